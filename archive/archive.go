@@ -0,0 +1,404 @@
+// Package archive keeps the signed TicketBAI documents, cancellations and
+// gateway receipts on the local filesystem for the years required by the
+// Basque regulations. Every record is indexed by TBAI code, NIF, series and
+// number, and date, and sealed with a hash chain so that any later change to
+// the files or the index can be detected during an inspection.
+//
+// An archive is safe for concurrent use within a single process, but should
+// not be shared between processes.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl/cal"
+)
+
+// Kind describes the type of record stored in the archive.
+type Kind string
+
+// Kinds of records.
+const (
+	KindInvoice Kind = "invoice" // signed TicketBAI document
+	KindCancel  Kind = "cancel"  // signed AnulaTicketBAI document
+	KindReceipt Kind = "receipt" // receipt provided by the gateway
+)
+
+const (
+	indexFile = "index.jsonl"
+	docsDir   = "docs"
+)
+
+// ErrNotFound is returned when no record matches the request.
+var ErrNotFound = errors.New("not found")
+
+var unsafePath = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// Entry describes a record in the archive.
+type Entry struct {
+	// Seq is the position of the entry in the archive, starting at 1.
+	Seq  int  `json:"seq"`
+	Kind Kind `json:"kind"`
+	// Code is the TBAI code of the invoice the record belongs to, if known.
+	Code   string   `json:"code,omitempty"`
+	NIF    string   `json:"nif"`
+	Series string   `json:"series,omitempty"`
+	Number string   `json:"number"`
+	Date   cal.Date `json:"date"`
+	// File is the path of the record's file, relative to the archive.
+	File     string    `json:"file"`
+	Hash     string    `json:"hash"`
+	StoredAt time.Time `json:"stored_at"`
+	// PrevSeal and Seal chain the entries: each seal is the hash of the
+	// previous seal and the rest of the entry's details.
+	PrevSeal string `json:"prev_seal,omitempty"`
+	Seal     string `json:"seal"`
+}
+
+// Query defines the filters used to find records. Empty fields match any
+// record.
+type Query struct {
+	Kind   Kind
+	Code   string
+	NIF    string
+	Series string
+	Number string
+	From   *cal.Date // inclusive
+	To     *cal.Date // inclusive
+}
+
+// Archive stores the records in a directory of the local filesystem.
+type Archive struct {
+	dir string
+	now func() time.Time
+
+	mu       sync.Mutex
+	entries  []*Entry
+	byCode   map[string][]*Entry
+	byNIF    map[string][]*Entry
+	byNumber map[string][]*Entry
+}
+
+// Option is used to configure the archive.
+type Option func(*Archive)
+
+// WithCurrentTime defines the function used to timestamp new entries. Useful
+// for testing.
+func WithCurrentTime(now func() time.Time) Option {
+	return func(a *Archive) {
+		a.now = now
+	}
+}
+
+// Open prepares the archive in the given directory, creating it if needed,
+// and loads its index. The seals are not checked, use Verify for that.
+func Open(dir string, opts ...Option) (*Archive, error) {
+	a := &Archive{
+		dir:      dir,
+		now:      time.Now,
+		byCode:   make(map[string][]*Entry),
+		byNIF:    make(map[string][]*Entry),
+		byNumber: make(map[string][]*Entry),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, docsDir), 0o750); err != nil {
+		return nil, fmt.Errorf("creating archive: %w", err)
+	}
+	f, err := os.Open(filepath.Join(dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening index: %w", err)
+	}
+	defer f.Close() // nolint:errcheck
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		e := new(Entry)
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("reading index entry %d: %w", len(a.entries)+1, err)
+		}
+		a.add(e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	return a, nil
+}
+
+// StoreDocument archives the exact XML of the signed TicketBAI document.
+func (a *Archive) StoreDocument(doc *convert.SignedDocument) (*Entry, error) {
+	if doc == nil {
+		return nil, errors.New("missing document")
+	}
+	if doc.Factura == nil || doc.Factura.CabeceraFactura == nil {
+		return nil, errors.New("document has no invoice")
+	}
+	if doc.Sujetos == nil || doc.Sujetos.Emisor == nil {
+		return nil, errors.New("document has no supplier")
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	h := doc.Head()
+	e := &Entry{
+		Kind:   KindInvoice,
+		Code:   doc.TBAICode(),
+		NIF:    doc.Sujetos.Emisor.NIF,
+		Series: h.SerieFactura,
+		Number: h.NumFactura,
+	}
+	if e.Date, err = convert.ParseDate(h.FechaExpedicionFactura); err != nil {
+		return nil, err
+	}
+	return a.store(e, data, ".xml")
+}
+
+// StoreCancel archives the exact XML of the signed cancellation document. The
+// TBAI code is taken from the cancelled invoice, if archived.
+func (a *Archive) StoreCancel(doc *convert.SignedCancelDocument) (*Entry, error) {
+	if doc == nil {
+		return nil, errors.New("missing document")
+	}
+	id := doc.IDFactura
+	if id == nil || id.Emisor == nil || id.CabeceraFactura == nil {
+		return nil, errors.New("document has no invoice")
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{
+		Kind:   KindCancel,
+		NIF:    id.Emisor.NIF,
+		Series: id.CabeceraFactura.SerieFactura,
+		Number: id.CabeceraFactura.NumFactura,
+	}
+	if e.Date, err = convert.ParseDate(id.CabeceraFactura.FechaExpedicionFactura); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	for _, prev := range a.byNumber[numberKey(e.NIF, e.Series, e.Number)] {
+		if prev.Kind == KindInvoice {
+			e.Code = prev.Code
+		}
+	}
+	a.mu.Unlock()
+	return a.store(e, data, ".xml")
+}
+
+// StoreReceipt archives the receipt provided by the gateway after sending the
+// document of the given entry.
+func (a *Archive) StoreReceipt(doc *Entry, r *ticketbai.Receipt) (*Entry, error) {
+	if doc == nil || r == nil {
+		return nil, errors.New("missing entry or receipt")
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{
+		Kind:   KindReceipt,
+		Code:   doc.Code,
+		NIF:    doc.NIF,
+		Series: doc.Series,
+		Number: doc.Number,
+		Date:   doc.Date,
+	}
+	return a.store(e, data, ".json")
+}
+
+// store writes the data to a new file and appends the sealed entry to the
+// index.
+func (a *Archive) store(e *Entry, data []byte, ext string) (*Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e.Seq = len(a.entries) + 1
+	e.StoredAt = a.now().UTC()
+	e.File = filepath.ToSlash(filepath.Join(
+		docsDir,
+		unsafePath.ReplaceAllString(e.NIF, "_"),
+		e.Date.String()[:4],
+		fmt.Sprintf("%08d-%s%s", e.Seq, e.Kind, ext),
+	))
+	e.Hash = hashBytes(data)
+	if n := len(a.entries); n > 0 {
+		e.PrevSeal = a.entries[n-1].Seal
+	}
+	seal, err := e.seal()
+	if err != nil {
+		return nil, err
+	}
+	e.Seal = seal
+
+	if err := writeFile(filepath.Join(a.dir, filepath.FromSlash(e.File)), data); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", e.File, err)
+	}
+	if err := a.appendIndex(e); err != nil {
+		return nil, err
+	}
+	a.add(e)
+	return e, nil
+}
+
+func (a *Archive) appendIndex(e *Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(a.dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening index: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close() // nolint:errcheck
+		return fmt.Errorf("writing index: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close() // nolint:errcheck
+		return fmt.Errorf("writing index: %w", err)
+	}
+	return f.Close()
+}
+
+func (a *Archive) add(e *Entry) {
+	a.entries = append(a.entries, e)
+	if e.Code != "" {
+		a.byCode[e.Code] = append(a.byCode[e.Code], e)
+	}
+	a.byNIF[e.NIF] = append(a.byNIF[e.NIF], e)
+	k := numberKey(e.NIF, e.Series, e.Number)
+	a.byNumber[k] = append(a.byNumber[k], e)
+}
+
+// Find provides the entries matching the query, in the order they were
+// stored.
+func (a *Archive) Find(q Query) []*Entry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Entries are only indexed by number along with their series, which may
+	// be empty, so the NIF index is used for queries without a series.
+	list := a.entries
+	switch {
+	case q.Code != "":
+		list = a.byCode[q.Code]
+	case q.NIF != "" && q.Series != "" && q.Number != "":
+		list = a.byNumber[numberKey(q.NIF, q.Series, q.Number)]
+	case q.NIF != "":
+		list = a.byNIF[q.NIF]
+	}
+
+	var out []*Entry
+	for _, e := range list {
+		if q.matches(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Entries provides all the entries in the order they were stored.
+func (a *Archive) Entries() []*Entry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*Entry(nil), a.entries...)
+}
+
+// Read provides the contents of the entry's file, ensuring they match the
+// hash in the index.
+func (a *Archive) Read(e *Entry) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, filepath.FromSlash(e.File)))
+	if err != nil {
+		return nil, err
+	}
+	if hashBytes(data) != e.Hash {
+		return nil, fmt.Errorf("%s: hash does not match index", e.File)
+	}
+	return data, nil
+}
+
+// Document loads the signed TicketBAI document with the given TBAI code.
+func (a *Archive) Document(code string) (*convert.SignedDocument, error) {
+	list := a.Find(Query{Kind: KindInvoice, Code: code})
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	data, err := a.Read(list[len(list)-1])
+	if err != nil {
+		return nil, err
+	}
+	return convert.ParseSignedDocument(data)
+}
+
+func (q Query) matches(e *Entry) bool {
+	switch {
+	case q.Kind != "" && e.Kind != q.Kind,
+		q.Code != "" && e.Code != q.Code,
+		q.NIF != "" && e.NIF != q.NIF,
+		q.Series != "" && e.Series != q.Series,
+		q.Number != "" && e.Number != q.Number,
+		q.From != nil && e.Date.String() < q.From.String(),
+		q.To != nil && e.Date.String() > q.To.String():
+		return false
+	}
+	return true
+}
+
+// writeFile writes the data to a temporary file which is then renamed, so
+// that partially written files are never left in the archive. Files left by
+// entries that never made it to the index are replaced.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() // nolint:errcheck
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() // nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func numberKey(nif, series, number string) string {
+	return strings.Join([]string{nif, series, number}, "\x00")
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
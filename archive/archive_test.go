@@ -0,0 +1,205 @@
+package archive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/archive"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/cal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2022, 2, 1, 12, 0, 0, 0, time.UTC)
+
+func signedCancel(t *testing.T, sd *convert.SignedDocument) *convert.SignedCancelDocument {
+	t.Helper()
+	cd, err := convert.NewAnulaTicketBAIFromDocument(sd.TicketBAI)
+	require.NoError(t, err)
+	require.NoError(t, cd.Sign("TEST", test.LoadCertificate(), convert.IssuerRoleSupplier, convert.ZoneBI))
+	scd, err := convert.NewSignedCancelDocument(cd)
+	require.NoError(t, err)
+	return scd
+}
+
+// fill stores two invoices, the receipt of the first and the cancellation of
+// the second.
+func fill(t *testing.T, a *archive.Archive) (*convert.SignedDocument, *convert.SignedDocument) {
+	t.Helper()
+	sd1 := test.LoadSignedDocument("invoice-es-es-b2c.xml")
+	sd2 := test.LoadSignedDocument("invoice-vi.xml")
+
+	e1, err := a.StoreDocument(sd1)
+	require.NoError(t, err)
+	_, err = a.StoreReceipt(e1, &ticketbai.Receipt{ID: "REG-1", ReceivedAt: "01-02-2022"})
+	require.NoError(t, err)
+	_, err = a.StoreDocument(sd2)
+	require.NoError(t, err)
+	_, err = a.StoreCancel(signedCancel(t, sd2))
+	require.NoError(t, err)
+	return sd1, sd2
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	a, err := archive.Open(dir, archive.WithCurrentTime(func() time.Time { return now }))
+	require.NoError(t, err)
+	sd1, sd2 := fill(t, a)
+
+	t.Run("should index the entries", func(t *testing.T) {
+		list := a.Find(archive.Query{Code: sd1.TBAICode()})
+		require.Len(t, list, 2)
+		assert.Equal(t, archive.KindInvoice, list[0].Kind)
+		assert.Equal(t, archive.KindReceipt, list[1].Kind)
+		assert.Equal(t, "SIMPL", list[0].Series)
+		assert.Equal(t, "0002", list[0].Number)
+		assert.Equal(t, "2022-02-01", list[0].Date.String())
+		assert.Equal(t, "docs/S7836107H/2022/00000001-invoice.xml", list[0].File)
+
+		list = a.Find(archive.Query{NIF: "S7836107H", Series: "TEST", Number: "004"})
+		require.Len(t, list, 2)
+		assert.Equal(t, archive.KindCancel, list[1].Kind)
+		assert.Equal(t, sd2.TBAICode(), list[1].Code)
+
+		list = a.Find(archive.Query{NIF: "S7836107H", Number: "0002"})
+		require.Len(t, list, 2)
+		assert.Equal(t, "SIMPL", list[0].Series)
+
+		assert.Len(t, a.Find(archive.Query{NIF: "S7836107H", Kind: archive.KindInvoice}), 2)
+		assert.Empty(t, a.Find(archive.Query{NIF: "B12345678"}))
+
+		from, to := cal.MakeDate(2022, 2, 1), cal.MakeDate(2022, 2, 28)
+		assert.Len(t, a.Find(archive.Query{From: &from, To: &to}), 4)
+		from = cal.MakeDate(2022, 2, 2)
+		assert.Empty(t, a.Find(archive.Query{From: &from}))
+	})
+
+	t.Run("should load the exact documents", func(t *testing.T) {
+		doc, err := a.Document(sd1.TBAICode())
+		require.NoError(t, err)
+		want, _ := sd1.Bytes()
+		got, _ := doc.Bytes()
+		assert.Equal(t, want, got)
+
+		_, err = a.Document("TBAI-MISSING")
+		assert.ErrorIs(t, err, archive.ErrNotFound)
+	})
+
+	t.Run("should chain the seals", func(t *testing.T) {
+		list := a.Entries()
+		require.Len(t, list, 4)
+		assert.Empty(t, list[0].PrevSeal)
+		for i := 1; i < len(list); i++ {
+			assert.Equal(t, list[i-1].Seal, list[i].PrevSeal)
+		}
+		assert.Equal(t, list[3].Seal, a.Head())
+		assert.NoError(t, a.Verify())
+	})
+
+	t.Run("should reopen the archive", func(t *testing.T) {
+		b, err := archive.Open(dir)
+		require.NoError(t, err)
+		assert.NoError(t, b.Verify())
+		assert.Equal(t, a.Head(), b.Head())
+		assert.Len(t, b.Find(archive.Query{Code: sd1.TBAICode()}), 2)
+	})
+
+	t.Run("should refuse cancellations without an invoice", func(t *testing.T) {
+		cd := signedCancel(t, sd2)
+		cd.IDFactura = nil
+		_, err := a.StoreCancel(cd)
+		assert.ErrorContains(t, err, "document has no invoice")
+		assert.Len(t, a.Entries(), 4)
+	})
+}
+
+func TestArchiveTampering(t *testing.T) {
+	t.Run("should detect modified files", func(t *testing.T) {
+		dir := t.TempDir()
+		a, err := archive.Open(dir)
+		require.NoError(t, err)
+		fill(t, a)
+
+		e := a.Entries()[0]
+		path := filepath.Join(dir, filepath.FromSlash(e.File))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte("0002"), []byte("0003"), 1), 0o600))
+
+		assert.ErrorContains(t, a.Verify(), "hash does not match index")
+	})
+
+	t.Run("should detect modified entries", func(t *testing.T) {
+		dir := t.TempDir()
+		a, err := archive.Open(dir)
+		require.NoError(t, err)
+		fill(t, a)
+
+		path := filepath.Join(dir, "index.jsonl")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte(`"number":"004"`), []byte(`"number":"005"`), 1), 0o600))
+
+		b, err := archive.Open(dir)
+		require.NoError(t, err)
+		assert.ErrorContains(t, b.Verify(), "seal does not match contents")
+	})
+
+	t.Run("should detect removed entries", func(t *testing.T) {
+		dir := t.TempDir()
+		a, err := archive.Open(dir)
+		require.NoError(t, err)
+		fill(t, a)
+
+		path := filepath.Join(dir, "index.jsonl")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := bytes.SplitAfter(data, []byte("\n"))
+		require.NoError(t, os.WriteFile(path, bytes.Join(append(lines[:1], lines[2:]...), nil), 0o600))
+
+		b, err := archive.Open(dir)
+		require.NoError(t, err)
+		assert.Error(t, b.Verify())
+	})
+}
+
+func TestArchiveExport(t *testing.T) {
+	a, err := archive.Open(t.TempDir(), archive.WithCurrentTime(func() time.Time { return now }))
+	require.NoError(t, err)
+	_, sd2 := fill(t, a)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, a.Export(buf, archive.Query{Number: "004"}))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	require.Contains(t, files, "manifest.json")
+
+	rc, err := files["manifest.json"].Open()
+	require.NoError(t, err)
+	m := new(archive.Manifest)
+	require.NoError(t, json.NewDecoder(rc).Decode(m))
+	require.NoError(t, rc.Close())
+
+	assert.Equal(t, a.Head(), m.Head)
+	require.Len(t, m.Entries, 2)
+	assert.Equal(t, sd2.TBAICode(), m.Entries[0].Code)
+	assert.NoError(t, m.Entries[0].CheckSeal(m.Entries[0].PrevSeal))
+	assert.NoError(t, m.Entries[1].CheckSeal(m.Entries[0].Seal))
+	for _, e := range m.Entries {
+		assert.Contains(t, files, e.File)
+	}
+	assert.Len(t, files, 3)
+}
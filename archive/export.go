@@ -0,0 +1,64 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// manifestFile is the name of the file with the exported entries.
+const manifestFile = "manifest.json"
+
+// Manifest describes the contents of an export.
+type Manifest struct {
+	ExportedAt time.Time `json:"exported_at"`
+	// Head is the seal of the last entry in the archive at the time of the
+	// export.
+	Head    string   `json:"head,omitempty"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Export writes a zip file with the records matching the query, for example
+// those of a NIF over the period requested in a tax inspection. The files keep
+// their paths in the archive and are listed in a manifest along with their
+// index entries, so that the hash of each file and the seal of each entry can
+// be checked without access to the rest of the archive.
+func (a *Archive) Export(w io.Writer, q Query) error {
+	m := &Manifest{
+		ExportedAt: a.now().UTC(),
+		Head:       a.Head(),
+		Entries:    a.Find(q),
+	}
+
+	zw := zip.NewWriter(w)
+	for _, e := range m.Entries {
+		data, err := a.Read(e)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", e.Seq, err)
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     e.File,
+			Method:   zip.Deflate,
+			Modified: e.StoredAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create(manifestFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	return zw.Close()
+}
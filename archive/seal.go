@@ -0,0 +1,69 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// seal calculates the hash that chains the entry to the previous one, using
+// every field except the seal itself.
+func (e *Entry) seal() (string, error) {
+	c := *e
+	c.Seal = ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CheckSeal ensures the entry's seal matches its contents and follows the
+// previous entry's seal, which should be empty for the first entry. Used to
+// check exported entries independently of the archive.
+func (e *Entry) CheckSeal(prevSeal string) error {
+	if e.PrevSeal != prevSeal {
+		return fmt.Errorf("entry %d: does not follow the previous seal", e.Seq)
+	}
+	seal, err := e.seal()
+	if err != nil {
+		return err
+	}
+	if seal != e.Seal {
+		return fmt.Errorf("entry %d: seal does not match contents", e.Seq)
+	}
+	return nil
+}
+
+// Verify checks the whole archive: the chain of seals in the index, and the
+// hashes of the files of every entry. The first problem found is returned.
+func (a *Archive) Verify() error {
+	prev := ""
+	for i, e := range a.Entries() {
+		if e.Seq != i+1 {
+			return fmt.Errorf("entry %d: unexpected sequence %d", i+1, e.Seq)
+		}
+		if err := e.CheckSeal(prev); err != nil {
+			return err
+		}
+		if _, err := a.Read(e); err != nil {
+			return fmt.Errorf("entry %d: %w", e.Seq, err)
+		}
+		prev = e.Seal
+	}
+	return nil
+}
+
+// Head provides the seal of the last entry, which can be recorded elsewhere,
+// for example in a periodic report, to detect the archive being rolled back
+// or rewritten.
+func (a *Archive) Head() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n := len(a.entries); n > 0 {
+		return a.entries[n-1].Seal
+	}
+	return ""
+}
@@ -8,9 +8,29 @@
 //	openssl x509 -in AAPPNR_cert_sha256.crt -outform PEM -out AAPPNR_cert_sha256.pem
 package ca
 
-import "embed"
+import (
+	"crypto/x509"
+	"embed"
+)
 
 //go:embed *.pem
 
 // Content contains the root certificates used by the TicketBAI services.
 var Content embed.FS
+
+// Pool prepares a new certificate pool with the embedded root certificates.
+func Pool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	files, err := Content.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := Content.ReadFile(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		pool.AppendCertsFromPEM(data)
+	}
+	return pool, nil
+}
@@ -24,7 +24,7 @@ func (c *Client) GenerateCancel(env *gobl.Envelope) (*convert.AnulaTicketBAI, er
 	if inv.Supplier.TaxID.Country != l10n.ES.Tax() {
 		return nil, ErrValidation.withMessage("only spanish invoices are supported")
 	}
-	zone := zoneFor(inv)
+	zone := c.zoneFor(inv)
 	if zone == "" {
 		return nil, ErrValidation.withMessage("invalid zone")
 	}
@@ -44,11 +44,53 @@ func (c *Client) GenerateCancel(env *gobl.Envelope) (*convert.AnulaTicketBAI, er
 	return cd, nil
 }
 
+// GenerateCancelFromDocument creates a new AnulaTicketBAI document that cancels
+// the provided signed TicketBAI document, usually obtained with ParseDocument
+// from the XML stored when the invoice was issued. Unlike GenerateCancel, the
+// envelope's stamps are not required.
+func (c *Client) GenerateCancelFromDocument(d *convert.TicketBAI) (*convert.AnulaTicketBAI, error) {
+	cd, err := convert.NewAnulaTicketBAIFromDocument(d)
+	if err != nil {
+		return nil, ErrValidation.withCause(err)
+	}
+	return cd, nil
+}
+
+// GenerateCancelFromChainData creates a new AnulaTicketBAI document for the
+// invoice in the envelope using the chain data stored when it was issued. The
+// series, number and issue date are taken from the chain data instead of the
+// invoice or its stamps.
+func (c *Client) GenerateCancelFromChainData(env *gobl.Envelope, data *convert.ChainData) (*convert.AnulaTicketBAI, error) {
+	inv, ok := env.Extract().(*bill.Invoice)
+	if !ok {
+		return nil, ErrValidation.withMessage("only invoices are supported")
+	}
+	if inv.Supplier.TaxID.Country != l10n.ES.Tax() {
+		return nil, ErrValidation.withMessage("only spanish invoices are supported")
+	}
+	if c.zoneFor(inv) == "" {
+		return nil, ErrValidation.withMessage("invalid zone")
+	}
+
+	cd, err := convert.NewAnulaTicketBAIFromChainData(inv.Supplier, data)
+	if err != nil {
+		return nil, ErrValidation.withCause(err)
+	}
+	return cd, nil
+}
+
 // FingerprintCancel generates a finger print for the TicketBAI document using the
 // data provided from the previous invoice data.
 func (c *Client) FingerprintCancel(cd *convert.AnulaTicketBAI) error {
+	if err := c.checkDevice(); err != nil {
+		return err
+	}
 	conf := c.buildSoftware()
-	return cd.Fingerprint(conf)
+	if err := cd.Fingerprint(conf); err != nil {
+		return err
+	}
+	cd.HuellaTBAI.NumSerieDispositivo = c.device
+	return nil
 }
 
 // SignCancel is used to generate the XML DSig components of the final XML document.
@@ -57,12 +99,21 @@ func (c *Client) SignCancel(cd *convert.AnulaTicketBAI, env *gobl.Envelope) erro
 	if !ok {
 		return ErrValidation.withMessage("only invoices are supported")
 	}
-	zone := zoneFor(inv)
+	zone := c.zoneFor(inv)
 	if zone == "" {
 		return ErrValidation.withMessage("invalid zone: '%s'", zone)
 	}
+	if err := c.matchZone(zone); err != nil {
+		return err
+	}
 	dID := env.Head.UUID.String()
-	if err := cd.Sign(dID, c.cert, c.issuerRole, zone, xmldsig.WithCurrentTime(c.CurrentTime)); err != nil {
+	var err error
+	if c.signer != nil {
+		err = cd.SignWith(dID, c.signer, c.issuerRole, zone, xmldsig.WithCurrentTime(c.CurrentTime))
+	} else {
+		err = cd.Sign(dID, c.cert, c.issuerRole, zone, xmldsig.WithCurrentTime(c.CurrentTime))
+	}
+	if err != nil {
 		return fmt.Errorf("signing: %w", err)
 	}
 
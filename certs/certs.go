@@ -0,0 +1,375 @@
+// Package certs inspects the certificates used to sign TicketBAI documents and
+// authenticate with the agencies, so that problems such as expired
+// certificates or a holder that does not match the invoice's supplier can be
+// detected before any document is sent.
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invopop/gobl.ticketbai/ca"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/xmldsig"
+)
+
+// Kind describes the type of certificate according to its holder.
+type Kind string
+
+// Kinds of certificates issued by Izenpe and other Spanish authorities.
+const (
+	KindUnknown        Kind = ""
+	KindCitizen        Kind = "citizen"        // natural person
+	KindRepresentative Kind = "representative" // natural person representing an entity
+	KindSeal           Kind = "seal"           // entity seal
+	KindDevice         Kind = "device"         // device or application
+)
+
+// expiryMargin is the time before expiry from which a warning is reported.
+const expiryMargin = 30 * 24 * time.Hour
+
+var (
+	oidOrganizationIdentifier = asn1.ObjectIdentifier{2, 5, 4, 97}
+	oidQCPLegal               = asn1.ObjectIdentifier{0, 4, 0, 194112, 1, 1} // QCP-l
+	oidQCPLegalQSCD           = asn1.ObjectIdentifier{0, 4, 0, 194112, 1, 3} // QCP-l-qscd
+)
+
+// identifier prefixes used in the serial number and organization identifier
+// attributes, as defined by ETSI EN 319 412-1.
+var idPrefixes = []string{"IDCES-", "PNOES-", "VATES-", "NTRES-"}
+
+// Holder identifies a party in a certificate.
+type Holder struct {
+	NIF  string `json:"nif"`
+	Name string `json:"name,omitempty"`
+}
+
+// Info contains the details of a certificate relevant to TicketBAI.
+type Info struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Kind         Kind      `json:"kind,omitempty"`
+	// Holder is the natural person or entity the certificate was issued to,
+	// which is the entity in the case of representatives. Empty for devices.
+	Holder *Holder `json:"holder,omitempty"`
+	// Representative is the natural person acting on behalf of the entity
+	// in representative certificates.
+	Representative *Holder  `json:"representative,omitempty"`
+	KeyUsage       []string `json:"key_usage,omitempty"`
+	ExtKeyUsage    []string `json:"ext_key_usage,omitempty"`
+	// Trusted is true when the certificate chain can be verified against the
+	// roots embedded in the ca package, or the ones provided with WithRoots.
+	Trusted bool `json:"trusted"`
+	// Warnings contains the problems found that may lead the agencies to
+	// reject the signatures or connections made with the certificate.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+type options struct {
+	now   time.Time
+	roots *x509.CertPool
+}
+
+// Option is used to configure the inspection.
+type Option func(*options)
+
+// WithCurrentTime defines the time used to check the validity of the
+// certificate. Useful for testing.
+func WithCurrentTime(ts time.Time) Option {
+	return func(o *options) {
+		o.now = ts
+	}
+}
+
+// WithRoots defines the root certificates used to verify the certificate
+// chain, instead of the ones embedded in the ca package.
+func WithRoots(pool *x509.CertPool) Option {
+	return func(o *options) {
+		o.roots = pool
+	}
+}
+
+// Inspect extracts the details of the signing certificate and checks it for
+// common problems.
+func Inspect(cert *xmldsig.Certificate, opts ...Option) (*Info, error) {
+	if cert == nil {
+		return nil, errors.New("missing certificate")
+	}
+	block, _ := pem.Decode(cert.PEM())
+	if block == nil {
+		return nil, errors.New("invalid certificate")
+	}
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+	return InspectX509(c, cert.CaChain, opts...)
+}
+
+// InspectX509 extracts the details of the certificate, using the provided
+// chain of intermediate certificates to verify it, and checks it for common
+// problems.
+func InspectX509(c *x509.Certificate, chain []*x509.Certificate, opts ...Option) (*Info, error) {
+	o := &options{now: time.Now()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	info := &Info{
+		Subject:      c.Subject.String(),
+		Issuer:       c.Issuer.String(),
+		SerialNumber: c.SerialNumber.String(),
+		NotBefore:    c.NotBefore,
+		NotAfter:     c.NotAfter,
+		KeyUsage:     keyUsages(c.KeyUsage),
+		ExtKeyUsage:  extKeyUsages(c),
+	}
+	info.classify(c)
+
+	roots := o.roots
+	if roots == nil {
+		var err error
+		roots, err = ca.Pool()
+		if err != nil {
+			return nil, fmt.Errorf("preparing cert pool: %w", err)
+		}
+	}
+	inter := x509.NewCertPool()
+	for _, ic := range chain {
+		inter.AddCert(ic)
+	}
+	_, verr := c.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: inter,
+		CurrentTime:   o.now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	info.Trusted = verr == nil
+
+	info.check(c, o)
+
+	return info, nil
+}
+
+// CheckRole checks that the certificate may be used to sign documents with
+// the given issuer role, for the supplier and customer with the NIFs
+// provided, returning the problems found.
+func (info *Info) CheckRole(role convert.IssuerRole, supplier, customer string) []string {
+	if info.Kind == KindDevice {
+		return []string{"device certificates do not identify the holder's NIF"}
+	}
+	if info.Holder == nil {
+		return []string{"certificate holder NIF cannot be determined"}
+	}
+	holder := normalizeNIF(info.Holder.NIF)
+	switch role {
+	case convert.IssuerRoleSupplier:
+		if holder != normalizeNIF(supplier) {
+			return []string{fmt.Sprintf("certificate holder NIF '%s' does not match supplier NIF '%s'", info.Holder.NIF, supplier)}
+		}
+	case convert.IssuerRoleCustomer:
+		if holder != normalizeNIF(customer) {
+			return []string{fmt.Sprintf("certificate holder NIF '%s' does not match customer NIF '%s'", info.Holder.NIF, customer)}
+		}
+	case convert.IssuerRoleThirdParty:
+		if holder == normalizeNIF(supplier) {
+			return []string{"certificate belongs to the supplier, use the supplier issuer role instead"}
+		}
+	default:
+		return []string{fmt.Sprintf("unknown issuer role '%s'", role)}
+	}
+	return nil
+}
+
+// CheckDocument checks that the certificate may be used to sign the
+// document, according to the document's issuer role and parties.
+func (info *Info) CheckDocument(doc *convert.TicketBAI) []string {
+	if doc.Sujetos == nil || doc.Sujetos.Emisor == nil {
+		return []string{"document has no supplier"}
+	}
+	customer := ""
+	if d := doc.Sujetos.Destinatarios; d != nil && len(d.IDDestinatario) > 0 {
+		customer = d.IDDestinatario[0].NIF
+	}
+	role := convert.IssuerRole(doc.Sujetos.EmitidaPorTercerosODestinatario)
+	return info.CheckRole(role, doc.Sujetos.Emisor.NIF, customer)
+}
+
+// Expired returns true if the certificate is no longer valid at the given
+// time.
+func (info *Info) Expired(ts time.Time) bool {
+	return ts.After(info.NotAfter)
+}
+
+func (info *Info) classify(c *x509.Certificate) {
+	orgID := ""
+	for _, n := range c.Subject.Names {
+		if n.Type.Equal(oidOrganizationIdentifier) {
+			orgID, _ = n.Value.(string)
+		}
+	}
+	serial := c.Subject.SerialNumber
+
+	switch {
+	case orgID != "" && hasPolicy(c, oidQCPLegal, oidQCPLegalQSCD):
+		info.Kind = KindSeal
+		info.Holder = &Holder{NIF: stripIDPrefix(orgID), Name: first(c.Subject.Organization)}
+	case orgID != "":
+		info.Kind = KindRepresentative
+		info.Holder = &Holder{NIF: stripIDPrefix(orgID), Name: first(c.Subject.Organization)}
+		if serial != "" {
+			info.Representative = &Holder{NIF: stripIDPrefix(serial), Name: personName(c)}
+		}
+	case serial != "":
+		info.Kind = KindCitizen
+		info.Holder = &Holder{NIF: stripIDPrefix(serial), Name: personName(c)}
+	default:
+		info.Kind = KindDevice
+	}
+}
+
+func (info *Info) check(c *x509.Certificate, o *options) {
+	now := o.now
+	switch {
+	case now.Before(c.NotBefore):
+		info.warn("certificate not valid until %s", c.NotBefore.Format(time.DateOnly))
+	case now.After(c.NotAfter):
+		info.warn("certificate expired on %s", c.NotAfter.Format(time.DateOnly))
+	case now.Add(expiryMargin).After(c.NotAfter):
+		info.warn("certificate expires on %s", c.NotAfter.Format(time.DateOnly))
+	}
+	if c.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) == 0 {
+		info.warn("key usage does not allow digital signatures")
+	}
+	if len(c.ExtKeyUsage) > 0 && !hasExtKeyUsage(c, x509.ExtKeyUsageClientAuth) {
+		info.warn("extended key usage does not allow TLS client authentication")
+	}
+	if info.Kind == KindDevice {
+		info.warn("device certificates do not identify the holder's NIF")
+	}
+	switch {
+	case info.Trusted:
+	case o.roots != nil:
+		info.warn("certificate chain cannot be verified against the provided roots")
+	default:
+		info.warn("certificate chain cannot be verified against the embedded roots")
+	}
+}
+
+func (info *Info) warn(format string, args ...any) {
+	info.Warnings = append(info.Warnings, fmt.Sprintf(format, args...))
+}
+
+func hasPolicy(c *x509.Certificate, oids ...asn1.ObjectIdentifier) bool {
+	for _, p := range c.PolicyIdentifiers {
+		for _, oid := range oids {
+			if p.Equal(oid) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasExtKeyUsage(c *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range c.ExtKeyUsage {
+		if u == usage || u == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital-signature"},
+	{x509.KeyUsageContentCommitment, "content-commitment"},
+	{x509.KeyUsageKeyEncipherment, "key-encipherment"},
+	{x509.KeyUsageDataEncipherment, "data-encipherment"},
+	{x509.KeyUsageKeyAgreement, "key-agreement"},
+	{x509.KeyUsageCertSign, "cert-sign"},
+	{x509.KeyUsageCRLSign, "crl-sign"},
+}
+
+func keyUsages(ku x509.KeyUsage) []string {
+	var out []string
+	for _, k := range keyUsageNames {
+		if ku&k.usage != 0 {
+			out = append(out, k.name)
+		}
+	}
+	return out
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "server-auth",
+	x509.ExtKeyUsageClientAuth:      "client-auth",
+	x509.ExtKeyUsageCodeSigning:     "code-signing",
+	x509.ExtKeyUsageEmailProtection: "email-protection",
+	x509.ExtKeyUsageTimeStamping:    "time-stamping",
+}
+
+func extKeyUsages(c *x509.Certificate) []string {
+	var out []string
+	for _, u := range c.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[u]; ok {
+			out = append(out, name)
+		}
+	}
+	for _, oid := range c.UnknownExtKeyUsage {
+		out = append(out, oid.String())
+	}
+	return out
+}
+
+func personName(c *x509.Certificate) string {
+	var given, surname string
+	for _, n := range c.Subject.Names {
+		v, _ := n.Value.(string)
+		switch {
+		case n.Type.Equal(asn1.ObjectIdentifier{2, 5, 4, 42}):
+			given = v
+		case n.Type.Equal(asn1.ObjectIdentifier{2, 5, 4, 4}):
+			surname = v
+		}
+	}
+	if name := strings.TrimSpace(given + " " + surname); name != "" {
+		return name
+	}
+	return c.Subject.CommonName
+}
+
+func stripIDPrefix(id string) string {
+	for _, p := range idPrefixes {
+		if strings.HasPrefix(id, p) {
+			return strings.TrimPrefix(id, p)
+		}
+	}
+	return id
+}
+
+func normalizeNIF(nif string) string {
+	nif = strings.ToUpper(strings.TrimSpace(nif))
+	if len(nif) == 11 {
+		nif = strings.TrimPrefix(nif, "ES")
+	}
+	return nif
+}
+
+func first(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0]
+}
@@ -0,0 +1,172 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/invopop/gobl.ticketbai/certs"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/xmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadCertificate(t *testing.T, name string) *xmldsig.Certificate {
+	t.Helper()
+	pass, err := os.ReadFile(test.Path("test", "certs", name+"_pin.txt"))
+	require.NoError(t, err)
+	cert, err := xmldsig.LoadCertificate(test.Path("test", "certs", name+".p12"), string(pass))
+	require.NoError(t, err)
+	return cert
+}
+
+func inspect(t *testing.T, name string, ts time.Time) *certs.Info {
+	t.Helper()
+	info, err := certs.Inspect(loadCertificate(t, name), certs.WithCurrentTime(ts))
+	require.NoError(t, err)
+	return info
+}
+
+var now = time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func TestInspect(t *testing.T) {
+	t.Run("representative", func(t *testing.T) {
+		info := inspect(t, "EntitateOrdezkaria_RepresentanteDeEntidad", now)
+		assert.Equal(t, certs.KindRepresentative, info.Kind)
+		require.NotNil(t, info.Holder)
+		assert.Equal(t, "S7836107H", info.Holder.NIF)
+		assert.Equal(t, "IZENPE S.A.", info.Holder.Name)
+		require.NotNil(t, info.Representative)
+		assert.Equal(t, "99999973K", info.Representative.NIF)
+		assert.Equal(t, "REPRESENTANTE FICTICIO ACTIVO", info.Representative.Name)
+		assert.Contains(t, info.KeyUsage, "digital-signature")
+		assert.Contains(t, info.ExtKeyUsage, "client-auth")
+	})
+
+	t.Run("seal", func(t *testing.T) {
+		info := inspect(t, "EnpresaZigilua_SelloDeEmpresa", now)
+		assert.Equal(t, certs.KindSeal, info.Kind)
+		require.NotNil(t, info.Holder)
+		assert.Equal(t, "S7836107H", info.Holder.NIF)
+		assert.Nil(t, info.Representative)
+	})
+
+	t.Run("citizen", func(t *testing.T) {
+		info := inspect(t, "PertsonaFisikoa_PersonaFisica", now)
+		assert.Equal(t, certs.KindCitizen, info.Kind)
+		require.NotNil(t, info.Holder)
+		assert.Equal(t, "99999972C", info.Holder.NIF)
+		assert.Equal(t, "NUEVOCIUD FICTICIO ACTIVO", info.Holder.Name)
+	})
+
+	t.Run("device", func(t *testing.T) {
+		info := inspect(t, "Gailua_Dispositivo", now)
+		assert.Equal(t, certs.KindDevice, info.Kind)
+		assert.Nil(t, info.Holder)
+		assert.Contains(t, info.Warnings, "device certificates do not identify the holder's NIF")
+	})
+
+	t.Run("development certificates are not trusted", func(t *testing.T) {
+		info := inspect(t, "EntitateOrdezkaria_RepresentanteDeEntidad", now)
+		assert.False(t, info.Trusted)
+		assert.Contains(t, info.Warnings, "certificate chain cannot be verified against the embedded roots")
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		info := inspect(t, "EntitateOrdezkaria_RepresentanteDeEntidad", time.Date(2029, 1, 10, 0, 0, 0, 0, time.UTC))
+		assert.Contains(t, info.Warnings, "certificate expires on 2029-01-22")
+
+		info = inspect(t, "EntitateOrdezkaria_RepresentanteDeEntidad", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+		assert.Contains(t, info.Warnings, "certificate expired on 2029-01-22")
+		assert.True(t, info.Expired(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestCheckRole(t *testing.T) {
+	info := inspect(t, "EntitateOrdezkaria_RepresentanteDeEntidad", now)
+
+	assert.Empty(t, info.CheckRole(convert.IssuerRoleSupplier, "S7836107H", ""))
+	assert.Empty(t, info.CheckRole(convert.IssuerRoleSupplier, "ESS7836107H", ""))
+	assert.Equal(t,
+		[]string{"certificate holder NIF 'S7836107H' does not match supplier NIF 'B98602642'"},
+		info.CheckRole(convert.IssuerRoleSupplier, "B98602642", ""),
+	)
+	assert.Empty(t, info.CheckRole(convert.IssuerRoleCustomer, "B98602642", "S7836107H"))
+	assert.NotEmpty(t, info.CheckRole(convert.IssuerRoleCustomer, "B98602642", "54387763P"))
+	assert.Empty(t, info.CheckRole(convert.IssuerRoleThirdParty, "B98602642", ""))
+	assert.Equal(t,
+		[]string{"certificate belongs to the supplier, use the supplier issuer role instead"},
+		info.CheckRole(convert.IssuerRoleThirdParty, "S7836107H", ""),
+	)
+
+	device := inspect(t, "Gailua_Dispositivo", now)
+	for _, role := range []convert.IssuerRole{
+		convert.IssuerRoleSupplier,
+		convert.IssuerRoleCustomer,
+		convert.IssuerRoleThirdParty,
+	} {
+		assert.Equal(t,
+			[]string{"device certificates do not identify the holder's NIF"},
+			device.CheckRole(role, "S7836107H", "B98602642"),
+		)
+	}
+}
+
+func TestCheckDocument(t *testing.T) {
+	info := inspect(t, "EntitateOrdezkaria_RepresentanteDeEntidad", now)
+	doc := &convert.TicketBAI{
+		Sujetos: &convert.Sujetos{
+			Emisor:                          &convert.Emisor{NIF: "S7836107H"},
+			EmitidaPorTercerosODestinatario: string(convert.IssuerRoleSupplier),
+		},
+	}
+	assert.Empty(t, info.CheckDocument(doc))
+
+	doc.Sujetos.EmitidaPorTercerosODestinatario = string(convert.IssuerRoleThirdParty)
+	assert.NotEmpty(t, info.CheckDocument(doc))
+}
+
+func TestInspectWithRoots(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Local Root"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	c, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+
+	t.Run("trusted by the provided roots", func(t *testing.T) {
+		pool := x509.NewCertPool()
+		pool.AddCert(c)
+		info, err := certs.InspectX509(c, nil, certs.WithCurrentTime(now), certs.WithRoots(pool))
+		require.NoError(t, err)
+		assert.True(t, info.Trusted)
+	})
+
+	t.Run("not trusted by the embedded roots", func(t *testing.T) {
+		info, err := certs.InspectX509(c, nil, certs.WithCurrentTime(now))
+		require.NoError(t, err)
+		assert.False(t, info.Trusted)
+	})
+
+	t.Run("not trusted by other roots", func(t *testing.T) {
+		info, err := certs.InspectX509(c, nil, certs.WithCurrentTime(now), certs.WithRoots(x509.NewCertPool()))
+		require.NoError(t, err)
+		assert.False(t, info.Trusted)
+		assert.Contains(t, info.Warnings, "certificate chain cannot be verified against the provided roots")
+	})
+}
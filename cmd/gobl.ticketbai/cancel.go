@@ -5,15 +5,19 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/invopop/gobl"
 	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/xmldsig"
 	"github.com/spf13/cobra"
 )
 
 type cancelOpts struct {
 	*rootOpts
+
+	doc string
 }
 
 func cancel(o *rootOpts) *cancelOpts {
@@ -24,12 +28,16 @@ func (c *cancelOpts) cmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "cancel [infile]",
 		Short: "Cancels an invoice in the TicketBAI provider",
-		RunE:  c.runE,
+		Run: func(cmd *cobra.Command, args []string) {
+			handleError(c.runE(cmd, args))
+		},
 	}
 
 	f := cmd.Flags()
 	c.prepareFlags(f)
 
+	f.StringVar(&c.doc, "doc", "", "Path to the original signed TicketBAI XML to cancel")
+
 	return cmd
 }
 
@@ -49,19 +57,22 @@ func (c *cancelOpts) runE(cmd *cobra.Command, args []string) error {
 	if err := json.Unmarshal(buf.Bytes(), env); err != nil {
 		return fmt.Errorf("unmarshaling gobl envelope: %w", err)
 	}
-	zone := ticketbai.ZoneFor(env)
+	zr := c.zoneResolver()
+	zone, _ := zr.ResolveEnvelope(env)
 	if zone == "" {
 		return fmt.Errorf("no zone found in envelope")
 	}
 
 	cert, err := xmldsig.LoadCertificate(c.cert, c.password)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	opts := []ticketbai.Option{
 		ticketbai.WithCertificate(cert),
 		ticketbai.WithThirdPartyIssuer(),
+		ticketbai.WithZoneResolver(zr),
+		ticketbai.WithDeviceSerial(c.device),
 	}
 
 	if c.production {
@@ -72,27 +83,49 @@ func (c *cancelOpts) runE(cmd *cobra.Command, args []string) error {
 
 	tc, err := ticketbai.New(c.software(zone), zone, opts...)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	tcd, err := tc.GenerateCancel(env)
-	if err != nil {
-		panic(err)
+	var tcd *convert.AnulaTicketBAI
+	if c.doc != "" {
+		data, err := os.ReadFile(c.doc)
+		if err != nil {
+			return fmt.Errorf("reading document: %w", err)
+		}
+		td, err := ticketbai.ParseDocument(data)
+		if err != nil {
+			return fmt.Errorf("parsing document: %w", err)
+		}
+		tcd, err = tc.GenerateCancelFromDocument(td)
+		if err != nil {
+			return err
+		}
+	} else {
+		tcd, err = tc.GenerateCancel(env)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = tc.FingerprintCancel(tcd)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	if err := tc.SignCancel(tcd, env); err != nil {
-		panic(err)
+		return err
+	}
+
+	receipt, err := tc.Cancel(cmd.Context(), env, tcd)
+	if err != nil {
+		return err
 	}
 
-	err = tc.Cancel(cmd.Context(), env, tcd)
+	data, err := json.Marshal(receipt)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	fmt.Printf("Cancelled document with receipt: \n%s\n", string(data))
 
 	return nil
 }
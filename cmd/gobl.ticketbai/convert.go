@@ -8,14 +8,16 @@ import (
 
 	"github.com/invopop/gobl"
 	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/spf13/cobra"
 )
 
 type convertOpts struct {
 	*rootOpts
+	substitution bool
 }
 
-func convert(o *rootOpts) *convertOpts {
+func convertCmd(o *rootOpts) *convertOpts {
 	return &convertOpts{rootOpts: o}
 }
 
@@ -26,6 +28,11 @@ func (c *convertOpts) cmd() *cobra.Command {
 		RunE:  c.runE,
 	}
 
+	f := cmd.Flags()
+	f.BoolVar(&c.inferZone, "infer-zone", false, "Infer the zone from the supplier's address when missing")
+	f.BoolVar(&c.aggregate, "aggregate-lines", false, "Merge lines with the same item, price and taxes when over the limit")
+	f.BoolVar(&c.substitution, "simplified-substitution", false, "Flag the invoice as a replacement of the preceding simplified invoices")
+
 	return cmd
 }
 
@@ -51,17 +58,23 @@ func (c *convertOpts) runE(cmd *cobra.Command, args []string) error {
 	if err := json.Unmarshal(buf.Bytes(), env); err != nil {
 		return fmt.Errorf("unmarshaling gobl envelope: %w", err)
 	}
-	zone := ticketbai.ZoneFor(env)
+	zr := c.zoneResolver()
+	zone, _ := zr.ResolveEnvelope(env)
 	if zone == "" {
 		return fmt.Errorf("no zone found in envelope")
 	}
 
-	tc, err := ticketbai.New(&ticketbai.Software{}, zone)
+	opts := append([]ticketbai.Option{ticketbai.WithZoneResolver(zr)}, c.convertOptions()...)
+	tc, err := ticketbai.New(&ticketbai.Software{}, zone, opts...)
 	if err != nil {
 		return fmt.Errorf("creating ticketbai client: %w", err)
 	}
 
-	td, err := tc.Convert(env)
+	var convOpts []convert.Option
+	if c.substitution {
+		convOpts = append(convOpts, convert.WithSimplifiedSubstitution())
+	}
+	td, err := tc.Convert(env, convOpts...)
 	if err != nil {
 		panic(err)
 	}
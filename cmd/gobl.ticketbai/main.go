@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	ticketbai "github.com/invopop/gobl.ticketbai"
 	"github.com/invopop/gobl.ticketbai/internal/gateways"
 )
 
@@ -50,7 +51,11 @@ func handleError(err error) {
 	}
 
 	eb := new(errorBody)
-	if e, ok := err.(*gateways.Error); ok {
+	if e, ok := err.(*ticketbai.Error); ok {
+		eb.Key = e.Key()
+		eb.Code = e.Code()
+		eb.Error = e.Message()
+	} else if e, ok := err.(*gateways.Error); ok {
 		eb.Key = e.Key()
 		eb.Code = e.Code()
 		eb.Error = e.Message()
@@ -20,7 +20,10 @@ type rootOpts struct {
 	swName        string
 	swVersion     string
 	swLicense     string
+	device        string
 	production    bool
+	inferZone     bool
+	aggregate     bool
 }
 
 func root() *rootOpts {
@@ -36,7 +39,7 @@ func (o *rootOpts) cmd() *cobra.Command {
 
 	cmd.AddCommand(versionCmd())
 	cmd.AddCommand(send(o).cmd())
-	cmd.AddCommand(convert(o).cmd())
+	cmd.AddCommand(convertCmd(o).cmd())
 	cmd.AddCommand(cancel(o).cmd())
 
 	return cmd
@@ -50,7 +53,27 @@ func (o *rootOpts) prepareFlags(f *pflag.FlagSet) {
 	f.StringVar(&o.swName, "sw-name", os.Getenv("SOFTWARE_NAME"), "Name of the software")
 	f.StringVar(&o.swVersion, "sw-version", os.Getenv("SOFTWARE_VERSION"), "Version of the software")
 	f.StringVar(&o.swLicense, "sw-license", os.Getenv("SOFTWARE_LICENSE"), "License of the software")
+	f.StringVar(&o.device, "device", os.Getenv("DEVICE_SERIAL"), "Serial number of the issuing device")
 	f.BoolVarP(&o.production, "production", "p", false, "Production environment")
+	f.BoolVar(&o.inferZone, "infer-zone", false, "Infer the zone from the supplier's address when missing")
+	f.BoolVar(&o.aggregate, "aggregate-lines", false, "Merge lines with the same item, price and taxes when over the limit")
+}
+
+// zoneResolver provides the zone resolver to use according to the flags.
+func (o *rootOpts) zoneResolver() *ticketbai.ZoneResolver {
+	if o.inferZone {
+		return ticketbai.NewZoneResolver(ticketbai.WithAddressZone())
+	}
+	return ticketbai.NewZoneResolver()
+}
+
+// convertOptions provides the client options that affect conversion according
+// to the flags.
+func (o *rootOpts) convertOptions() []ticketbai.Option {
+	if o.aggregate {
+		return []ticketbai.Option{ticketbai.WithLineAggregation()}
+	}
+	return nil
 }
 
 func (o *rootOpts) software(zone l10n.Code) *ticketbai.Software {
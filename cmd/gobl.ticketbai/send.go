@@ -8,7 +8,7 @@ import (
 
 	"github.com/invopop/gobl"
 	ticketbai "github.com/invopop/gobl.ticketbai"
-	convert1 "github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/xmldsig"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +17,7 @@ type sendOpts struct {
 	*rootOpts
 
 	previous string
+	reissue  bool
 }
 
 func send(o *rootOpts) *sendOpts {
@@ -36,6 +37,7 @@ func (c *sendOpts) cmd() *cobra.Command {
 	c.prepareFlags(f)
 
 	f.StringVar(&c.previous, "prev", "", "Previous document fingerprint to chain with")
+	f.BoolVar(&c.reissue, "reissue", false, "Register again an invoice that was previously cancelled")
 
 	return cmd
 }
@@ -57,7 +59,8 @@ func (c *sendOpts) runE(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("unmarshaling gobl envelope: %w", err)
 	}
 
-	zone := ticketbai.ZoneFor(env)
+	zr := c.zoneResolver()
+	zone, _ := zr.ResolveEnvelope(env)
 	if zone == "" {
 		return fmt.Errorf("unable to determine zone")
 	}
@@ -70,7 +73,10 @@ func (c *sendOpts) runE(cmd *cobra.Command, args []string) error {
 	opts := []ticketbai.Option{
 		ticketbai.WithCertificate(cert),
 		ticketbai.WithThirdPartyIssuer(),
+		ticketbai.WithZoneResolver(zr),
+		ticketbai.WithDeviceSerial(c.device),
 	}
+	opts = append(opts, c.convertOptions()...)
 
 	if c.production {
 		opts = append(opts, ticketbai.InProduction())
@@ -83,14 +89,19 @@ func (c *sendOpts) runE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	td, err := tc.Convert(env)
+	var td *convert.TicketBAI
+	if c.reissue {
+		td, err = tc.Reissue(env)
+	} else {
+		td, err = tc.Convert(env)
+	}
 	if err != nil {
 		return err
 	}
 
-	var prev *convert1.ChainData
+	var prev *convert.ChainData
 	if c.previous != "" {
-		prev = new(convert1.ChainData)
+		prev = new(convert.ChainData)
 		if err := json.Unmarshal([]byte(c.previous), prev); err != nil {
 			return err
 		}
@@ -105,8 +116,7 @@ func (c *sendOpts) runE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	err = tc.Post(cmd.Context(), env, td)
-	if err != nil {
+	if _, err = tc.Post(cmd.Context(), env, td); err != nil {
 		return err
 	}
 
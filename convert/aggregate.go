@@ -0,0 +1,129 @@
+package convert
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/num"
+	"github.com/invopop/gobl/tax"
+)
+
+// maxDetailLines is the maximum number of detail lines accepted in Gipuzkoa
+// and Araba.
+const maxDetailLines = 1000
+
+// AggregatedLine describes a detail line resulting from merging several
+// invoice lines with the same item, price and taxes.
+type AggregatedLine struct {
+	Description string `json:"description"`
+	Price       string `json:"price"`
+	Quantity    string `json:"quantity"`
+	Lines       []int  `json:"lines"` // indexes of the merged GOBL lines
+}
+
+type lineGroup struct {
+	line     *bill.Line
+	quantity num.Amount
+	net      num.Amount
+	total    num.Amount
+	indexes  []int
+}
+
+// AggregatedLines returns the list of detail lines that were merged to keep
+// the document within the limit of detail lines, if any.
+func (doc *TicketBAI) AggregatedLines() []*AggregatedLine {
+	return doc.aggregated
+}
+
+// aggregateLines replaces the document's detail lines with lines merged by
+// item, price and taxes if over the limit. Line totals are added up after
+// rounding, so the sum of the details doesn't change.
+func (doc *TicketBAI) aggregateLines(inv *bill.Invoice) error {
+	if len(doc.Factura.DatosFactura.DetallesFactura.IDDetalleFactura) <= maxDetailLines {
+		return nil
+	}
+
+	groups := []*lineGroup{}
+	byKey := make(map[string]*lineGroup)
+	for _, line := range inv.Lines {
+		if line.Item.Price == nil {
+			continue
+		}
+		k := aggregationKey(line)
+		g, ok := byKey[k]
+		if !ok {
+			g = &lineGroup{
+				line:     line,
+				quantity: num.MakeAmount(0, 0),
+				net:      num.MakeAmount(0, 2),
+				total:    num.MakeAmount(0, 2),
+			}
+			byKey[k] = g
+			groups = append(groups, g)
+		}
+		g.quantity = g.quantity.Add(lineQuantity(line))
+		g.net = g.net.Add(line.Total.Rescale(2))
+		g.total = g.total.Add(calculateTotal(line).Rescale(2))
+		g.indexes = append(g.indexes, line.Index)
+	}
+
+	lines := make([]IDDetalleFactura, 0, len(groups)+len(inv.Discounts))
+	doc.aggregated = nil
+	for _, g := range groups {
+		price, discount, err := linePricing(g.quantity, lineUnitPrice(g.line), g.net)
+		if err != nil {
+			return validationErr("lines: %d: %s", g.line.Index, err)
+		}
+		lines = append(lines, IDDetalleFactura{
+			DescripcionDetalle: cleanText(g.line.Item.Name),
+			Cantidad:           g.quantity.String(),
+			ImporteUnitario:    price.String(),
+			Descuento:          discount.String(),
+			ImporteTotal:       g.total.String(),
+		})
+		if len(g.indexes) > 1 {
+			doc.aggregated = append(doc.aggregated, &AggregatedLine{
+				Description: g.line.Item.Name,
+				Price:       price.String(),
+				Quantity:    g.quantity.String(),
+				Lines:       g.indexes,
+			})
+		}
+	}
+	for _, d := range inv.Discounts {
+		lines = append(lines, newDiscountDetalle(d))
+	}
+
+	if len(lines) > maxDetailLines {
+		return validationErr("line count over limit (%d) for tax locality after aggregation", maxDetailLines)
+	}
+
+	doc.Factura.DatosFactura.DetallesFactura.IDDetalleFactura = lines
+	return nil
+}
+
+// aggregationKey identifies lines that can be merged together.
+func aggregationKey(line *bill.Line) string {
+	parts := []string{line.Item.Name, line.Item.Price.String()}
+	for _, c := range line.Taxes {
+		parts = append(parts, comboKey(c))
+	}
+	return strings.Join(parts, "|")
+}
+
+func comboKey(c *tax.Combo) string {
+	parts := []string{c.Category.String(), c.Rate.String()}
+	if c.Percent != nil {
+		parts = append(parts, c.Percent.String())
+	}
+	if c.Surcharge != nil {
+		parts = append(parts, c.Surcharge.String())
+	}
+	keys := make([]string, 0, len(c.Ext))
+	for k, v := range c.Ext {
+		keys = append(keys, k.String()+"="+v.String())
+	}
+	sort.Strings(keys)
+	return strings.Join(append(parts, keys...), ";")
+}
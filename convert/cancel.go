@@ -6,6 +6,7 @@ import (
 
 	"github.com/invopop/gobl/bill"
 	"github.com/invopop/gobl/l10n"
+	"github.com/invopop/gobl/org"
 	"github.com/invopop/xmldsig"
 )
 
@@ -35,22 +36,86 @@ type CabeceraAnulacionFactura struct {
 
 // NewAnulaTicketBAI creates a new AnulaTicketBAI document
 func NewAnulaTicketBAI(inv *bill.Invoice, ts time.Time) (*AnulaTicketBAI, error) {
-	doc := &AnulaTicketBAI{
+	doc := newAnulaTicketBAI(
+		newEmisor(inv.Supplier),
+		&CabeceraAnulacionFactura{
+			SerieFactura:           inv.Series.String(),
+			NumFactura:             inv.Code.String(),
+			FechaExpedicionFactura: formatDate(ts),
+		},
+	)
+
+	return doc, nil
+}
+
+// NewAnulaTicketBAIFromDocument creates a new AnulaTicketBAI document that
+// cancels the provided TicketBAI document. The series, number and issue date
+// are copied exactly from the original document's header, so this is the
+// preferred approach when the signed XML has been kept.
+func NewAnulaTicketBAIFromDocument(tbai *TicketBAI) (*AnulaTicketBAI, error) {
+	if tbai == nil ||
+		tbai.Sujetos == nil ||
+		tbai.Sujetos.Emisor == nil ||
+		tbai.Factura == nil ||
+		tbai.Factura.CabeceraFactura == nil {
+		return nil, validationErr("document to cancel is incomplete")
+	}
+	h := tbai.Factura.CabeceraFactura
+	if h.NumFactura == "" || h.FechaExpedicionFactura == "" {
+		return nil, validationErr("document to cancel is missing number or issue date")
+	}
+
+	doc := newAnulaTicketBAI(
+		&Emisor{
+			NIF:                        tbai.Sujetos.Emisor.NIF,
+			ApellidosNombreRazonSocial: tbai.Sujetos.Emisor.ApellidosNombreRazonSocial,
+		},
+		&CabeceraAnulacionFactura{
+			SerieFactura:           h.SerieFactura,
+			NumFactura:             h.NumFactura,
+			FechaExpedicionFactura: h.FechaExpedicionFactura,
+		},
+	)
+
+	return doc, nil
+}
+
+// NewAnulaTicketBAIFromChainData creates a new AnulaTicketBAI document for the
+// supplier using the chain data stored when the original document was issued.
+func NewAnulaTicketBAIFromChainData(supplier *org.Party, data *ChainData) (*AnulaTicketBAI, error) {
+	if supplier == nil || supplier.TaxID == nil {
+		return nil, validationErr("supplier with tax ID required")
+	}
+	if data == nil || data.Code == "" || data.IssueDate == "" {
+		return nil, validationErr("chain data is missing code or issue date")
+	}
+	if _, err := time.Parse("02-01-2006", data.IssueDate); err != nil {
+		return nil, validationErr("chain data issue date '%s' is invalid", data.IssueDate)
+	}
+
+	doc := newAnulaTicketBAI(
+		newEmisor(supplier),
+		&CabeceraAnulacionFactura{
+			SerieFactura:           data.Series,
+			NumFactura:             data.Code,
+			FechaExpedicionFactura: data.IssueDate,
+		},
+	)
+
+	return doc, nil
+}
+
+func newAnulaTicketBAI(emisor *Emisor, head *CabeceraAnulacionFactura) *AnulaTicketBAI {
+	return &AnulaTicketBAI{
 		TNamespace: ticketBAIAnulacionNamespace,
 		Cabecera: &Cabecera{
 			IDVersionTBAI: ticketBAIVersion,
 		},
 		IDFactura: &IDFactura{
-			Emisor: newEmisor(inv.Supplier),
-			CabeceraFactura: &CabeceraAnulacionFactura{
-				SerieFactura:           inv.Series.String(),
-				NumFactura:             inv.Code.String(),
-				FechaExpedicionFactura: formatDate(ts),
-			},
+			Emisor:          emisor,
+			CabeceraFactura: head,
 		},
 	}
-
-	return doc, nil
 }
 
 // IssueYear is a convenience method to extract the year of issue for headers.
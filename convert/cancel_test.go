@@ -0,0 +1,69 @@
+package convert_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelConversion(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339, "2022-02-01T04:00:00Z")
+	require.NoError(t, err)
+	role := convert.IssuerRoleThirdParty
+
+	t.Run("should build cancel from a previous document", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+		inv.Series = "SERIES"
+		inv.Code = "001"
+		tbai, err := convert.NewTicketBAI(inv, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		doc, err := convert.NewAnulaTicketBAIFromDocument(tbai)
+		require.NoError(t, err)
+
+		h := doc.IDFactura.CabeceraFactura
+		assert.Equal(t, "SERIES", h.SerieFactura)
+		assert.Equal(t, "001", h.NumFactura)
+		assert.Equal(t, "01-02-2022", h.FechaExpedicionFactura)
+		assert.Equal(t, tbai.Sujetos.Emisor.NIF, doc.IDFactura.Emisor.NIF)
+		assert.Equal(t, "2022", doc.IssueYear())
+	})
+
+	t.Run("should fail with an incomplete document", func(t *testing.T) {
+		_, err := convert.NewAnulaTicketBAIFromDocument(&convert.TicketBAI{})
+		assert.ErrorContains(t, err, "document to cancel is incomplete")
+	})
+
+	t.Run("should build cancel from chain data", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+
+		doc, err := convert.NewAnulaTicketBAIFromChainData(inv.Supplier, &convert.ChainData{
+			Series:    "AF",
+			Code:      "123",
+			IssueDate: "31-12-2021",
+			Signature: "ABC",
+		})
+		require.NoError(t, err)
+
+		h := doc.IDFactura.CabeceraFactura
+		assert.Equal(t, "AF", h.SerieFactura)
+		assert.Equal(t, "123", h.NumFactura)
+		assert.Equal(t, "31-12-2021", h.FechaExpedicionFactura)
+		assert.Equal(t, inv.Supplier.TaxID.Code.String(), doc.IDFactura.Emisor.NIF)
+		assert.Equal(t, "2021", doc.IssueYear())
+	})
+
+	t.Run("should fail with invalid chain data date", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+
+		_, err := convert.NewAnulaTicketBAIFromChainData(inv.Supplier, &convert.ChainData{
+			Code:      "123",
+			IssueDate: "2021-12-31",
+		})
+		assert.ErrorContains(t, err, "issue date '2021-12-31' is invalid")
+	})
+}
@@ -0,0 +1,200 @@
+package convert
+
+import (
+	"sort"
+
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/regimes/es"
+	"github.com/invopop/gobl/tax"
+)
+
+// maxClaves is the maximum number of keys allowed by TicketBAI.
+const maxClaves = 3
+
+// Regime keys (ClaveRegimenIvaOpTrascendencia) defined by TicketBAI.
+const (
+	ClaveGeneral              = "01" // Régimen general
+	ClaveExport               = "02" // Exportación
+	ClaveSecondHandGoods      = "03" // Bienes usados, objetos de arte, antigüedades y colección
+	ClaveInvestmentGold       = "04" // Oro de inversión
+	ClaveTravelAgency         = "05" // Agencias de viajes
+	ClaveVATGroup             = "06" // Grupo de entidades en IVA (nivel avanzado)
+	ClaveCashBasis            = "07" // Criterio de caja
+	ClaveIPSIIGIC             = "08" // Operaciones sujetas al IPSI / IGIC
+	ClaveTravelAgencyMediator = "09" // Agencias de viajes mediadoras por cuenta ajena
+	ClaveThirdPartyCollection = "10" // Cobros por cuenta de terceros
+	ClaveRentalRetained       = "11" // Arrendamiento de local sujeto a retención
+	ClaveRentalNotRetained    = "12" // Arrendamiento de local no sujeto a retención
+	ClaveRentalMixed          = "13" // Arrendamiento de local sujeto y no sujeto a retención
+	ClavePublicCertification  = "14" // IVA pendiente en certificaciones de obra a la Administración
+	ClaveSuccessiveSupply     = "15" // IVA pendiente en operaciones de tracto sucesivo
+	ClaveOSS                  = "17" // Regímenes del Capítulo XI del Título IX (OSS e IOSS)
+	ClaveAgriculture          = "19" // Régimen especial de agricultura, ganadería y pesca
+	ClaveSurcharge            = "51" // Recargo de equivalencia
+	ClaveSimplified           = "52" // Régimen simplificado
+)
+
+var validClaves = []cbc.Code{
+	ClaveGeneral, ClaveExport, ClaveSecondHandGoods, ClaveInvestmentGold,
+	ClaveTravelAgency, ClaveVATGroup, ClaveCashBasis, ClaveIPSIIGIC,
+	ClaveTravelAgencyMediator, ClaveThirdPartyCollection, ClaveRentalRetained,
+	ClaveRentalNotRetained, ClaveRentalMixed, ClavePublicCertification,
+	ClaveSuccessiveSupply, ClaveOSS, ClaveAgriculture, ClaveSurcharge,
+	ClaveSimplified,
+}
+
+// tagClaves maps the GOBL scheme tags to the key they imply.
+var tagClaves = []struct {
+	tag   cbc.Key
+	clave string
+}{
+	{es.TagSecondHandGoods, ClaveSecondHandGoods},
+	{es.TagArt, ClaveSecondHandGoods},
+	{es.TagAntiques, ClaveSecondHandGoods},
+	{es.TagTravelAgency, ClaveTravelAgency},
+	{es.TagCashBasis, ClaveCashBasis},
+	{es.TagSimplifiedScheme, ClaveSimplified},
+}
+
+// NewClaves provides the regime keys that apply to the invoice, as also
+// required by the LROE records of received invoices, along with any extra
+// keys that cannot be determined from the invoice.
+func NewClaves(inv *bill.Invoice, extra ...string) (*Claves, error) {
+	claves, err := newClaves(inv, extra)
+	if err != nil {
+		return nil, err
+	}
+	return &Claves{IDClave: claves}, nil
+}
+
+func newClaves(inv *bill.Invoice, extra []string) ([]IDClave, error) {
+	keys := make(map[string]bool)
+
+	for _, code := range extra {
+		if !cbc.Code(code).In(validClaves...) {
+			return nil, validationErr("claves: invalid key '%s'", code)
+		}
+		keys[code] = true
+	}
+
+	if inv.Customer != nil && partyCountry(inv.Customer) != "ES" {
+		keys[ClaveExport] = true
+	}
+	for _, tc := range tagClaves {
+		if inv.HasTags(tc.tag) {
+			keys[tc.clave] = true
+		}
+	}
+	if hasCategory(inv, es.TaxCategoryIGIC) || hasCategory(inv, es.TaxCategoryIPSI) {
+		keys[ClaveIPSIIGIC] = true
+	}
+	if hasSurchargedLines(inv) {
+		keys[ClaveSurcharge] = true
+	}
+
+	if len(keys) == 0 {
+		keys[ClaveGeneral] = true
+	}
+	if len(keys) > maxClaves {
+		return nil, validationErr("claves: too many regime keys (%d), max %d", len(keys), maxClaves)
+	}
+
+	codes := make([]string, 0, len(keys))
+	for k := range keys {
+		codes = append(codes, k)
+	}
+	sort.Strings(codes)
+
+	claves := make([]IDClave, len(codes))
+	for i, code := range codes {
+		if err := validateClave(inv, code); err != nil {
+			return nil, err
+		}
+		claves[i] = IDClave{ClaveRegimenIvaOpTrascendencia: code}
+	}
+
+	return claves, nil
+}
+
+// validateClave checks that the key is consistent with the operations that
+// will be included in the invoice's breakdown.
+func validateClave(inv *bill.Invoice, code string) error {
+	ti := newTaxInfo(inv)
+	switch code {
+	case ClaveExport:
+		if inv.Customer == nil || partyCountry(inv.Customer) == "ES" {
+			if !hasVATRate(inv, func(r *tax.RateTotal) bool {
+				return ti.isExenta(r) && r.Ext.Get(tbai.ExtKeyExempt) == "E2"
+			}) {
+				return validationErr("claves: key %s requires a foreign customer or operations exempt under E2", code)
+			}
+		}
+	case ClaveIPSIIGIC:
+		if !hasCategory(inv, es.TaxCategoryIGIC) && !hasCategory(inv, es.TaxCategoryIPSI) &&
+			!hasVATRate(inv, ti.isNoSujeta) {
+			return validationErr("claves: key %s requires IPSI, IGIC or not subject operations", code)
+		}
+	case ClaveRentalRetained, ClaveRentalMixed:
+		if !hasRetainedTaxes(inv) {
+			return validationErr("claves: key %s requires retained taxes", code)
+		}
+	case ClaveRentalNotRetained:
+		if hasRetainedTaxes(inv) {
+			return validationErr("claves: key %s cannot be used with retained taxes", code)
+		}
+	case ClaveOSS:
+		if !hasVATRate(inv, func(r *tax.RateTotal) bool {
+			return ti.isNoSujeta(r) && ti.causaNoSujeta(r) == "RL"
+		}) {
+			return validationErr("claves: key %s requires operations not subject by localisation rules (RL)", code)
+		}
+	case ClaveSurcharge:
+		if !hasSurchargedLines(inv) {
+			return validationErr("claves: key %s requires lines with ext '%s' set to 'resale'", code, tbai.ExtKeyProduct)
+		}
+	}
+	return nil
+}
+
+func hasCategory(inv *bill.Invoice, cat cbc.Code) bool {
+	if inv.Totals == nil || inv.Totals.Taxes == nil {
+		return false
+	}
+	return inv.Totals.Taxes.Category(cat) != nil
+}
+
+func hasRetainedTaxes(inv *bill.Invoice) bool {
+	if inv.Totals == nil || inv.Totals.Taxes == nil {
+		return false
+	}
+	for _, category := range inv.Totals.Taxes.Categories {
+		if category.Retained {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVATRate(inv *bill.Invoice, match func(*tax.RateTotal) bool) bool {
+	if inv.Totals == nil || inv.Totals.Taxes == nil {
+		return false
+	}
+	vat := inv.Totals.Taxes.Category(tax.CategoryVAT)
+	if vat == nil {
+		return false
+	}
+	for _, rate := range vat.Rates {
+		if match(rate) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSurchargedLines(inv *bill.Invoice) bool {
+	return hasVATRate(inv, func(r *tax.RateTotal) bool {
+		return r.Ext.Get(tbai.ExtKeyProduct) == "resale"
+	})
+}
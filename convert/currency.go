@@ -0,0 +1,33 @@
+package convert
+
+import (
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/currency"
+)
+
+// inEuros returns a version of the invoice with all amounts in euros, the
+// only currency accepted by TicketBAI. Invoices issued in other currencies
+// are converted using the exchange rates included in the invoice, which are
+// then recalculated by GOBL so that rounding remains consistent.
+func inEuros(inv *bill.Invoice) (*bill.Invoice, error) {
+	if inv.Currency == currency.CodeEmpty || inv.Currency == currency.EUR {
+		return inv, nil
+	}
+	if !hasExchangeRate(inv.ExchangeRates, inv.Currency, currency.EUR) {
+		return nil, validationErr("exchange_rates: missing rate from '%s' to '%s'", inv.Currency, currency.EUR)
+	}
+	out, err := inv.ConvertInto(currency.EUR)
+	if err != nil {
+		return nil, validationErr("currency: %s", err.Error())
+	}
+	return out, nil
+}
+
+func hasExchangeRate(rates []*currency.ExchangeRate, from, to currency.Code) bool {
+	for _, r := range rates {
+		if r != nil && r.From == from && r.To == to {
+			return true
+		}
+	}
+	return false
+}
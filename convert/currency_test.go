@@ -0,0 +1,62 @@
+package convert_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/currency"
+	"github.com/invopop/gobl/num"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyConversion(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339, "2022-02-01T04:00:00Z")
+	require.NoError(t, err)
+	role := convert.IssuerRoleThirdParty
+
+	t.Run("should convert amounts to euros", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+		inv.Currency = currency.USD
+		inv.ExchangeRates = []*currency.ExchangeRate{
+			{From: currency.USD, To: currency.EUR, Amount: num.MakeAmount(2, 0)},
+		}
+
+		doc, err := convert.NewTicketBAI(inv, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		line := doc.Factura.DatosFactura.DetallesFactura.IDDetalleFactura[0]
+		assert.Equal(t, "200.00", line.ImporteUnitario)
+		assert.Equal(t, "200.00", line.Descuento)
+		assert.Equal(t, "2178.00", line.ImporteTotal)
+		assert.Equal(t, "2178.00", doc.Factura.DatosFactura.ImporteTotalFactura)
+		assert.Equal(t, "270.00", doc.Factura.DatosFactura.RetencionSoportada)
+
+		diva := doc.Factura.TipoDesglose.DesgloseFactura.Sujeta.NoExenta.DetalleNoExenta[0].DesgloseIVA.DetalleIVA[0]
+		assert.Equal(t, "1800.00", diva.BaseImponible)
+		assert.Equal(t, "378.00", diva.CuotaImpuesto)
+		assert.Equal(t, "93.60", diva.CuotaRecargoEquivalencia)
+	})
+
+	t.Run("should not modify the original invoice", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+		inv.Currency = currency.USD
+		inv.ExchangeRates = []*currency.ExchangeRate{
+			{From: currency.USD, To: currency.EUR, Amount: num.MakeAmount(2, 0)},
+		}
+
+		_, err := convert.NewTicketBAI(inv, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+		assert.Equal(t, currency.USD, inv.Currency)
+	})
+
+	t.Run("should reject foreign currencies without a rate", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+		inv.Currency = currency.GBP
+
+		_, err := convert.NewTicketBAI(inv, ts, role, convert.ZoneBI)
+		assert.ErrorContains(t, err, "missing rate from 'GBP' to 'EUR'")
+	})
+}
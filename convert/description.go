@@ -0,0 +1,140 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/org"
+)
+
+// maxTextLength is the maximum number of characters allowed in the
+// description of the invoice and its lines.
+const maxTextLength = 250
+
+// DescriptionSource provides the text to use as the description of the
+// invoice (DescripcionFactura). An empty string implies that the source has
+// no description for the invoice and the next one should be tried.
+type DescriptionSource func(inv *bill.Invoice) (string, error)
+
+// DescriptionFromNotes uses the text of the first invoice note with one of
+// the provided keys, or the general note if none are provided.
+func DescriptionFromNotes(keys ...cbc.Key) DescriptionSource {
+	if len(keys) == 0 {
+		keys = []cbc.Key{org.NoteKeyGeneral}
+	}
+	return func(inv *bill.Invoice) (string, error) {
+		for _, key := range keys {
+			for _, note := range inv.Notes {
+				if note != nil && note.Key == key {
+					return note.Text, nil
+				}
+			}
+		}
+		return "", nil
+	}
+}
+
+// DescriptionFromLines summarises the names of the items in the invoice's
+// lines.
+func DescriptionFromLines() DescriptionSource {
+	return func(inv *bill.Invoice) (string, error) {
+		names := []string{}
+		seen := make(map[string]bool)
+		for _, line := range inv.Lines {
+			if line.Item == nil || line.Item.Name == "" || seen[line.Item.Name] {
+				continue
+			}
+			seen[line.Item.Name] = true
+			names = append(names, line.Item.Name)
+		}
+		return strings.Join(names, ", "), nil
+	}
+}
+
+// DescriptionFromTemplate executes the template with the invoice to generate
+// the description, for example:
+//
+//	Factura {{.Series}}-{{.Code}} de {{.Supplier.Name}}
+func DescriptionFromTemplate(tmpl *template.Template) DescriptionSource {
+	return func(inv *bill.Invoice) (string, error) {
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, inv); err != nil {
+			return "", validationErr("description: %s", err.Error())
+		}
+		return buf.String(), nil
+	}
+}
+
+// DescriptionForSupplier only uses the source for invoices issued by the
+// supplier with the given NIF.
+func DescriptionForSupplier(nif string, src DescriptionSource) DescriptionSource {
+	nif = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(nif)), "ES")
+	return func(inv *bill.Invoice) (string, error) {
+		if inv.Supplier == nil || inv.Supplier.TaxID == nil || inv.Supplier.TaxID.Code.String() != nif {
+			return "", nil
+		}
+		return src(inv)
+	}
+}
+
+// NewDescription provides the description of the invoice from the first of
+// the sources with some text, falling back to the general note, which is
+// then required. The text is cleaned and truncated as in DescripcionFactura.
+func NewDescription(inv *bill.Invoice, sources ...DescriptionSource) (string, error) {
+	return newDescription(inv, sources)
+}
+
+// newDescription tries each of the description sources in order, falling
+// back to the general note, which is then required.
+func newDescription(inv *bill.Invoice, sources []DescriptionSource) (string, error) {
+	sources = append(sources[:len(sources):len(sources)], DescriptionFromNotes())
+	for _, src := range sources {
+		text, err := src(inv)
+		if err != nil {
+			return "", err
+		}
+		if text = cleanText(text); text != "" {
+			return text, nil
+		}
+	}
+	return "", validationErr(`notes: missing note with key '%s'`, org.NoteKeyGeneral)
+}
+
+// cleanText removes the characters that are not allowed in XML documents,
+// collapses whitespace and truncates the text to the maximum length allowed
+// by TicketBAI.
+func cleanText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError:
+			return -1
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), r == 0xFFFE, r == 0xFFFF:
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.Join(strings.Fields(text), " ")
+
+	return truncateText(text, maxTextLength)
+}
+
+// truncateText shortens the text to max characters, preferably on a word
+// boundary, adding an ellipsis to show that it was truncated.
+func truncateText(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)[:max-1]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ") + "…"
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
 	"github.com/invopop/gobl/l10n"
 	"github.com/invopop/xmldsig"
 )
@@ -64,7 +65,8 @@ type TicketBAI struct {
 	HuellaTBAI *HuellaTBAI        // Fingerprint
 	Signature  *xmldsig.Signature `xml:"ds:Signature,omitempty"` // XML Signature
 
-	ts time.Time
+	ts         time.Time
+	aggregated []*AggregatedLine
 }
 
 // Cabecera defines the document head with TBAI version ID.
@@ -74,8 +76,15 @@ type Cabecera struct {
 
 // NewTicketBAI takes the GOBL Invoice and converts into a TicketBAI document
 // ready to send to a regional API.
-func NewTicketBAI(inv *bill.Invoice, ts time.Time, role IssuerRole, zone l10n.Code) (*TicketBAI, error) {
-	err := validate(inv, zone)
+func NewTicketBAI(inv *bill.Invoice, ts time.Time, role IssuerRole, zone l10n.Code, opts ...Option) (*TicketBAI, error) {
+	o := newOptions(opts)
+
+	err := validate(inv, zone, o)
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err = inEuros(inv)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +110,7 @@ func NewTicketBAI(inv *bill.Invoice, ts time.Time, role IssuerRole, zone l10n.Co
 			EmitidaPorTercerosODestinatario: string(role),
 		},
 		Factura: &Factura{
-			CabeceraFactura: newCabeceraFactura(inv),
+			CabeceraFactura: newCabeceraFactura(inv, o),
 			TipoDesglose:    newTipoDesglose(inv),
 		},
 	}
@@ -109,22 +118,23 @@ func NewTicketBAI(inv *bill.Invoice, ts time.Time, role IssuerRole, zone l10n.Co
 	doc.SetIssueTimestamp(ts)
 
 	// Add customers
-	if inv.Customer != nil {
-		// If the customer is still nil, implies that they didn't have enough
-		// fiscal information to include in the output.
-		if dest := newDestinatario(inv.Customer); dest != nil {
-			doc.Sujetos.Destinatarios = &Destinatarios{
-				IDDestinatario: []*IDDestinatario{dest},
-			}
-		}
+	doc.Sujetos.Destinatarios, err = newDestinatarios(inv, o)
+	if err != nil {
+		return nil, err
 	}
 
 	// Complete invoice data
-	doc.Factura.DatosFactura, err = newDatosFactura(inv)
+	doc.Factura.DatosFactura, err = newDatosFactura(inv, o)
 	if err != nil {
 		return nil, err
 	}
 
+	if o.aggregateLines && zone.In(ZoneSS, ZoneVI) {
+		if err := doc.aggregateLines(inv); err != nil {
+			return nil, err
+		}
+	}
+
 	return doc, nil
 }
 
@@ -259,6 +269,22 @@ func formatDate(ts timeLocationable) string {
 	return ts.In(location).Format("02-01-2006")
 }
 
+// FormatDate provides the date in the format used by TicketBAI and the LROE
+// records (DD-MM-YYYY).
+func FormatDate(d cal.Date) string {
+	return formatDate(d)
+}
+
+// ParseDate reverses FormatDate, providing the date of a TicketBAI or LROE
+// record.
+func ParseDate(date string) (cal.Date, error) {
+	ts, err := time.Parse("02-01-2006", date)
+	if err != nil {
+		return cal.Date{}, fmt.Errorf("parsing date: %w", err)
+	}
+	return cal.DateOf(ts), nil
+}
+
 func formatTime(ts timeLocationable) string {
 	return ts.In(location).Format("15:04:05")
 }
@@ -86,6 +86,48 @@ func TestInvoiceConversion(t *testing.T) {
 		assert.Equal(t, "Abroad Co LLC", invoice.Sujetos.Destinatarios.IDDestinatario[0].ApellidosNombreRazonSocial)
 	})
 
+	t.Run("should include the people of the customer as co-recipients", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Customer.People = []*org.Person{
+			{
+				Name:       &org.Name{Given: "Ane", Surname: "Etxeberria", Surname2: "Garcia"},
+				Identities: []*org.Identity{{Type: "NIF", Code: "12345678Z"}},
+			},
+			{
+				Name:       &org.Name{Given: "John", Surname: "Smith"},
+				Identities: []*org.Identity{{Key: org.IdentityKeyPassport, Country: "GB", Code: "123456789"}},
+			},
+		}
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+		assert.Len(t, invoice.Sujetos.Destinatarios.IDDestinatario, 1)
+
+		invoice, err = convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithCoRecipients())
+		require.NoError(t, err)
+
+		dests := invoice.Sujetos.Destinatarios.IDDestinatario
+		require.Len(t, dests, 3)
+		assert.Equal(t, "12345678Z", dests[1].NIF)
+		assert.Equal(t, "Etxeberria Garcia Ane", dests[1].ApellidosNombreRazonSocial)
+		assert.Equal(t, dests[0].CodigoPostal, dests[1].CodigoPostal)
+		assert.Empty(t, dests[2].NIF)
+		assert.Equal(t, "03", dests[2].IDOtro.IDType)
+		assert.Equal(t, "GB", dests[2].IDOtro.CodigoPais)
+		assert.Equal(t, "123456789", dests[2].IDOtro.ID)
+		assert.Equal(t, "Smith John", dests[2].ApellidosNombreRazonSocial)
+	})
+
+	t.Run("should fail with co-recipients without identity", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Customer.People = []*org.Person{
+			{Name: &org.Name{Given: "Ane", Surname: "Etxeberria"}},
+		}
+
+		_, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithCoRecipients())
+		assert.ErrorContains(t, err, "customer: people: 0: missing identity")
+	})
+
 	t.Run("should not include customer if no tax ID present", func(t *testing.T) {
 		goblInvoice := test.LoadInvoice("sample-invoice.json")
 		goblInvoice.Customer.TaxID = nil
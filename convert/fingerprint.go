@@ -4,6 +4,7 @@ package convert
 type HuellaTBAI struct {
 	EncadenamientoFacturaAnterior *EncadenamientoFacturaAnterior `xml:",omitempty"`
 	Software                      *Software
+	NumSerieDispositivo           string `xml:",omitempty"` // max 30
 }
 
 // EncadenamientoFacturaAnterior has the info of the previous invoice generated
@@ -24,12 +25,15 @@ type ChainData struct {
 	Signature string `json:"signature"` // first 100 characters
 }
 
-// Software used to generate the Ticketbai invoice
+// Software used to generate the Ticketbai invoice. The developer company
+// is identified either by its Spanish NIF or, if it doesn't have one, by
+// an IDOtro identity.
 type Software struct {
-	License string `xml:"LicenciaTBAI"`
-	NIF     string `xml:"EntidadDesarrolladora>NIF"`
-	Name    string `xml:"Nombre"`
-	Version string `xml:"Version"`
+	License string  `xml:"LicenciaTBAI"`
+	NIF     string  `xml:"EntidadDesarrolladora>NIF,omitempty"`
+	IDOtro  *IDOtro `xml:"EntidadDesarrolladora>IDOtro,omitempty"`
+	Name    string  `xml:"Nombre"`
+	Version string  `xml:"Version"`
 }
 
 func newHuellaTBAI(soft *Software, data *ChainData) *HuellaTBAI {
@@ -77,3 +77,51 @@ func TestFingerprintGeneration(t *testing.T) {
 		assert.Equal(t, strings.Repeat("1234567890", 10), chaining.SignatureValueFirmaFacturaAnterior)
 	})
 }
+
+func TestFingerprintSoftwareIdentity(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339, "2022-02-01T04:00:00Z")
+	require.NoError(t, err)
+
+	newDoc := func(t *testing.T) *convert.TicketBAI {
+		t.Helper()
+		inv := test.LoadInvoice("sample-invoice.json")
+		doc, err := convert.NewTicketBAI(inv, ts, convert.IssuerRoleThirdParty, convert.ZoneBI)
+		require.NoError(t, err)
+		return doc
+	}
+
+	t.Run("should identify developer by NIF", func(t *testing.T) {
+		doc := newDoc(t)
+		require.NoError(t, doc.Fingerprint(&convert.Software{
+			License: "12345",
+			NIF:     "12345678A",
+			Name:    "My Software",
+			Version: "1.0",
+		}, nil))
+
+		data, err := doc.Bytes()
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "<EntidadDesarrolladora><NIF>12345678A</NIF></EntidadDesarrolladora>")
+		assert.NotContains(t, string(data), "NumSerieDispositivo")
+	})
+
+	t.Run("should identify foreign developer and device", func(t *testing.T) {
+		doc := newDoc(t)
+		require.NoError(t, doc.Fingerprint(&convert.Software{
+			License: "12345",
+			IDOtro: &convert.IDOtro{
+				CodigoPais: "FR",
+				IDType:     "02",
+				ID:         "FR12345678901",
+			},
+			Name:    "My Software",
+			Version: "1.0",
+		}, nil))
+		doc.HuellaTBAI.NumSerieDispositivo = "TILL-0001"
+
+		data, err := doc.Bytes()
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "<EntidadDesarrolladora><IDOtro><CodigoPais>FR</CodigoPais><IDType>02</IDType><ID>FR12345678901</ID></IDOtro></EntidadDesarrolladora>")
+		assert.Contains(t, string(data), "</Software><NumSerieDispositivo>TILL-0001</NumSerieDispositivo></HuellaTBAI>")
+	})
+}
@@ -4,11 +4,13 @@ import (
 	"github.com/invopop/gobl/addons/es/tbai"
 	"github.com/invopop/gobl/bill"
 	"github.com/invopop/gobl/num"
-	"github.com/invopop/gobl/org"
-	"github.com/invopop/gobl/regimes/es"
 	"github.com/invopop/gobl/tax"
 )
 
+// maxSubstitutedInvoices is the maximum number of invoices that can be
+// referenced by a corrective or substitution invoice.
+const maxSubstitutedInvoices = 100
+
 // Factura contains the invoice info
 type Factura struct {
 	CabeceraFactura *CabeceraFactura
@@ -18,13 +20,14 @@ type Factura struct {
 
 // CabeceraFactura contains info about the invoice header
 type CabeceraFactura struct {
-	SerieFactura                    string `xml:",omitempty"`
-	NumFactura                      string
-	FechaExpedicionFactura          string
-	HoraExpedicionFactura           string
-	FacturaSimplificada             string
-	FacturaRectificativa            *FacturaRectificativa            `xml:",omitempty"`
-	FacturasRectificadasSustituidas *FacturasRectificadasSustituidas `xml:",omitempty"`
+	SerieFactura                          string `xml:",omitempty"`
+	NumFactura                            string
+	FechaExpedicionFactura                string
+	HoraExpedicionFactura                 string
+	FacturaSimplificada                   string
+	FacturaEmitidaSustitucionSimplificada string                           `xml:",omitempty"`
+	FacturaRectificativa                  *FacturaRectificativa            `xml:",omitempty"`
+	FacturasRectificadasSustituidas       *FacturasRectificadasSustituidas `xml:",omitempty"`
 }
 
 // DatosFactura contains info about the invoice description
@@ -50,8 +53,19 @@ type IDClave struct {
 
 // FacturaRectificativa contains the info a corrective invoice
 type FacturaRectificativa struct {
-	Codigo string
-	Tipo   string
+	Codigo                          string
+	Tipo                            string
+	ImporteRectificacionSustitutiva *ImporteRectificacionSustitutiva `xml:",omitempty"`
+}
+
+// ImporteRectificacionSustitutiva contains the totals of the invoices replaced
+// by a corrective invoice by substitution. Documents generated from GOBL
+// never include it, as only corrections by differences are supported, but it
+// is kept when parsing documents generated elsewhere.
+type ImporteRectificacionSustitutiva struct {
+	BaseRectificada         string
+	CuotaRectificada        string
+	CuotaRecargoRectificada string `xml:",omitempty"`
 }
 
 // FacturasRectificadasSustituidas contains the info of all the invoices corrected or substituted in
@@ -68,12 +82,22 @@ type IDFacturaRectificadaSustituida struct {
 	FechaExpedicionFactura string
 }
 
-func newCabeceraFactura(inv *bill.Invoice) *CabeceraFactura {
+func newCabeceraFactura(inv *bill.Invoice, o *options) *CabeceraFactura {
 	simplifiedInvoice := "N"
 	if inv.HasTags(tax.TagSimplified) {
 		simplifiedInvoice = "S"
 	}
 
+	if o.simplifiedSubstitution {
+		return &CabeceraFactura{
+			SerieFactura:                          inv.Series.String(),
+			NumFactura:                            inv.Code.String(),
+			FacturaSimplificada:                   simplifiedInvoice,
+			FacturaEmitidaSustitucionSimplificada: "S",
+			FacturasRectificadasSustituidas:       newFacturasSustituidas(inv),
+		}
+	}
+
 	return &CabeceraFactura{
 		SerieFactura:                    inv.Series.String(),
 		NumFactura:                      inv.Code.String(),
@@ -83,15 +107,23 @@ func newCabeceraFactura(inv *bill.Invoice) *CabeceraFactura {
 	}
 }
 
-func newDatosFactura(inv *bill.Invoice) (*DatosFactura, error) {
-	description, err := newDescription(inv.Notes)
+func newDatosFactura(inv *bill.Invoice, o *options) (*DatosFactura, error) {
+	description, err := newDescription(inv, o.descriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	claves, err := newClaves(inv, o.regimeKeys)
 	if err != nil {
 		return nil, err
 	}
 
 	// This is only needed on Guipuzcoa and Alava, but Vizcaya documentation
 	// states that it will be safely ignored so it will be added for everyone
-	lineDetails := newDetallesFactura(inv)
+	lineDetails, err := newDetallesFactura(inv)
+	if err != nil {
+		return nil, err
+	}
 
 	opDate := inv.OperationDate
 	if opDate == nil {
@@ -105,19 +137,10 @@ func newDatosFactura(inv *bill.Invoice) (*DatosFactura, error) {
 		DetallesFactura:     lineDetails,
 		ImporteTotalFactura: newImporteTotal(inv),
 		RetencionSoportada:  newRetencionSoportada(inv),
-		Claves:              &Claves{IDClave: newClaves(inv)},
+		Claves:              &Claves{IDClave: claves},
 	}, nil
 }
 
-func newDescription(notes []*org.Note) (string, error) {
-	for _, note := range notes {
-		if note.Key == org.NoteKeyGeneral {
-			return note.Text, nil
-		}
-	}
-	return "", validationErr(`notes: missing note with key '%s'`, org.NoteKeyGeneral)
-}
-
 func newImporteTotal(inv *bill.Invoice) string {
 	totalWithDiscounts := inv.Totals.Total
 
@@ -146,36 +169,6 @@ func newRetencionSoportada(inv *bill.Invoice) string {
 	return totalRetention.String()
 }
 
-func newClaves(inv *bill.Invoice) []IDClave {
-	claves := []IDClave{}
-
-	if inv.Customer != nil && partyCountry(inv.Customer) != "ES" {
-		claves = append(claves, IDClave{
-			ClaveRegimenIvaOpTrascendencia: "02",
-		})
-	}
-
-	if hasSurchargedLines(inv) {
-		claves = append(claves, IDClave{
-			ClaveRegimenIvaOpTrascendencia: "51",
-		})
-	}
-
-	if underSimplifiedRegime(inv) {
-		claves = append(claves, IDClave{
-			ClaveRegimenIvaOpTrascendencia: "52",
-		})
-	}
-
-	if len(claves) == 0 {
-		claves = append(claves, IDClave{
-			ClaveRegimenIvaOpTrascendencia: "01",
-		})
-	}
-
-	return claves
-}
-
 func newFacturaRectificativa(inv *bill.Invoice) *FacturaRectificativa {
 	if len(inv.Preceding) == 0 {
 		return nil
@@ -207,24 +200,18 @@ func newFacturasRectificadasSustituidas(inv *bill.Invoice) *FacturasRectificadas
 	}
 }
 
-func hasSurchargedLines(inv *bill.Invoice) bool {
-	if inv.Totals == nil || inv.Totals.Taxes == nil {
-		return false
-	}
-	vat := inv.Totals.Taxes.Category(tax.CategoryVAT)
-	if vat == nil {
-		return false
+// newFacturasSustituidas lists all the simplified invoices replaced by the
+// invoice.
+func newFacturasSustituidas(inv *bill.Invoice) *FacturasRectificadasSustituidas {
+	ids := make([]*IDFacturaRectificadaSustituida, 0, len(inv.Preceding))
+	for _, p := range inv.Preceding {
+		ids = append(ids, &IDFacturaRectificadaSustituida{
+			SerieFactura:           p.Series.String(),
+			NumFactura:             p.Code.String(),
+			FechaExpedicionFactura: formatDate(p.IssueDate),
+		})
 	}
-
-	for _, rate := range vat.Rates {
-		if rate.Ext.Get(tbai.ExtKeyProduct) == "resale" {
-			return true
-		}
+	return &FacturasRectificadasSustituidas{
+		IDFacturaRectificadaSustituida: ids,
 	}
-
-	return false
-}
-
-func underSimplifiedRegime(inv *bill.Invoice) bool {
-	return inv.HasTags(es.TagSimplifiedScheme)
 }
@@ -1,8 +1,11 @@
 package convert_test
 
 import (
+	"strings"
 	"testing"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl.ticketbai/test"
@@ -54,6 +57,43 @@ func TestFacturaConversion(t *testing.T) {
 		assert.Equal(t, "S", factura.CabeceraFactura.FacturaSimplificada)
 	})
 
+	t.Run("should reference the simplified invoices being replaced", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Preceding = []*org.DocumentRef{
+			{Series: "T", Code: "0001", IssueDate: cal.NewDate(2022, 1, 30)},
+			{Series: "T", Code: "0002", IssueDate: cal.NewDate(2022, 1, 31)},
+		}
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithSimplifiedSubstitution())
+		require.NoError(t, err)
+
+		cabecera := invoice.Factura.CabeceraFactura
+		assert.Equal(t, "N", cabecera.FacturaSimplificada)
+		assert.Equal(t, "S", cabecera.FacturaEmitidaSustitucionSimplificada)
+		assert.Nil(t, cabecera.FacturaRectificativa)
+		ids := cabecera.FacturasRectificadasSustituidas.IDFacturaRectificadaSustituida
+		require.Len(t, ids, 2)
+		assert.Equal(t, "T", ids[1].SerieFactura)
+		assert.Equal(t, "0002", ids[1].NumFactura)
+		assert.Equal(t, "31-01-2022", ids[1].FechaExpedicionFactura)
+	})
+
+	t.Run("should require the simplified invoices being replaced", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+
+		_, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithSimplifiedSubstitution())
+		assert.ErrorContains(t, err, "preceding simplified invoices are required")
+	})
+
+	t.Run("should not mark regular invoices as substitutions", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		assert.Empty(t, invoice.Factura.CabeceraFactura.FacturaEmitidaSustitucionSimplificada)
+	})
+
 	t.Run("should fill invoice operation date", func(t *testing.T) {
 		goblInvoice := test.LoadInvoice("sample-invoice.json")
 		goblInvoice.OperationDate = cal.NewDate(2022, 3, 15)
@@ -85,6 +125,65 @@ func TestFacturaConversion(t *testing.T) {
 		assert.ErrorContains(t, err, "notes: missing note with key 'general'")
 	})
 
+	t.Run("should use the description sources in order", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Notes = nil
+		goblInvoice.Lines[0].Item.Name = "Fuel"
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI,
+			convert.WithDescription(convert.DescriptionFromNotes(), convert.DescriptionFromLines()),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "Fuel", invoice.Factura.DatosFactura.DescripcionFactura)
+	})
+
+	t.Run("should use a template for the supplier", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		tmpl := template.Must(template.New("desc").Parse("Invoice {{.Series}}-{{.Code}}"))
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI,
+			convert.WithDescription(
+				convert.DescriptionForSupplier("ES"+goblInvoice.Supplier.TaxID.Code.String(), convert.DescriptionFromTemplate(tmpl)),
+			),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "Invoice "+goblInvoice.Series.String()+"-"+goblInvoice.Code.String(), invoice.Factura.DatosFactura.DescripcionFactura)
+
+		goblInvoice.Supplier.TaxID.Code = "B98602642"
+		_, err = convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI,
+			convert.WithDescription(
+				convert.DescriptionForSupplier("A99805194", convert.DescriptionFromTemplate(tmpl)),
+			),
+		)
+		assert.ErrorContains(t, err, "missing note with key 'general'")
+	})
+
+	t.Run("should fall back to the general note", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Lines[0].Item.Name = ""
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI,
+			convert.WithDescription(convert.DescriptionFromLines()),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "Some random description", invoice.Factura.DatosFactura.DescripcionFactura)
+	})
+
+	t.Run("should sanitise and truncate the description", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Notes = []*org.Note{
+			{Key: org.NoteKeyGeneral, Text: "  Line one\n\tline\x00 two " + strings.Repeat("word ", 60)},
+		}
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		desc := invoice.Factura.DatosFactura.DescripcionFactura
+		assert.True(t, strings.HasPrefix(desc, "Line one line two word word"))
+		assert.True(t, strings.HasSuffix(desc, "word…"))
+		assert.LessOrEqual(t, utf8.RuneCountInString(desc), 250)
+	})
+
 	t.Run("should include VAT and discounts to the total of the invoice", func(t *testing.T) {
 		goblInvoice := test.LoadInvoice("sample-invoice.json")
 		goblInvoice.Lines = []*bill.Line{{
@@ -204,6 +303,86 @@ func TestFacturaConversion(t *testing.T) {
 			claves := invoice.Factura.DatosFactura.Claves
 			assert.Equal(t, "52", claves.IDClave[0].ClaveRegimenIvaOpTrascendencia)
 		})
+
+	t.Run("should add special regime keys from scheme tags", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.SetTags(es.TagTravelAgency, es.TagCashBasis)
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		claves := invoice.Factura.DatosFactura.Claves
+		require.Len(t, claves.IDClave, 2)
+		assert.Equal(t, "05", claves.IDClave[0].ClaveRegimenIvaOpTrascendencia)
+		assert.Equal(t, "07", claves.IDClave[1].ClaveRegimenIvaOpTrascendencia)
+	})
+
+	t.Run("should add the regime keys from the options", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithRegimeKeys("04"))
+		require.NoError(t, err)
+
+		claves := invoice.Factura.DatosFactura.Claves
+		require.Len(t, claves.IDClave, 1)
+		assert.Equal(t, "04", claves.IDClave[0].ClaveRegimenIvaOpTrascendencia)
+	})
+
+	t.Run("should order the keys", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Customer.TaxID.Country = "GB"
+		goblInvoice.SetTags(es.TagSimplifiedScheme, es.TagArt)
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		var codes []string
+		for _, c := range invoice.Factura.DatosFactura.Claves.IDClave {
+			codes = append(codes, c.ClaveRegimenIvaOpTrascendencia)
+		}
+		assert.Equal(t, []string{"02", "03", "52"}, codes)
+	})
+
+	t.Run("should return error if more than three keys", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Customer.TaxID.Country = "GB"
+		goblInvoice.SetTags(es.TagSimplifiedScheme, es.TagTravelAgency, es.TagCashBasis)
+
+		_, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		assert.ErrorContains(t, err, "too many regime keys")
+	})
+
+	t.Run("should return error for unknown keys in the options", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+
+		_, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithRegimeKeys("16"))
+		assert.ErrorContains(t, err, "invalid key '16'")
+	})
+
+	t.Run("should validate keys against the breakdown", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+
+		_, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithRegimeKeys("17"))
+		assert.ErrorContains(t, err, "key 17 requires operations not subject")
+
+		goblInvoice = test.LoadInvoice("sample-invoice.json")
+
+		_, err = convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithRegimeKeys("12"))
+		assert.ErrorContains(t, err, "key 12 cannot be used with retained taxes")
+	})
+
+	t.Run("should accept OSS key (17) with customer rates", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("invoice-es-nl-b2c.json")
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI, convert.WithRegimeKeys("17"))
+		require.NoError(t, err)
+
+		var codes []string
+		for _, c := range invoice.Factura.DatosFactura.Claves.IDClave {
+			codes = append(codes, c.ClaveRegimenIvaOpTrascendencia)
+		}
+		assert.Equal(t, []string{"02", "17"}, codes)
+	})
 }
 
 func DiscountOf(amount int) *bill.LineDiscount {
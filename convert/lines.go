@@ -1,6 +1,9 @@
 package convert
 
 import (
+	"errors"
+	"math/big"
+
 	"github.com/invopop/gobl/bill"
 	"github.com/invopop/gobl/num"
 	"github.com/invopop/gobl/tax"
@@ -22,28 +25,129 @@ type IDDetalleFactura struct {
 	ImporteTotal       string
 }
 
-func newDetallesFactura(gobl *bill.Invoice) *DetallesFactura {
+func newDetallesFactura(gobl *bill.Invoice) (*DetallesFactura, error) {
 	lines := []IDDetalleFactura{}
 	for _, line := range gobl.Lines {
 		if line.Item.Price == nil {
 			continue
 		}
+		quantity := lineQuantity(line)
+		price, discount, err := linePricing(quantity, lineUnitPrice(line), *line.Total)
+		if err != nil {
+			return nil, validationErr("lines: %d: %s", line.Index, err)
+		}
 		lines = append(lines, IDDetalleFactura{
-			DescripcionDetalle: line.Item.Name,
-			Cantidad:           line.Quantity.String(),
-			ImporteUnitario:    line.Item.Price.Rescale(2).String(),
-			Descuento:          calculateDiscounts(line).String(),
+			DescripcionDetalle: cleanText(line.Item.Name),
+			Cantidad:           quantity.String(),
+			ImporteUnitario:    price.String(),
+			Descuento:          discount.String(),
 			ImporteTotal:       calculateTotal(line).Rescale(2).String(),
 		})
 	}
 
+	// Document level discounts are not reflected in the lines, so they're
+	// added as negative detail lines to keep the sum of the line totals in
+	// line with the invoice total.
+	for _, d := range gobl.Discounts {
+		lines = append(lines, newDiscountDetalle(d))
+	}
+
 	return &DetallesFactura{
 		IDDetalleFactura: lines,
+	}, nil
+}
+
+func newDiscountDetalle(d *bill.Discount) IDDetalleFactura {
+	desc := d.Reason
+	if desc == "" {
+		desc = "Descuento"
+	}
+	amount := d.Amount.Rescale(2)
+	total := amount.Add(taxesOf(d.Taxes, amount))
+
+	return IDDetalleFactura{
+		DescripcionDetalle: cleanText(desc),
+		Cantidad:           "1",
+		ImporteUnitario:    amount.Negate().String(),
+		Descuento:          num.MakeAmount(0, 2).String(),
+		ImporteTotal:       total.Rescale(2).Negate().String(),
 	}
 }
 
-func calculateDiscounts(line *bill.Line) num.Amount {
-	return line.Sum.Subtract(*line.Total)
+// linePricing provides the unit price and discount of a detail line with the
+// given quantity and net total. The discount is the difference between the
+// quantity times the unit price and the total, which absorbs any rounding
+// residue from limiting the precision of either. When the residue is larger
+// than the discount, the unit price is instead calculated from the total with
+// the 8 decimals allowed, as TicketBAI doesn't accept discounts that increase
+// the line's amount.
+func linePricing(quantity, price, net num.Amount) (num.Amount, num.Amount, error) {
+	net = net.Rescale(2)
+	gross := grossAmount(quantity, price)
+	discount := gross.Subtract(net)
+	if discount.IsZero() || sign(discount) == sign(gross) {
+		return price, discount, nil
+	}
+	if quantity.IsZero() {
+		return num.Amount{}, num.Amount{}, errors.New("quantity precision would be lost")
+	}
+
+	// price = net / quantity, rounded away from zero to 8 decimals so that
+	// the rounded gross amount is never below the total.
+	n := new(big.Int).Abs(big.NewInt(net.Value()))
+	n.Mul(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(quantity.Exp())+6), nil))
+	d := new(big.Int).Abs(big.NewInt(quantity.Value()))
+	n.Add(n, d).Sub(n, big.NewInt(1)).Quo(n, d)
+	if !n.IsInt64() {
+		return num.Amount{}, num.Amount{}, errors.New("unit price out of range")
+	}
+	v := n.Int64()
+	if sign(net) != sign(quantity) {
+		v = -v
+	}
+	price = trimAmount(num.MakeAmount(v, 8), 2, 8)
+	return price, grossAmount(quantity, price).Subtract(net), nil
+}
+
+func sign(a num.Amount) int {
+	switch v := a.Value(); {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+func grossAmount(quantity, price num.Amount) num.Amount {
+	return quantity.Multiply(price).Rescale(2)
+}
+
+// lineQuantity provides the line's quantity with up to the 2 decimals allowed
+// by TicketBAI.
+func lineQuantity(line *bill.Line) num.Amount {
+	return trimAmount(line.Quantity, 0, 2)
+}
+
+// lineUnitPrice provides the line's unit price with at least 2 and up to the 8
+// decimals allowed by TicketBAI.
+func lineUnitPrice(line *bill.Line) num.Amount {
+	return trimAmount(*line.Item.Price, 2, 8)
+}
+
+// trimAmount removes the trailing zeros of the amount's decimals down to min,
+// and rounds it to no more than max decimals.
+func trimAmount(a num.Amount, min, max uint32) num.Amount {
+	for a.Exp() > min && a.Value()%10 == 0 {
+		a = num.MakeAmount(a.Value()/10, a.Exp()-1)
+	}
+	if a.Exp() > max {
+		a = a.Rescale(max)
+	}
+	if a.Exp() < min {
+		a = a.Rescale(min)
+	}
+	return a
 }
 
 func calculateTotal(line *bill.Line) num.Amount {
@@ -53,13 +157,19 @@ func calculateTotal(line *bill.Line) num.Amount {
 }
 
 func calculateTaxes(line *bill.Line) num.Amount {
+	return taxesOf(line.Taxes, *line.Total)
+}
+
+// taxesOf calculates the non-retained taxes in the set applied to the
+// provided base amount.
+func taxesOf(taxes tax.Set, base num.Amount) num.Amount {
 	total := num.MakeAmount(0, 0)
-	for _, t := range line.Taxes {
+	for _, t := range taxes {
 		if regime.CategoryDef(t.Category).Retained {
 			continue
 		}
 		if t.Percent != nil {
-			total = total.Add(t.Percent.Of(*line.Total))
+			total = total.Add(t.Percent.Of(base))
 		}
 	}
 	return total
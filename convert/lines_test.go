@@ -58,6 +58,78 @@ func TestLines(t *testing.T) {
 		assert.Equal(t, "1210.00", line.ImporteTotal)
 	})
 
+	t.Run("should keep unit price precision up to 8 decimals", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Lines = []*bill.Line{
+			{
+				Index:    1,
+				Quantity: num.MakeAmount(35, 1),
+				Item:     &org.Item{Name: "Fuel", Price: num.NewAmount(123456789, 8)},
+				Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "standard"}},
+			},
+			{
+				Index:    2,
+				Quantity: num.MakeAmount(2556, 3),
+				Item:     &org.Item{Name: "Gold", Price: num.NewAmount(123456789, 10)},
+				Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "standard"}},
+			},
+		}
+		require.NoError(t, goblInvoice.Calculate())
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		lines := invoice.Factura.DatosFactura.DetallesFactura.IDDetalleFactura
+		assert.Equal(t, "3.5", lines[0].Cantidad)
+		assert.Equal(t, "1.23456789", lines[0].ImporteUnitario)
+		assert.Equal(t, "2.56", lines[1].Cantidad)
+		assert.Equal(t, "0.01234568", lines[1].ImporteUnitario)
+
+		for i, l := range lines {
+			qty, err := num.AmountFromString(l.Cantidad)
+			require.NoError(t, err)
+			price, err := num.AmountFromString(l.ImporteUnitario)
+			require.NoError(t, err)
+			discount, err := num.AmountFromString(l.Descuento)
+			require.NoError(t, err)
+			net := qty.Multiply(price).Rescale(2).Subtract(discount)
+			assert.Equal(t, goblInvoice.Lines[i].Total.Rescale(2).String(), net.String())
+		}
+	})
+
+	t.Run("should move quantity rounding into the unit price", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Lines = []*bill.Line{{
+			Index:    1,
+			Quantity: num.MakeAmount(2554, 3),
+			Item:     &org.Item{Name: "A", Price: num.NewAmount(10, 0)},
+			Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "standard"}},
+		}}
+		require.NoError(t, goblInvoice.Calculate())
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		line := invoice.Factura.DatosFactura.DetallesFactura.IDDetalleFactura[0]
+		assert.Equal(t, "2.55", line.Cantidad)
+		assert.Equal(t, "10.01568628", line.ImporteUnitario)
+		assert.Equal(t, "0.00", line.Descuento)
+	})
+
+	t.Run("should refuse lines where the quantity is lost", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Lines = []*bill.Line{{
+			Index:    1,
+			Quantity: num.MakeAmount(4, 3),
+			Item:     &org.Item{Name: "A", Price: num.NewAmount(1000, 0)},
+			Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "standard"}},
+		}}
+		require.NoError(t, goblInvoice.Calculate())
+
+		_, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		assert.ErrorContains(t, err, "lines: 1: quantity precision would be lost")
+	})
+
 	t.Run("should subtract taxes if included in prices per unit", func(t *testing.T) {
 		inv := test.LoadInvoice("sample-invoice.json")
 		inv.Tax = &bill.Tax{PricesInclude: "VAT"}
@@ -98,6 +170,40 @@ func TestLines(t *testing.T) {
 		assert.Equal(t, "1210.00", invoice.Factura.DatosFactura.ImporteTotalFactura)
 	})
 
+	t.Run("should add document discounts as detail lines", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		goblInvoice.Lines = []*bill.Line{{
+			Index:    1,
+			Quantity: num.MakeAmount(100, 0),
+			Item:     &org.Item{Name: "A", Price: num.NewAmount(10, 0)},
+			Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "standard"}},
+		}}
+		goblInvoice.Discounts = []*bill.Discount{{
+			Reason: "Promotion",
+			Amount: num.MakeAmount(100, 0),
+			Taxes:  tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "standard"}},
+		}}
+		require.NoError(t, goblInvoice.Calculate())
+
+		invoice, err := convert.NewTicketBAI(goblInvoice, ts, role, convert.ZoneBI)
+		require.NoError(t, err)
+
+		lines := invoice.Factura.DatosFactura.DetallesFactura.IDDetalleFactura
+		require.Len(t, lines, 2)
+		assert.Equal(t, "Promotion", lines[1].DescripcionDetalle)
+		assert.Equal(t, "1", lines[1].Cantidad)
+		assert.Equal(t, "-100.00", lines[1].ImporteUnitario)
+		assert.Equal(t, "-121.00", lines[1].ImporteTotal)
+
+		sum := num.MakeAmount(0, 2)
+		for _, l := range lines {
+			a, err := num.AmountFromString(l.ImporteTotal)
+			require.NoError(t, err)
+			sum = sum.Add(a)
+		}
+		assert.Equal(t, invoice.Factura.DatosFactura.ImporteTotalFactura, sum.String())
+	})
+
 	t.Run("should return error if more than 1000 lines included and not Vizcaya", func(t *testing.T) {
 		inv := test.LoadInvoice("sample-invoice.json")
 		inv.Lines = []*bill.Line{}
@@ -115,4 +221,52 @@ func TestLines(t *testing.T) {
 
 		assert.ErrorContains(t, err, "line count over limit (1000) for tax locality")
 	})
+
+	t.Run("should aggregate lines over the limit when enabled", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+		inv.Lines = []*bill.Line{}
+		for i := 1; i <= 1001; i++ {
+			name := "A"
+			if i%2 == 0 {
+				name = "B"
+			}
+			inv.Lines = append(inv.Lines, &bill.Line{
+				Index:    i,
+				Quantity: num.MakeAmount(3, 0),
+				Item:     &org.Item{Name: name, Price: num.NewAmount(333, 2)},
+				Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: tax.RateGeneral}},
+			})
+		}
+		require.NoError(t, inv.Calculate())
+
+		doc, err := convert.NewTicketBAI(inv, ts, role, convert.ZoneSS, convert.WithLineAggregation())
+		require.NoError(t, err)
+
+		lines := doc.Factura.DatosFactura.DetallesFactura.IDDetalleFactura
+		require.Len(t, lines, 2)
+		assert.Equal(t, "A", lines[0].DescripcionDetalle)
+		assert.Equal(t, "1503", lines[0].Cantidad)
+		assert.Equal(t, "3.33", lines[0].ImporteUnitario)
+		assert.Equal(t, "6057.09", lines[0].ImporteTotal)
+		assert.Equal(t, "1500", lines[1].Cantidad)
+
+		agg := doc.AggregatedLines()
+		require.Len(t, agg, 2)
+		assert.Len(t, agg[0].Lines, 501)
+		assert.Equal(t, 1, agg[0].Lines[0])
+		assert.Len(t, agg[1].Lines, 500)
+
+		diva := doc.Factura.TipoDesglose.DesgloseFactura.Sujeta.NoExenta.DetalleNoExenta[0].DesgloseIVA.DetalleIVA[0]
+		assert.Equal(t, inv.Totals.Taxes.Category(tax.CategoryVAT).Rates[0].Base.Rescale(2).String(), diva.BaseImponible)
+	})
+
+	t.Run("should not aggregate lines within the limit", func(t *testing.T) {
+		inv := test.LoadInvoice("sample-invoice.json")
+
+		doc, err := convert.NewTicketBAI(inv, ts, role, convert.ZoneSS, convert.WithLineAggregation())
+		require.NoError(t, err)
+
+		assert.Len(t, doc.Factura.DatosFactura.DetallesFactura.IDDetalleFactura, 1)
+		assert.Empty(t, doc.AggregatedLines())
+	})
 }
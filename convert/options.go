@@ -0,0 +1,71 @@
+package convert
+
+// Option is used to customise how GOBL invoices are converted into TicketBAI
+// documents.
+type Option func(*options)
+
+type options struct {
+	aggregateLines bool
+	descriptions   []DescriptionSource
+	coRecipients   bool
+	regimeKeys     []string
+
+	simplifiedSubstitution bool
+}
+
+// WithLineAggregation allows invoices over the limit of detail lines
+// accepted in Gipuzkoa and Araba to be converted by merging the lines with the
+// same item, price and taxes. Merged lines are reported by the document's
+// AggregatedLines method.
+func WithLineAggregation() Option {
+	return func(o *options) {
+		o.aggregateLines = true
+	}
+}
+
+// WithDescription defines the sources used to generate the invoice's
+// description, tried in order until one provides some text. The text of the
+// invoice's general note is used when none of them do.
+func WithDescription(sources ...DescriptionSource) Option {
+	return func(o *options) {
+		o.descriptions = append(o.descriptions, sources...)
+	}
+}
+
+// WithCoRecipients adds the people of the customer as additional recipients
+// of the invoice, for example the co-owners of a property. Each person must
+// have a NIF, DNI or NIE identity, or one of the identities accepted for
+// foreign customers.
+func WithCoRecipients() Option {
+	return func(o *options) {
+		o.coRecipients = true
+	}
+}
+
+// WithRegimeKeys adds regime keys (ClaveRegimenIvaOpTrascendencia) that
+// cannot be determined from the invoice, such as investment gold (04), VAT
+// groups (06) or the rental of business premises (11, 12, 13). Keys are
+// checked against the invoice's taxes.
+func WithRegimeKeys(codes ...string) Option {
+	return func(o *options) {
+		o.regimeKeys = append(o.regimeKeys, codes...)
+	}
+}
+
+// WithSimplifiedSubstitution flags the invoice as a complete invoice issued
+// to replace the simplified invoices (tickets) listed in its preceding
+// documents (FacturaEmitidaSustitucionSimplificada), which GOBL has no way
+// to express.
+func WithSimplifiedSubstitution() Option {
+	return func(o *options) {
+		o.simplifiedSubstitution = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := new(options)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -3,9 +3,11 @@ package convert
 import (
 	"strings"
 
+	"github.com/invopop/gobl/bill"
 	"github.com/invopop/gobl/cbc"
 	"github.com/invopop/gobl/l10n"
 	"github.com/invopop/gobl/org"
+	"github.com/invopop/gobl/tax"
 )
 
 const (
@@ -33,9 +35,16 @@ type Emisor struct {
 	ApellidosNombreRazonSocial string
 }
 
-// Destinatarios contains info about the invoice customers,
-// Tickebai allows up to 100 customers but GOBL only allows one
-// per invoice
+// maxDestinatarios is the maximum number of recipients allowed by TicketBAI.
+const maxDestinatarios = 100
+
+// nifIdentityTypes lists the identity types of Spanish people that can be
+// used as NIF for co-recipients.
+var nifIdentityTypes = []cbc.Code{"NIF", "DNI", "NIE"}
+
+// Destinatarios contains info about the invoice customers. TicketBAI allows
+// up to 100 customers but GOBL only allows one per invoice, so co-recipients
+// may be taken from the people of the customer.
 type Destinatarios struct {
 	IDDestinatario []*IDDestinatario
 }
@@ -63,16 +72,83 @@ func newEmisor(party *org.Party) *Emisor {
 	}
 }
 
+func newDestinatarios(inv *bill.Invoice, o *options) (*Destinatarios, error) {
+	if inv.Customer == nil {
+		return nil, nil
+	}
+	// If the customer is still nil, implies that they didn't have enough
+	// fiscal information to include in the output.
+	dest := newDestinatario(inv.Customer)
+	if dest == nil {
+		return nil, nil
+	}
+	ds := &Destinatarios{
+		IDDestinatario: []*IDDestinatario{dest},
+	}
+
+	if o.coRecipients {
+		for i, person := range inv.Customer.People {
+			if person == nil {
+				continue
+			}
+			d := newDestinatario(coRecipientParty(inv.Customer, person))
+			if d == nil {
+				return nil, validationErr("customer: people: %d: missing identity", i)
+			}
+			ds.IDDestinatario = append(ds.IDDestinatario, d)
+		}
+		if len(ds.IDDestinatario) > maxDestinatarios {
+			return nil, validationErr("customer: too many recipients (%d), max %d", len(ds.IDDestinatario), maxDestinatarios)
+		}
+	}
+
+	return ds, nil
+}
+
+// coRecipientParty prepares a party for one of the people of the customer,
+// so that it can be converted like any other recipient. The customer's
+// address is used if the person doesn't have one.
+func coRecipientParty(customer *org.Party, person *org.Person) *org.Party {
+	party := &org.Party{
+		Addresses: person.Addresses,
+	}
+	if person.Name != nil {
+		party.Name = personName(person.Name)
+	}
+	if len(party.Addresses) == 0 {
+		party.Addresses = customer.Addresses
+	}
+	for _, id := range person.Identities {
+		if id == nil || id.Code == "" {
+			continue
+		}
+		if (id.Country == "" || id.Country == l10n.ES.ISO()) && id.Type.In(nifIdentityTypes...) {
+			party.TaxID = &tax.Identity{Country: l10n.ES.Tax(), Code: id.Code}
+			break
+		}
+		party.Identities = append(party.Identities, id)
+	}
+	return party
+}
+
+// personName formats the name of a person with the surnames first, as
+// expected by TicketBAI.
+func personName(n *org.Name) string {
+	parts := []string{}
+	for _, p := range []string{n.Surname, n.Surname2, n.Given} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
 func newDestinatario(party *org.Party) *IDDestinatario {
 	d := &IDDestinatario{
 		ApellidosNombreRazonSocial: party.Name,
 	}
 
-	if party.TaxID != nil && party.TaxID.Country == "ES" && party.TaxID.Code != "" {
-		d.NIF = party.TaxID.Code.String()
-	} else {
-		d.IDOtro = otherIdentity(party)
-	}
+	d.NIF, d.IDOtro = PartyIdentity(party)
 	if d.NIF == "" && d.IDOtro == nil {
 		// Assume this is a B2C operation.
 		return nil
@@ -86,6 +162,16 @@ func newDestinatario(party *org.Party) *IDDestinatario {
 	return d
 }
 
+// PartyIdentity provides the NIF of parties with a Spanish tax ID, or the
+// alternative identity (IDOtro) of any other party, which will be nil if the
+// party cannot be identified.
+func PartyIdentity(party *org.Party) (string, *IDOtro) {
+	if party.TaxID != nil && party.TaxID.Country == "ES" && party.TaxID.Code != "" {
+		return party.TaxID.Code.String(), nil
+	}
+	return "", otherIdentity(party)
+}
+
 func otherIdentity(party *org.Party) *IDOtro {
 	oid := new(IDOtro)
 	if party.TaxID != nil {
@@ -32,6 +32,15 @@ func (doc *TicketBAI) generateCodes(locality l10n.Code) *Codes {
 	}
 }
 
+// TBAICode provides the TicketBAI identifier of the signed document, as
+// included in the QR codes.
+func (doc *TicketBAI) TBAICode() string {
+	if doc.Signature == nil || doc.Factura == nil || doc.Factura.CabeceraFactura == nil {
+		return ""
+	}
+	return doc.generateTbaiCode()
+}
+
 func (doc *TicketBAI) generateTbaiCode() string {
 	header := doc.Factura.CabeceraFactura
 	dateParts := strings.Split(header.FechaExpedicionFactura, "-")
@@ -0,0 +1,106 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nbio/xml"
+)
+
+// SignedDocument keeps the exact XML of a signed TicketBAI document alongside
+// its parsed view. Re-encoding a parsed document may produce bytes that differ
+// from the ones that were signed, so the original XML is what should be
+// persisted and sent again, for example after an outage.
+type SignedDocument struct {
+	*TicketBAI
+	data []byte
+}
+
+// SignedCancelDocument keeps the exact XML of a signed AnulaTicketBAI document
+// alongside its parsed view.
+type SignedCancelDocument struct {
+	*AnulaTicketBAI
+	data []byte
+}
+
+// NewSignedDocument encodes the signed document, keeping the resulting XML
+// to be persisted and sent without any further changes.
+func NewSignedDocument(doc *TicketBAI) (*SignedDocument, error) {
+	if doc == nil || doc.Signature == nil {
+		return nil, errors.New("document has not been signed")
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SignedDocument{TicketBAI: doc, data: data}, nil
+}
+
+// ParseSignedDocument parses the XML of a signed TicketBAI document, keeping
+// a copy of the original data. The issue timestamp is restored from the
+// invoice header.
+func ParseSignedDocument(data []byte) (*SignedDocument, error) {
+	doc := new(TicketBAI)
+	if err := xml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if doc.Signature == nil {
+		return nil, errors.New("document has not been signed")
+	}
+	if doc.Factura != nil && doc.Factura.CabeceraFactura != nil {
+		h := doc.Head()
+		ts, err := parseTimestamp(h.FechaExpedicionFactura, h.HoraExpedicionFactura)
+		if err != nil {
+			return nil, err
+		}
+		doc.ts = ts
+	}
+	return &SignedDocument{TicketBAI: doc, data: bytes.Clone(data)}, nil
+}
+
+// Bytes returns the exact XML of the signed document.
+func (d *SignedDocument) Bytes() ([]byte, error) {
+	return d.data, nil
+}
+
+// NewSignedCancelDocument encodes the signed cancellation document, keeping
+// the resulting XML to be persisted and sent without any further changes.
+func NewSignedCancelDocument(doc *AnulaTicketBAI) (*SignedCancelDocument, error) {
+	if doc == nil || doc.Signature == nil {
+		return nil, errors.New("document has not been signed")
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SignedCancelDocument{AnulaTicketBAI: doc, data: data}, nil
+}
+
+// ParseSignedCancelDocument parses the XML of a signed AnulaTicketBAI
+// document, keeping a copy of the original data.
+func ParseSignedCancelDocument(data []byte) (*SignedCancelDocument, error) {
+	doc := new(AnulaTicketBAI)
+	if err := xml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if doc.Signature == nil {
+		return nil, errors.New("document has not been signed")
+	}
+	return &SignedCancelDocument{AnulaTicketBAI: doc, data: bytes.Clone(data)}, nil
+}
+
+// Bytes returns the exact XML of the signed cancellation document.
+func (d *SignedCancelDocument) Bytes() ([]byte, error) {
+	return d.data, nil
+}
+
+// parseTimestamp reverses formatDate and formatTime.
+func parseTimestamp(date, clock string) (time.Time, error) {
+	ts, err := time.ParseInLocation("02-01-2006 15:04:05", date+" "+clock, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing issue timestamp: %w", err)
+	}
+	return ts, nil
+}
@@ -0,0 +1,81 @@
+package convert_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/xmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedDocument(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339, "2022-02-01T04:00:00Z")
+	require.NoError(t, err)
+
+	t.Run("should keep the original bytes", func(t *testing.T) {
+		data, err := os.ReadFile(test.Path("test", "data", "out", "invoice-es-es-b2c.xml"))
+		require.NoError(t, err)
+
+		sd, err := convert.ParseSignedDocument(data)
+		require.NoError(t, err)
+
+		out, err := sd.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, data, out)
+		assert.Equal(t, "0002", sd.Head().NumFactura)
+		assert.True(t, ts.Equal(sd.IssueTimestamp()))
+		assert.NotEmpty(t, sd.SignatureValue())
+	})
+
+	t.Run("should refuse unsigned documents", func(t *testing.T) {
+		doc, err := convert.NewTicketBAI(test.LoadInvoice("sample-invoice.json"), ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		_, err = convert.NewSignedDocument(doc)
+		assert.ErrorContains(t, err, "document has not been signed")
+
+		data, err := doc.Bytes()
+		require.NoError(t, err)
+		_, err = convert.ParseSignedDocument(data)
+		assert.ErrorContains(t, err, "document has not been signed")
+	})
+
+	t.Run("should round trip signed documents", func(t *testing.T) {
+		cert, err := xmldsig.LoadCertificate(test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad.p12"), "IZDesa2025")
+		require.NoError(t, err)
+
+		doc, err := convert.NewTicketBAI(test.LoadInvoice("sample-invoice.json"), ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		require.NoError(t, doc.Sign("TEST", cert, convert.IssuerRoleSupplier, convert.ZoneBI))
+		sd, err := convert.NewSignedDocument(doc)
+		require.NoError(t, err)
+		data, err := sd.Bytes()
+		require.NoError(t, err)
+
+		pd, err := convert.ParseSignedDocument(data)
+		require.NoError(t, err)
+		out, err := pd.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, data, out)
+		assert.Equal(t, doc.SignatureValue(), pd.SignatureValue())
+		assert.True(t, doc.IssueTimestamp().Equal(pd.IssueTimestamp()))
+
+		cd, err := convert.NewAnulaTicketBAIFromDocument(pd.TicketBAI)
+		require.NoError(t, err)
+		require.NoError(t, cd.Sign("TEST", cert, convert.IssuerRoleSupplier, convert.ZoneBI))
+		scd, err := convert.NewSignedCancelDocument(cd)
+		require.NoError(t, err)
+		data, err = scd.Bytes()
+		require.NoError(t, err)
+
+		pcd, err := convert.ParseSignedCancelDocument(data)
+		require.NoError(t, err)
+		out, err = pcd.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, data, out)
+		assert.Equal(t, cd.IDFactura.CabeceraFactura.NumFactura, pcd.IDFactura.CabeceraFactura.NumFactura)
+	})
+}
@@ -0,0 +1,368 @@
+package convert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/beevik/etree"
+	"github.com/invopop/gobl/l10n"
+	"github.com/invopop/xmldsig"
+	dsig "github.com/russellhaering/goxmldsig"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Signer signs TicketBAI documents with any crypto.Signer, such as a key kept
+// in a KMS or a separate signing service, along with the certificate chain
+// of the key.
+//
+// The xmldsig package only signs with private keys held in memory, so
+// documents are first signed with a temporary stand-in key, and the parts of
+// the signature that depend on the key (the key value, its digest and the
+// signature value) are then rebuilt using the signer. This relies on the
+// layout of the signatures produced by xmldsig, and should be replaced by
+// signing with the crypto.Signer directly once xmldsig supports it.
+type Signer struct {
+	key   crypto.Signer
+	chain []*x509.Certificate
+
+	once    sync.Once
+	standIn *xmldsig.Certificate
+	err     error
+}
+
+// Curve URIs used in the ECDSA key values, as defined in XMLDSIG 1.1.
+var curveURIs = map[string]string{
+	"P-256": "urn:oid:1.2.840.10045.3.1.7",
+	"P-384": "urn:oid:1.3.132.0.34",
+	"P-521": "urn:oid:1.3.132.0.35",
+}
+
+// NewSigner prepares a signer from the key and its certificate chain, which
+// must start with the certificate of the key. Only RSA and ECDSA keys are
+// supported.
+func NewSigner(key crypto.Signer, chain ...*x509.Certificate) (*Signer, error) {
+	if key == nil {
+		return nil, errors.New("signer: missing key")
+	}
+	if len(chain) == 0 || chain[0] == nil {
+		return nil, errors.New("signer: missing certificate")
+	}
+	switch pub := chain[0].PublicKey.(type) {
+	case *rsa.PublicKey:
+		if !pub.Equal(key.Public()) {
+			return nil, errors.New("signer: key does not match certificate")
+		}
+	case *ecdsa.PublicKey:
+		if !pub.Equal(key.Public()) {
+			return nil, errors.New("signer: key does not match certificate")
+		}
+		if _, ok := curveURIs[pub.Curve.Params().Name]; !ok {
+			return nil, fmt.Errorf("signer: unsupported curve %s", pub.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("signer: unsupported key type %T", chain[0].PublicKey)
+	}
+	return &Signer{key: key, chain: chain}, nil
+}
+
+// NewLocalSigner prepares a signer that uses the private key held in memory
+// by the certificate. Mainly useful for testing and for moving gradually to
+// external signers.
+func NewLocalSigner(cert *xmldsig.Certificate) (*Signer, error) {
+	if cert == nil {
+		return nil, errors.New("signer: missing certificate")
+	}
+	kb, _ := pem.Decode(cert.PrivateKey())
+	if kb == nil {
+		return nil, errors.New("signer: unsupported private key")
+	}
+	var key crypto.Signer
+	var err error
+	switch kb.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(kb.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(kb.Bytes)
+	default:
+		err = fmt.Errorf("unsupported private key type %s", kb.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signer: %w", err)
+	}
+	cb, _ := pem.Decode(cert.PEM())
+	leaf, err := x509.ParseCertificate(cb.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signer: %w", err)
+	}
+	return NewSigner(key, append([]*x509.Certificate{leaf}, cert.CaChain...)...)
+}
+
+// Certificate provides the certificate of the signer's key.
+func (s *Signer) Certificate() *x509.Certificate {
+	return s.chain[0]
+}
+
+// TLSCertificate provides the certificate chain and signer ready to be used
+// for mutual TLS authentication with the gateways.
+func (s *Signer) TLSCertificate() tls.Certificate {
+	raw := make([][]byte, len(s.chain))
+	for i, c := range s.chain {
+		raw[i] = c.Raw
+	}
+	return tls.Certificate{
+		Certificate: raw,
+		PrivateKey:  s.key,
+		Leaf:        s.chain[0],
+	}
+}
+
+// SignWith signs the document with the given signer and role.
+func (doc *TicketBAI) SignWith(docID string, signer *Signer, role IssuerRole, zone l10n.Code, opts ...xmldsig.Option) error {
+	s, err := newSignatureWith(doc, docID, zone, role, signer, opts...)
+	if err != nil {
+		return err
+	}
+	doc.Signature = s
+	return nil
+}
+
+// SignWith signs the cancellation document with the given signer and role.
+func (doc *AnulaTicketBAI) SignWith(docID string, signer *Signer, role IssuerRole, zone l10n.Code, opts ...xmldsig.Option) error {
+	s, err := newSignatureWith(doc, docID, zone, role, signer, opts...)
+	if err != nil {
+		return err
+	}
+	doc.Signature = s
+	return nil
+}
+
+func newSignatureWith(doc any, docID string, zone l10n.Code, role IssuerRole, signer *Signer, opts ...xmldsig.Option) (*xmldsig.Signature, error) {
+	if signer == nil {
+		return nil, errors.New("cannot sign without a signer")
+	}
+	cert, err := signer.standInCertificate()
+	if err != nil {
+		return nil, err
+	}
+	data, err := toBytesCanonical(doc)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSignature(doc, docID, zone, role, cert, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.resign(s, data); err != nil {
+		return nil, fmt.Errorf("signer: %w", err)
+	}
+	return s, nil
+}
+
+// standInCertificate provides an xmldsig certificate with the signer's chain
+// and a temporary key, generated once per signer.
+func (s *Signer) standInCertificate() (*xmldsig.Certificate, error) {
+	s.once.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			s.err = fmt.Errorf("signer: generating stand-in key: %w", err)
+			return
+		}
+		data, err := pkcs12.Passwordless.Encode(key, s.chain[0], s.chain[1:], "")
+		if err != nil {
+			s.err = fmt.Errorf("signer: encoding stand-in certificate: %w", err)
+			return
+		}
+		s.standIn, s.err = xmldsig.LoadCertificateFromBytes(data, "")
+	})
+	return s.standIn, s.err
+}
+
+// resign replaces the key value, the key info digest and the signature value
+// that were produced with the stand-in key.
+func (s *Signer) resign(sig *xmldsig.Signature, data []byte) error {
+	cfg := XMLDSigConfig()
+	ns, err := signatureNamespaces(data)
+	if err != nil {
+		return err
+	}
+
+	if cfg.IncludeKeyValue {
+		sig.KeyInfo.KeyValue, sig.KeyInfo.DSig11Namespace = s.keyValue()
+	}
+
+	keyInfoRef := "#" + sig.KeyInfo.ID
+	for _, ref := range sig.SignedInfo.Reference {
+		if ref.URI != keyInfoRef {
+			continue
+		}
+		ki, err := xml.Marshal(sig.KeyInfo)
+		if err != nil {
+			return fmt.Errorf("marshal key info: %w", err)
+		}
+		ki, err = canonicalize(ki, ns.Add(xmldsig.DSig, xmldsig.NamespaceDSig), cfg.KeyInfoCanonicalizer)
+		if err != nil {
+			return fmt.Errorf("canonicalize key info: %w", err)
+		}
+		ref.DigestValue = digest(ki, hashOr(cfg.KeyInfoHash, crypto.SHA512))
+	}
+
+	si, err := xml.Marshal(sig.SignedInfo)
+	if err != nil {
+		return fmt.Errorf("marshal signed info: %w", err)
+	}
+	si, err = canonicalize(si, ns.Add(xmldsig.DSig, sig.DSigNamespace), cfg.SignedInfoCanonicalizer)
+	if err != nil {
+		return fmt.Errorf("canonicalize signed info: %w", err)
+	}
+	value, err := s.sign(si, hashOr(cfg.SignedInfoHash, crypto.SHA256))
+	if err != nil {
+		return fmt.Errorf("sign signed info: %w", err)
+	}
+	sig.Value.Value = value
+
+	return nil
+}
+
+// sign hashes and signs the data, providing ECDSA signatures in the
+// concatenated format (r || s) required by XML-DSig.
+func (s *Signer) sign(data []byte, hash crypto.Hash) (string, error) {
+	h := hash.New()
+	h.Write(data) // nolint:errcheck
+	sum := h.Sum(nil)
+	out, err := s.key.Sign(rand.Reader, sum, hash)
+	if err != nil {
+		return "", err
+	}
+	// Signers backed by external services may be misconfigured, for example
+	// using PSS padding, so the signature is checked before it is used.
+	if err := s.verify(sum, out, hash); err != nil {
+		return "", err
+	}
+	if pub, ok := s.chain[0].PublicKey.(*ecdsa.PublicKey); ok {
+		var es struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(out, &es); err != nil {
+			return "", fmt.Errorf("parsing ECDSA signature: %w", err)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		out = append(es.R.FillBytes(make([]byte, size)), es.S.FillBytes(make([]byte, size))...)
+	}
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// verify checks the signature of the digest with the certificate's public
+// key.
+func (s *Signer) verify(digest, sig []byte, hash crypto.Hash) error {
+	switch pub := s.chain[0].PublicKey.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, sig); err != nil {
+			return fmt.Errorf("verifying signature: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			return errors.New("verifying signature: invalid ECDSA signature")
+		}
+	}
+	return nil
+}
+
+// keyValue provides the key value of the signer's public key, along with the
+// XMLDSIG 1.1 namespace required by ECDSA keys.
+func (s *Signer) keyValue() (*xmldsig.KeyValue, string) {
+	switch pub := s.chain[0].PublicKey.(type) {
+	case *rsa.PublicKey:
+		exp := []byte{byte(pub.E >> 16), byte(pub.E >> 8), byte(pub.E)}
+		return &xmldsig.KeyValue{
+			RSA: &xmldsig.RSAKeyValue{
+				Modulus:  base64.StdEncoding.EncodeToString(pub.N.Bytes()),
+				Exponent: base64.StdEncoding.EncodeToString(exp),
+			},
+		}, ""
+	case *ecdsa.PublicKey:
+		point, err := pub.ECDH()
+		if err != nil {
+			return nil, ""
+		}
+		return &xmldsig.KeyValue{
+			EC: &xmldsig.ECKeyValue{
+				NamedCurve: xmldsig.NamedCurve{URI: curveURIs[pub.Curve.Params().Name]},
+				PublicKey:  base64.StdEncoding.EncodeToString(point.Bytes()),
+			},
+		}, xmldsig.NamespaceDSig11
+	}
+	return nil, ""
+}
+
+// signatureNamespaces provides the namespaces in scope of the signature:
+// the default TicketBAI namespace and the ones declared in the document's
+// root element.
+func signatureNamespaces(data []byte) (xmldsig.Namespaces, error) {
+	d := etree.NewDocument()
+	if err := d.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("reading document: %w", err)
+	}
+	ns := xmldsig.Namespaces{"T": ticketBAIEmisionNamespace}
+	for _, a := range d.Root().Attr {
+		if a.Space == "xmlns" {
+			ns[a.Key] = a.Value
+		} else if a.Space == "" && a.Key == "xmlns" {
+			ns[""] = a.Value
+		}
+	}
+	return ns, nil
+}
+
+// canonicalize declares the namespaces missing in the data's root element
+// before canonicalizing it.
+func canonicalize(data []byte, ns xmldsig.Namespaces, c dsig.Canonicalizer) ([]byte, error) {
+	if c == nil {
+		c = dsig.MakeC14N10RecCanonicalizer()
+	}
+	d := etree.NewDocument()
+	if err := d.ReadFromBytes(data); err != nil {
+		return nil, err
+	}
+	r := d.Root()
+	for k, v := range ns {
+		space, key := "xmlns", k
+		if k == "" {
+			space, key = "", "xmlns"
+		}
+		if r.SelectAttr(attrName(space, key)) == nil {
+			r.CreateAttr(attrName(space, key), v)
+		}
+	}
+	return c.Canonicalize(r)
+}
+
+func attrName(space, key string) string {
+	if space == "" {
+		return key
+	}
+	return space + ":" + key
+}
+
+func digest(data []byte, hash crypto.Hash) string {
+	h := hash.New()
+	h.Write(data) // nolint:errcheck
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func hashOr(h, def crypto.Hash) crypto.Hash {
+	if h == 0 {
+		return def
+	}
+	return h
+}
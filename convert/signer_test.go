@@ -0,0 +1,264 @@
+package convert_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/xmldsig"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339, "2022-02-01T04:00:00Z")
+	require.NoError(t, err)
+	now := func() time.Time { return ts }
+
+	cert, err := xmldsig.LoadCertificate(test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad.p12"), "IZDesa2025")
+	require.NoError(t, err)
+
+	signer, err := convert.NewLocalSigner(cert)
+	require.NoError(t, err)
+
+	t.Run("should match the signature of the certificate", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		doc, err := convert.NewTicketBAI(goblInvoice, ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		require.NoError(t, doc.Sign("TEST", cert, convert.IssuerRoleSupplier, convert.ZoneBI, xmldsig.WithCurrentTime(now)))
+		want, err := doc.Bytes()
+		require.NoError(t, err)
+
+		doc, err = convert.NewTicketBAI(goblInvoice, ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		require.NoError(t, doc.SignWith("TEST", signer, convert.IssuerRoleSupplier, convert.ZoneBI, xmldsig.WithCurrentTime(now)))
+		got, err := doc.Bytes()
+		require.NoError(t, err)
+
+		assert.Equal(t, string(want), string(got))
+	})
+
+	t.Run("should match the cancel signature of the certificate", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		doc, err := convert.NewAnulaTicketBAI(goblInvoice, ts)
+		require.NoError(t, err)
+		require.NoError(t, doc.Sign("TEST", cert, convert.IssuerRoleSupplier, convert.ZoneSS, xmldsig.WithCurrentTime(now)))
+		want, err := doc.Bytes()
+		require.NoError(t, err)
+
+		doc, err = convert.NewAnulaTicketBAI(goblInvoice, ts)
+		require.NoError(t, err)
+		require.NoError(t, doc.SignWith("TEST", signer, convert.IssuerRoleSupplier, convert.ZoneSS, xmldsig.WithCurrentTime(now)))
+		got, err := doc.Bytes()
+		require.NoError(t, err)
+
+		assert.Equal(t, string(want), string(got))
+	})
+
+	t.Run("should sign with ECDSA keys", func(t *testing.T) {
+		key, leaf := newECDSACertificate(t)
+		signer, err := convert.NewSigner(key, leaf)
+		require.NoError(t, err)
+
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		doc, err := convert.NewTicketBAI(goblInvoice, ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		require.NoError(t, doc.SignWith("TEST", signer, convert.IssuerRoleSupplier, convert.ZoneBI))
+
+		kv := doc.Signature.KeyInfo.KeyValue
+		require.NotNil(t, kv.EC)
+		assert.Nil(t, kv.RSA)
+		assert.Equal(t, xmldsig.NamespaceDSig11, doc.Signature.KeyInfo.DSig11Namespace)
+		assert.Len(t, doc.Signature.Value.Value, 88) // 64 bytes, r || s
+	})
+
+	t.Run("should produce a valid RSA signature", func(t *testing.T) {
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		doc, err := convert.NewTicketBAI(goblInvoice, ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		require.NoError(t, doc.SignWith("TEST", signer, convert.IssuerRoleSupplier, convert.ZoneBI))
+		data, err := doc.Bytes()
+		require.NoError(t, err)
+		verifySignature(t, data, signer.Certificate())
+	})
+
+	t.Run("should produce a valid ECDSA signature", func(t *testing.T) {
+		key, leaf := newECDSACertificate(t)
+		signer, err := convert.NewSigner(key, leaf)
+		require.NoError(t, err)
+
+		goblInvoice := test.LoadInvoice("sample-invoice.json")
+		doc, err := convert.NewTicketBAI(goblInvoice, ts, convert.IssuerRoleSupplier, convert.ZoneBI)
+		require.NoError(t, err)
+		require.NoError(t, doc.SignWith("TEST", signer, convert.IssuerRoleSupplier, convert.ZoneBI))
+		data, err := doc.Bytes()
+		require.NoError(t, err)
+		verifySignature(t, data, leaf)
+
+		cd, err := convert.NewAnulaTicketBAI(goblInvoice, ts)
+		require.NoError(t, err)
+		require.NoError(t, cd.SignWith("TEST", signer, convert.IssuerRoleSupplier, convert.ZoneSS))
+		data, err = cd.Bytes()
+		require.NoError(t, err)
+		verifySignature(t, data, leaf)
+	})
+
+	t.Run("should refuse keys not matching the certificate", func(t *testing.T) {
+		key, _ := newECDSACertificate(t)
+		_, leaf := newECDSACertificate(t)
+		_, err := convert.NewSigner(key, leaf)
+		assert.ErrorContains(t, err, "key does not match certificate")
+	})
+
+	t.Run("should provide the TLS certificate", func(t *testing.T) {
+		tc := signer.TLSCertificate()
+		assert.Equal(t, signer.Certificate(), tc.Leaf)
+		assert.Len(t, tc.Certificate, len(cert.CaChain)+1)
+	})
+}
+
+func newECDSACertificate(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+	return key, leaf
+}
+
+// verifySignature checks the XML-DSig signature of the document on its own,
+// canonicalizing the signed elements where they are in the document instead
+// of relying on the way they were built by the signer.
+func verifySignature(t *testing.T, data []byte, leaf *x509.Certificate) {
+	t.Helper()
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(data))
+	root := doc.Root()
+	sig := findElement(root, func(el *etree.Element) bool {
+		return el.Space == "ds" && el.Tag == "Signature"
+	})
+	require.NotNil(t, sig, "missing signature")
+	si := sig.SelectElement("SignedInfo")
+	require.NotNil(t, si, "missing signed info")
+
+	refs := si.SelectElements("Reference")
+	require.Len(t, refs, 3)
+	for _, ref := range refs {
+		uri := ref.SelectAttrValue("URI", "")
+		c14n := dsig.Canonicalizer(dsig.MakeC14N10RecCanonicalizer())
+		if tr := ref.SelectElement("Transforms"); tr != nil {
+			for _, m := range tr.SelectElements("Transform") {
+				switch dsig.AlgorithmID(m.SelectAttrValue("Algorithm", "")) {
+				case dsig.EnvelopedSignatureAltorithmId, dsig.CanonicalXML10RecAlgorithmId:
+				case dsig.CanonicalXML11AlgorithmId:
+					c14n = dsig.MakeC14N11Canonicalizer()
+				case dsig.CanonicalXML10ExclusiveAlgorithmId:
+					c14n = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+				default:
+					t.Fatalf("reference %q: unexpected transform %s", uri, m.SelectAttrValue("Algorithm", ""))
+				}
+			}
+		}
+
+		var el *etree.Element
+		if uri == "" {
+			el = root.Copy()
+			s := findElement(el, func(el *etree.Element) bool {
+				return el.Space == "ds" && el.Tag == "Signature"
+			})
+			s.Parent().RemoveChild(s)
+		} else {
+			id := strings.TrimPrefix(uri, "#")
+			el = findElement(root, func(el *etree.Element) bool {
+				return el.SelectAttrValue("Id", "") == id
+			})
+			require.NotNil(t, el, "reference %q: element not found", uri)
+		}
+		out, err := c14n.Canonicalize(el)
+		require.NoError(t, err)
+
+		hash := signatureHash(t, ref.SelectElement("DigestMethod").SelectAttrValue("Algorithm", ""))
+		h := hash.New()
+		h.Write(out)
+		value, err := base64.StdEncoding.DecodeString(ref.SelectElement("DigestValue").Text())
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(h.Sum(nil), value), "reference %q: digest mismatch", uri)
+	}
+
+	xc := findElement(sig, func(el *etree.Element) bool { return el.Tag == "X509Certificate" })
+	require.NotNil(t, xc, "missing certificate")
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(xc.Text()))
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(leaf), "unexpected certificate")
+
+	out, err := dsig.MakeC14N10RecCanonicalizer().Canonicalize(si)
+	require.NoError(t, err)
+	hash := signatureHash(t, si.SelectElement("SignatureMethod").SelectAttrValue("Algorithm", ""))
+	h := hash.New()
+	h.Write(out)
+	digest := h.Sum(nil)
+	value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sig.SelectElement("SignatureValue").Text()))
+	require.NoError(t, err)
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPKCS1v15(pub, hash, digest, value))
+	case *ecdsa.PublicKey:
+		size := len(value) / 2
+		r := new(big.Int).SetBytes(value[:size])
+		s := new(big.Int).SetBytes(value[size:])
+		assert.True(t, ecdsa.Verify(pub, digest, r, s), "invalid ECDSA signature")
+	default:
+		t.Fatalf("unexpected public key %T", pub)
+	}
+}
+
+func signatureHash(t *testing.T, alg string) crypto.Hash {
+	t.Helper()
+	switch {
+	case strings.HasSuffix(alg, "sha256"):
+		return crypto.SHA256
+	case strings.HasSuffix(alg, "sha512"):
+		return crypto.SHA512
+	}
+	t.Fatalf("unexpected algorithm %s", alg)
+	return 0
+}
+
+func findElement(el *etree.Element, match func(*etree.Element) bool) *etree.Element {
+	if match(el) {
+		return el
+	}
+	for _, c := range el.ChildElements() {
+		if found := findElement(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/invopop/gobl/bill"
 	"github.com/invopop/gobl/l10n"
+	"github.com/invopop/gobl/tax"
 )
 
 // ValidationError is a simple wrapper around validation errors
@@ -29,11 +30,17 @@ var validSupplierLocalities = []l10n.Code{
 	ZoneVI, // Álava
 }
 
-func validate(inv *bill.Invoice, zone l10n.Code) error {
+func validate(inv *bill.Invoice, zone l10n.Code, o *options) error {
 	if inv.Type == bill.InvoiceTypeCorrective {
 		return validationErr("corrective invoices not supported, use credit or debit notes")
 	}
 
+	if o.simplifiedSubstitution {
+		if err := validateSubstitution(inv); err != nil {
+			return err
+		}
+	}
+
 	if inv.Supplier == nil || inv.Supplier.TaxID == nil {
 		return nil // ignore
 	}
@@ -47,7 +54,7 @@ func validate(inv *bill.Invoice, zone l10n.Code) error {
 	}
 
 	if zone.In(ZoneSS, ZoneVI) {
-		if len(inv.Lines) > 1000 {
+		if !o.aggregateLines && len(inv.Lines)+len(inv.Discounts) > maxDetailLines {
 			return validationErr("line count over limit (1000) for tax locality")
 		}
 		if inv.Customer != nil && len(inv.Customer.Addresses) == 0 {
@@ -67,3 +74,24 @@ func validate(inv *bill.Invoice, zone l10n.Code) error {
 
 	return nil
 }
+
+func validateSubstitution(inv *bill.Invoice) error {
+	if inv.Type != bill.InvoiceTypeStandard {
+		return validationErr("simplified substitution: only standard invoices can replace simplified invoices")
+	}
+	if inv.HasTags(tax.TagSimplified) {
+		return validationErr("simplified substitution: invoice must not be simplified")
+	}
+	if len(inv.Preceding) == 0 {
+		return validationErr("simplified substitution: preceding simplified invoices are required")
+	}
+	if len(inv.Preceding) > maxSubstitutedInvoices {
+		return validationErr("simplified substitution: too many preceding invoices (%d), max %d", len(inv.Preceding), maxSubstitutedInvoices)
+	}
+	for i, p := range inv.Preceding {
+		if p.IssueDate == nil {
+			return validationErr("preceding: %d: issue date is required", i)
+		}
+	}
+	return nil
+}
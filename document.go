@@ -7,14 +7,17 @@ import (
 	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl/addons/es/tbai"
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
 	"github.com/invopop/gobl/head"
 	"github.com/invopop/gobl/l10n"
 	"github.com/invopop/xmldsig"
 )
 
 // Convert creates a new TicketBAI document from the provided GOBL Envelope.
-// The envelope must contain a valid Invoice.
-func (c *Client) Convert(env *gobl.Envelope) (*convert.TicketBAI, error) {
+// The envelope must contain a valid Invoice. Any conversion options provided
+// are applied after the client's, for example to set the regime keys of a
+// single invoice with convert.WithRegimeKeys.
+func (c *Client) Convert(env *gobl.Envelope, opts ...convert.Option) (*convert.TicketBAI, error) {
 	// Extract the Invoice
 	inv, ok := env.Extract().(*bill.Invoice)
 	if !ok {
@@ -24,6 +27,27 @@ func (c *Client) Convert(env *gobl.Envelope) (*convert.TicketBAI, error) {
 	if hasExistingStamps(env) {
 		return nil, ErrDuplicate.withMessage("already has stamps")
 	}
+	return c.convert(inv, opts)
+}
+
+// Reissue creates a new TicketBAI document for an invoice that was previously
+// registered and then cancelled, allowing it to be registered again with the
+// same series and number. The envelope must contain the cancellation stamp
+// added by Cancel. The resulting document should be fingerprinted with the
+// current chain data and signed as usual, which will replace the envelope's
+// previous stamps. Conversion options are applied as in Convert.
+func (c *Client) Reissue(env *gobl.Envelope, opts ...convert.Option) (*convert.TicketBAI, error) {
+	inv, ok := env.Extract().(*bill.Invoice)
+	if !ok {
+		return nil, ErrValidation.withMessage("only invoices are supported")
+	}
+	if !hasCancelStamp(env) {
+		return nil, ErrValidation.withMessage("invoice has not been cancelled")
+	}
+	return c.convert(inv, opts)
+}
+
+func (c *Client) convert(inv *bill.Invoice, opts []convert.Option) (*convert.TicketBAI, error) {
 	if inv.Supplier.TaxID.Country != l10n.ES.Tax() {
 		return nil, ErrValidation.withMessage("only spanish invoices are supported")
 	}
@@ -34,12 +58,16 @@ func (c *Client) Convert(env *gobl.Envelope) (*convert.TicketBAI, error) {
 		return nil, ErrValidation.withMessage("missing taxes")
 	}
 
-	zone := zoneFor(inv)
+	zone := c.zoneFor(inv)
 	if zone == "" {
 		return nil, ErrValidation.withMessage("invalid zone")
 	}
+	if err := c.matchZone(zone); err != nil {
+		return nil, err
+	}
 
-	out, err := convert.NewTicketBAI(inv, c.CurrentTime(), c.issuerRole, zone)
+	opts = append(append([]convert.Option{}, c.convOpts...), opts...)
+	out, err := convert.NewTicketBAI(inv, c.CurrentTime(), c.issuerRole, zone, opts...)
 	if err != nil {
 		if _, ok := err.(*convert.ValidationError); ok {
 			return nil, ErrValidation.withCause(err) //nolint:govet
@@ -60,6 +88,22 @@ func ZoneFor(env *gobl.Envelope) l10n.Code {
 	return zoneFor(inv)
 }
 
+// ResolveZone determines the zone of the envelope using the client's zone
+// resolver, if any, along with the source used to determine it.
+func (c *Client) ResolveZone(env *gobl.Envelope) (l10n.Code, ZoneSource) {
+	inv, ok := env.Extract().(*bill.Invoice)
+	if !ok {
+		return "", ZoneSourceNone
+	}
+	return c.zones.Resolve(inv)
+}
+
+// zoneFor determines the zone of the invoice using the client's zone resolver.
+func (c *Client) zoneFor(inv *bill.Invoice) l10n.Code {
+	zone, _ := c.zones.Resolve(inv)
+	return zone
+}
+
 // zoneFor determines the zone of the invoice.
 func zoneFor(inv *bill.Invoice) l10n.Code {
 	// Figure out the zone
@@ -77,17 +121,33 @@ func zoneFor(inv *bill.Invoice) l10n.Code {
 // document in the chain, the parameter should be nil. The document is updated
 // in place.
 func (c *Client) Fingerprint(d *convert.TicketBAI, prev *convert.ChainData) error {
+	if err := c.checkDevice(); err != nil {
+		return err
+	}
 	soft := c.buildSoftware()
-	return d.Fingerprint(soft, prev)
+	if err := d.Fingerprint(soft, prev); err != nil {
+		return err
+	}
+	d.HuellaTBAI.NumSerieDispositivo = c.device
+	return nil
 }
 
 // Sign is used to generate the XML DSig components of the final XML document.
 // This method will also update the GOBL Envelope with the QR codes that are
 // generated.
 func (c *Client) Sign(d *convert.TicketBAI, env *gobl.Envelope) error {
-	zone := ZoneFor(env)
+	zone, _ := c.ResolveZone(env)
+	if err := c.matchZone(zone); err != nil {
+		return err
+	}
 	dID := env.Head.UUID.String()
-	if err := d.Sign(dID, c.cert, c.issuerRole, zone, xmldsig.WithCurrentTime(d.IssueTimestamp)); err != nil {
+	var err error
+	if c.signer != nil {
+		err = d.SignWith(dID, c.signer, c.issuerRole, zone, xmldsig.WithCurrentTime(d.IssueTimestamp))
+	} else {
+		err = d.Sign(dID, c.cert, c.issuerRole, zone, xmldsig.WithCurrentTime(d.IssueTimestamp))
+	}
+	if err != nil {
 		return fmt.Errorf("signing: %w", err)
 	}
 
@@ -105,9 +165,30 @@ func (c *Client) Sign(d *convert.TicketBAI, env *gobl.Envelope) error {
 			Value:    codes.QRCode,
 		},
 	)
+	// A new registration supersedes any previous cancellation.
+	removeStamp(env, StampCancel)
 	return nil
 }
 
+func hasCancelStamp(env *gobl.Envelope) bool {
+	for _, stamp := range env.Head.Stamps {
+		if stamp.Provider == StampCancel {
+			return true
+		}
+	}
+	return false
+}
+
+func removeStamp(env *gobl.Envelope, provider cbc.Key) {
+	stamps := make([]*head.Stamp, 0, len(env.Head.Stamps))
+	for _, stamp := range env.Head.Stamps {
+		if stamp.Provider != provider {
+			stamps = append(stamps, stamp)
+		}
+	}
+	env.Head.Stamps = stamps
+}
+
 func hasExistingStamps(env *gobl.Envelope) bool {
 	for _, stamp := range env.Head.Stamps {
 		if stamp.Provider.In(tbai.StampCode, tbai.StampQR) {
@@ -0,0 +1,171 @@
+package ticketbai_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/invopop/gobl"
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/xmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReissue(t *testing.T) {
+	tc := loadTBAIClient(t, ticketbai.ZoneBI)
+	ctx := context.Background()
+
+	env := test.LoadEnvelope("sample-invoice2.json")
+
+	td, err := tc.Convert(env)
+	require.NoError(t, err)
+	require.NoError(t, tc.Fingerprint(td, nil))
+	require.NoError(t, tc.Sign(td, env))
+
+	t.Run("should refuse to reissue before cancelling", func(t *testing.T) {
+		_, err := tc.Reissue(env)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "invoice has not been cancelled")
+
+		_, err = tc.Convert(env)
+		assert.ErrorIs(t, err, ticketbai.ErrDuplicate)
+	})
+
+	t.Run("should reissue after cancelling", func(t *testing.T) {
+		cd, err := tc.GenerateCancelFromDocument(td)
+		require.NoError(t, err)
+		require.NoError(t, tc.FingerprintCancel(cd))
+		require.NoError(t, tc.SignCancel(cd, env))
+		_, err = tc.Cancel(ctx, env, cd)
+		require.NoError(t, err)
+
+		code := stampValue(env, tbai.StampCode)
+		assert.Equal(t, code, stampValue(env, ticketbai.StampCancel))
+
+		rd, err := tc.Reissue(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(rd, td.ChainData()))
+		require.NoError(t, tc.Sign(rd, env))
+
+		assert.Equal(t, td.Head().NumFactura, rd.Head().NumFactura)
+		assert.Equal(t, td.Head().NumFactura, rd.HuellaTBAI.EncadenamientoFacturaAnterior.NumFacturaAnterior)
+		assert.Empty(t, stampValue(env, ticketbai.StampCancel))
+		assert.NotEmpty(t, stampValue(env, tbai.StampCode))
+	})
+}
+
+func stampValue(env *gobl.Envelope, provider cbc.Key) string {
+	for _, stamp := range env.Head.Stamps {
+		if stamp.Provider == provider {
+			return stamp.Value
+		}
+	}
+	return ""
+}
+
+func TestConvertRegimeKeys(t *testing.T) {
+	env := test.LoadEnvelope("sample-invoice2.json") // calculated and validated
+
+	t.Run("should add the keys of a single invoice", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		td, err := tc.Convert(env, convert.WithRegimeKeys(convert.ClaveInvestmentGold))
+		require.NoError(t, err)
+		require.Len(t, td.Factura.DatosFactura.Claves.IDClave, 1)
+		assert.Equal(t, "04", td.Factura.DatosFactura.Claves.IDClave[0].ClaveRegimenIvaOpTrascendencia)
+	})
+
+	t.Run("should add the keys of the client", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI, ticketbai.WithRegimeKeys(convert.ClaveVATGroup))
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.Len(t, td.Factura.DatosFactura.Claves.IDClave, 1)
+		assert.Equal(t, "06", td.Factura.DatosFactura.Claves.IDClave[0].ClaveRegimenIvaOpTrascendencia)
+	})
+}
+
+func TestFingerprintDevice(t *testing.T) {
+	env := test.LoadEnvelope("sample-invoice2.json")
+
+	t.Run("should include the device serial number", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI, ticketbai.WithDeviceSerial("TILL-0001"))
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		assert.Equal(t, "TILL-0001", td.HuellaTBAI.NumSerieDispositivo)
+		assert.Equal(t, "12345678A", td.HuellaTBAI.Software.NIF)
+	})
+
+	t.Run("should use the foreign developer identity", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI, ticketbai.WithForeignDeveloper(&convert.IDOtro{
+			CodigoPais: "FR",
+			IDType:     "02",
+			ID:         "FR12345678901",
+		}))
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		assert.Empty(t, td.HuellaTBAI.Software.NIF)
+		assert.Equal(t, "FR12345678901", td.HuellaTBAI.Software.IDOtro.ID)
+	})
+
+	t.Run("should refuse long device serial numbers", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI, ticketbai.WithDeviceSerial(strings.Repeat("X", 31)))
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		err = tc.Fingerprint(td, nil)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+	})
+
+	t.Run("should count characters instead of bytes", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI, ticketbai.WithDeviceSerial(strings.Repeat("Ñ", 30)))
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		assert.Equal(t, strings.Repeat("Ñ", 30), td.HuellaTBAI.NumSerieDispositivo)
+	})
+}
+
+func TestSignWithSigner(t *testing.T) {
+	env := test.LoadEnvelope("sample-invoice2.json")
+
+	tc := loadTBAIClient(t, ticketbai.ZoneBI)
+	td, err := tc.Convert(env)
+	require.NoError(t, err)
+	require.NoError(t, tc.Fingerprint(td, nil))
+	require.NoError(t, tc.Sign(td, env))
+
+	pass, err := os.ReadFile(
+		test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad_pin.txt"),
+	)
+	require.NoError(t, err)
+	cert, err := xmldsig.LoadCertificate(
+		test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad.p12"),
+		string(pass),
+	)
+	require.NoError(t, err)
+	signer, err := convert.NewLocalSigner(cert)
+	require.NoError(t, err)
+
+	t.Run("should produce the same signature as the certificate", func(t *testing.T) {
+		env := test.LoadEnvelope("sample-invoice2.json")
+		sc := loadTBAIClient(t, ticketbai.ZoneBI, ticketbai.WithSigner(signer))
+		sd, err := sc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, sc.Fingerprint(sd, nil))
+		require.NoError(t, sc.Sign(sd, env))
+		assert.Equal(t, td.SignatureValue(), sd.SignatureValue())
+	})
+
+	t.Run("should prepare the gateway connection", func(t *testing.T) {
+		_, err := ticketbai.New(&ticketbai.Software{NIF: "12345678A"}, ticketbai.ZoneBI,
+			ticketbai.WithSigner(signer),
+		)
+		assert.NoError(t, err)
+	})
+}
@@ -10,10 +10,12 @@ import (
 
 // Main error types return by this package.
 var (
-	ErrValidation = newError("validation")
-	ErrDuplicate  = newError("duplicate")
-	ErrConnection = newError("connection")
-	ErrInternal   = newError("internal")
+	ErrValidation       = newError("validation")
+	ErrDuplicate        = newError("duplicate")
+	ErrNotFound         = newError("not-found")
+	ErrAlreadyCancelled = newError("already-cancelled")
+	ErrConnection       = newError("connection")
+	ErrInternal         = newError("internal")
 )
 
 // Error allows for structured responses to better handle errors upstream.
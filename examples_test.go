@@ -9,10 +9,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/invopop/gobl"
 	ticketbai "github.com/invopop/gobl.ticketbai"
 	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl.ticketbai/internal/gateways"
 	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/l10n"
 	"github.com/invopop/xmldsig"
 	"github.com/lestrrat-go/helium"
 	"github.com/lestrrat-go/helium/xsd"
@@ -34,14 +36,13 @@ func TestXMLGeneration(t *testing.T) {
 	examples, err := lookupExamples()
 	require.NoError(t, err)
 
-	tbai, err := loadTBAIClient()
-	require.NoError(t, err)
-
 	for _, example := range examples {
 		name := fmt.Sprintf("should convert %s example file successfully", example)
 
 		t.Run(name, func(t *testing.T) {
-			data, err := convertExample(tbai, example)
+			env := test.LoadEnvelope(example)
+			tbai := loadTBAIClient(t, ticketbai.ZoneFor(env))
+			data, err := convertExample(tbai, env)
 			require.NoError(t, err)
 
 			// Validate against the TicketBAI XSD on every run so
@@ -67,42 +68,46 @@ func TestXMLGeneration(t *testing.T) {
 	}
 }
 
-func loadTBAIClient() (*ticketbai.Client, error) {
+func loadTBAIClient(t *testing.T, zone l10n.Code, opts ...ticketbai.Option) *ticketbai.Client {
+	t.Helper()
+
 	pass, err := os.ReadFile(
 		test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad_pin.txt"),
 	)
-	if err != nil {
-		return nil, err
-	}
+	require.NoError(t, err)
 
 	cert, err := xmldsig.LoadCertificate(
 		test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad.p12"),
 		string(pass),
 	)
-	if err != nil {
-		return nil, err
-	}
+	require.NoError(t, err)
 
 	ts, err := time.Parse(time.RFC3339, "2022-02-01T04:00:00Z")
-	if err != nil {
-		return nil, err
-	}
+	require.NoError(t, err)
+
+	opts = append([]ticketbai.Option{
+		ticketbai.WithCertificate(cert),
+		ticketbai.WithCurrentTime(ts),
+		ticketbai.WithThirdPartyIssuer(),
+		ticketbai.WithConnection(new(ticketbai.TestConnection)),
+	}, opts...)
 
-	return ticketbai.New(&ticketbai.Software{
+	tc, err := ticketbai.New(&ticketbai.Software{
 		Licenses: ticketbai.Licenses{
 			gateways.EnvironmentSandbox: {
-				ticketbai.ZoneBI: "My License",
+				zone: "My License",
 			},
 		},
 		NIF:     "12345678A",
 		Name:    "My Software",
 		Version: "1.0",
 	},
-		ticketbai.ZoneBI,
-		ticketbai.WithCertificate(cert),
-		ticketbai.WithCurrentTime(ts),
-		ticketbai.WithThirdPartyIssuer(),
+		zone,
+		opts...,
 	)
+	require.NoError(t, err)
+
+	return tc
 }
 
 func lookupExamples() ([]string, error) {
@@ -118,9 +123,7 @@ func lookupExamples() ([]string, error) {
 	return examples, nil
 }
 
-func convertExample(tc *ticketbai.Client, example string) ([]byte, error) {
-	env := test.LoadEnvelope(example)
-
+func convertExample(tc *ticketbai.Client, env *gobl.Envelope) ([]byte, error) {
 	td, err := tc.Convert(env)
 	if err != nil {
 		return nil, err
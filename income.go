@@ -0,0 +1,129 @@
+package ticketbai
+
+import (
+	"context"
+
+	"github.com/invopop/gobl"
+	"github.com/invopop/gobl.ticketbai/internal/gateways"
+	"github.com/invopop/gobl/org"
+)
+
+// Income describes income tied to an economic activity that is not
+// documented with an invoice, such as subsidies or capital gains.
+type Income = gateways.Income
+
+// PostIncome registers the income of a self-employed party in Bizkaia that
+// is not documented with a TicketBAI invoice, as part of the Modelo 140 LROE
+// (subchapter 1.3). The party must be a natural person with a Spanish tax ID.
+func (c *Client) PostIncome(ctx context.Context, party *org.Party, inc *Income) (*Receipt, error) {
+	gw, err := c.incomeConnection(party, inc)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gw.PostIncome(ctx, party, inc)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// CancelIncome cancels the record of income previously registered with
+// PostIncome, identified by its date and reference.
+func (c *Client) CancelIncome(ctx context.Context, party *org.Party, inc *Income) (*Receipt, error) {
+	gw, err := c.incomeConnection(party, inc)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gw.CancelIncome(ctx, party, inc)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// FetchIncome retrieves a page of the records of income without invoice
+// registered by the party in the given year.
+func (c *Client) FetchIncome(ctx context.Context, party *org.Party, year string, page int) ([]*Income, error) {
+	gw, err := c.incomeGateway()
+	if err != nil {
+		return nil, err
+	}
+	if party == nil || !hasSpanishTaxID(party) {
+		return nil, ErrValidation.withMessage("party must have a spanish tax ID")
+	}
+	out, err := gw.FetchIncome(ctx, party, year, page)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return out, nil
+}
+
+// PostIncomeInvoice registers an invoice issued by a self-employed party in
+// Bizkaia without TicketBAI, such as those issued before the party was
+// required to use it, as part of the Modelo 140 LROE (subchapter 1.2). The
+// record carries the same invoice details as a TicketBAI document, but is
+// neither fingerprinted nor signed, and no stamps are added to the envelope.
+// Income details can be provided with WithRenta.
+func (c *Client) PostIncomeInvoice(ctx context.Context, env *gobl.Envelope, opts ...PostOption) (*Receipt, error) {
+	gw, err := c.incomeGateway()
+	if err != nil {
+		return nil, err
+	}
+	d, err := c.Convert(env)
+	if err != nil {
+		return nil, err
+	}
+	inv, err := c.postInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gw.PostIncomeInvoice(ctx, inv, d, newPostOptions(opts).renta)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// CancelIncomeInvoice cancels the record of an invoice previously registered
+// with PostIncomeInvoice.
+func (c *Client) CancelIncomeInvoice(ctx context.Context, env *gobl.Envelope) (*Receipt, error) {
+	gw, err := c.incomeGateway()
+	if err != nil {
+		return nil, err
+	}
+	inv, err := c.postInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gw.CancelIncomeInvoice(ctx, inv)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// incomeConnection provides the client's gateway connection if it supports
+// income without invoice, checking the details provided.
+func (c *Client) incomeConnection(party *org.Party, inc *Income) (gateways.IncomeConnection, error) {
+	gw, err := c.incomeGateway()
+	if err != nil {
+		return nil, err
+	}
+	if party == nil || !hasSpanishTaxID(party) {
+		return nil, ErrValidation.withMessage("party must have a spanish tax ID")
+	}
+	if inc == nil || inc.Date.IsZero() {
+		return nil, ErrValidation.withMessage("income: missing date")
+	}
+	return gw, nil
+}
+
+// incomeGateway provides the client's gateway connection if it supports the
+// income of natural persons not documented with a TicketBAI invoice.
+func (c *Client) incomeGateway() (gateways.IncomeConnection, error) {
+	gw, ok := c.gw.(gateways.IncomeConnection)
+	if !ok || c.zone != ZoneBI {
+		return nil, ErrValidation.withMessage("income without TicketBAI is only supported in Bizkaia")
+	}
+	return gw, nil
+}
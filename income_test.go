@@ -0,0 +1,75 @@
+package ticketbai_test
+
+import (
+	"context"
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/num"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostIncome(t *testing.T) {
+	ctx := context.Background()
+	inv := test.LoadEnvelope("invoice-bi-pf-modelo140.json").Extract().(*bill.Invoice)
+	party := inv.Supplier
+
+	t.Run("registers income", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		inc := &ticketbai.Income{
+			Date:    cal.MakeDate(2024, 5, 10),
+			Concept: "01",
+			Amount:  num.MakeAmount(150000, 2),
+		}
+
+		_, err := tc.PostIncome(ctx, party, inc)
+		require.NoError(t, err)
+
+		out, err := tc.FetchIncome(ctx, party, "2024", 1)
+		require.NoError(t, err)
+		require.Len(t, out, 1)
+		assert.Equal(t, "01", out[0].Concept)
+
+		_, err = tc.CancelIncome(ctx, party, inc)
+		require.NoError(t, err)
+
+		_, err = tc.CancelIncome(ctx, party, inc)
+		assert.ErrorIs(t, err, ticketbai.ErrNotFound)
+	})
+
+	t.Run("requires a date", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+
+		_, err := tc.PostIncome(ctx, party, &ticketbai.Income{Concept: "01"})
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "income: missing date")
+	})
+
+	t.Run("registers invoices issued without TicketBAI", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("invoice-bi-pf-modelo140.json")
+
+		_, err := tc.PostIncomeInvoice(ctx, env)
+		require.NoError(t, err)
+		assert.Empty(t, env.Head.Stamps)
+
+		_, err = tc.CancelIncomeInvoice(ctx, env)
+		require.NoError(t, err)
+	})
+
+	t.Run("only in Bizkaia", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneSS)
+		inc := &ticketbai.Income{Date: cal.MakeDate(2024, 5, 10), Concept: "01"}
+
+		_, err := tc.PostIncome(ctx, party, inc)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "only supported in Bizkaia")
+
+		_, err = tc.FetchIncome(ctx, party, "2024", 1)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+	})
+}
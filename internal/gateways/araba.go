@@ -72,24 +72,36 @@ func newAraba(env Environment, tlsConfig *tls.Config) *ArabaConn {
 }
 
 // Post sends the complete TicketBAI document to the Araba API.
-func (c *ArabaConn) Post(ctx context.Context, _ *bill.Invoice, doc *convert.TicketBAI) error {
+func (c *ArabaConn) Post(ctx context.Context, _ *bill.Invoice, doc *convert.TicketBAI) (*Receipt, error) {
 	payload, err := doc.Bytes()
 	if err != nil {
-		return fmt.Errorf("generating payload: %w", err)
+		return nil, fmt.Errorf("generating payload: %w", err)
 	}
 	return c.post(ctx, arabaExecutePath, payload)
 }
 
 // Cancel will send a request to the Araba API to cancel a previously issued document.
-func (c *ArabaConn) Cancel(ctx context.Context, _ *bill.Invoice, doc *convert.AnulaTicketBAI) error {
+func (c *ArabaConn) Cancel(ctx context.Context, _ *bill.Invoice, doc *convert.AnulaTicketBAI) (*Receipt, error) {
 	payload, err := doc.Bytes()
 	if err != nil {
-		return fmt.Errorf("generating payload: %w", err)
+		return nil, fmt.Errorf("generating payload: %w", err)
 	}
 	return c.post(ctx, arabaCancelPath, payload)
 }
 
-func (c *ArabaConn) post(ctx context.Context, path string, payload []byte) error {
+// PostSigned sends the exact XML of the signed TicketBAI document.
+func (c *ArabaConn) PostSigned(ctx context.Context, _ *bill.Invoice, doc *convert.SignedDocument) (*Receipt, error) {
+	payload, _ := doc.Bytes()
+	return c.post(ctx, arabaExecutePath, payload)
+}
+
+// CancelSigned sends the exact XML of the signed cancellation document.
+func (c *ArabaConn) CancelSigned(ctx context.Context, _ *bill.Invoice, doc *convert.SignedCancelDocument) (*Receipt, error) {
+	payload, _ := doc.Bytes()
+	return c.post(ctx, arabaCancelPath, payload)
+}
+
+func (c *ArabaConn) post(ctx context.Context, path string, payload []byte) (*Receipt, error) {
 	out := new(ArabaResponse)
 	req := c.client.R().
 		SetContext(ctx).
@@ -101,20 +113,24 @@ func (c *ArabaConn) post(ctx context.Context, path string, payload []byte) error
 
 	res, err := req.Post(path)
 	if err != nil {
-		return ErrConnection.withCause(err)
+		return nil, ErrConnection.withCause(err)
 	}
 	if res.StatusCode() != http.StatusOK {
-		return ErrValidation.withCode(strconv.Itoa(res.StatusCode()))
+		return nil, ErrValidation.withCode(strconv.Itoa(res.StatusCode()))
 	}
 
 	if out.Output.Status != arabaStatusReceived {
 		err := ErrValidation
 		if len(out.Output.Errors) > 0 {
 			e1 := out.Output.Errors[0]
-			err = err.withMessage(e1.Description).withCode(e1.Code)
+			err = tbaiError(e1.Code).withMessage(e1.Description).withCode(e1.Code)
 		}
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &Receipt{
+		ID:         out.Output.ID,
+		ReceivedAt: out.Output.Data,
+		CSV:        out.Output.CSV,
+	}, nil
 }
@@ -12,6 +12,9 @@ import (
 	"github.com/invopop/gobl.ticketbai/internal/gateways/ebizkaia"
 	"github.com/invopop/gobl/addons/es/tbai"
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/num"
+	"github.com/invopop/gobl/org"
 	"github.com/invopop/gobl/regimes/es"
 	"github.com/invopop/gobl/tax"
 	"github.com/invopop/xmldsig"
@@ -38,6 +41,8 @@ const (
 	// Response codes of interest
 	eBizkaiaN3RespCodeTechnical  = "B4_1000004" // “Error técnico”
 	eBizkaiaN3RespCodeDuplicated = "B4_2000003" // “El registro no puede existir en el sistema”
+	eBizkaiaN3RespCodeNotFound   = "B4_2000002" // “El registro debe existir en el sistema”
+	eBizkaiaN3RespCodeCancelled  = "B4_2000004" // “El registro ya está anulado”
 	eBizkaiaN3RespCodeOther      = "N3_0000011" // “Otros, consulte el mensaje recibido (...)”
 )
 
@@ -52,6 +57,12 @@ type EBizkaiaConn struct {
 	client *resty.Client
 }
 
+var (
+	_ ReceivedConnection = (*EBizkaiaConn)(nil)
+	_ IncomeConnection   = (*EBizkaiaConn)(nil)
+	_ RentaConnection    = (*EBizkaiaConn)(nil)
+)
+
 func newEbizkaia(env Environment, tlsConfig *tls.Config) *EBizkaiaConn {
 	c := new(EBizkaiaConn)
 	c.client = resty.New()
@@ -71,12 +82,35 @@ func newEbizkaia(env Environment, tlsConfig *tls.Config) *EBizkaiaConn {
 
 // Post sends the complete TicketBAI document to the remote end-point. We assume
 // the document has been signed and prepared.
-func (c *EBizkaiaConn) Post(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI) error {
+func (c *EBizkaiaConn) Post(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI) (*Receipt, error) {
+	return c.PostWithRenta(ctx, inv, doc, nil)
+}
+
+// PostWithRenta sends the TicketBAI document along with the income details
+// reported under Modelo 140, ignored for companies.
+func (c *EBizkaiaConn) PostWithRenta(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI, renta []*Renta) (*Receipt, error) {
 	payload, err := doc.Bytes()
 	if err != nil {
-		return fmt.Errorf("generating payload: %w", err)
+		return nil, fmt.Errorf("generating payload: %w", err)
 	}
+	return c.post(ctx, inv, doc, payload, renta)
+}
 
+// PostSigned sends the exact XML of the signed TicketBAI document.
+func (c *EBizkaiaConn) PostSigned(ctx context.Context, inv *bill.Invoice, doc *convert.SignedDocument) (*Receipt, error) {
+	return c.PostSignedWithRenta(ctx, inv, doc, nil)
+}
+
+// PostSignedWithRenta sends the exact XML of the signed TicketBAI document
+// along with the income details reported under Modelo 140, ignored for
+// companies.
+func (c *EBizkaiaConn) PostSignedWithRenta(ctx context.Context, inv *bill.Invoice, doc *convert.SignedDocument, renta []*Renta) (*Receipt, error) {
+	payload, _ := doc.Bytes()
+	return c.post(ctx, inv, doc.TicketBAI, payload, renta)
+}
+
+func (c *EBizkaiaConn) post(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI, payload []byte, renta []*Renta) (*Receipt, error) {
+	var err error
 	model := modelFor(inv.Supplier.TaxID)
 	sup := &ebizkaia.Supplier{
 		Year:     doc.IssueYear(),
@@ -85,33 +119,45 @@ func (c *EBizkaiaConn) Post(ctx context.Context, inv *bill.Invoice, doc *convert
 		Model:    model,
 		Activity: inv.Supplier.Ext.Get(tbai.ExtKeyBIActivity).String(),
 	}
+	if model == ebizkaia.Modelo140 {
+		sup.Person = ebizkaia.NewPersonName(inv.Supplier)
+		sup.Renta, err = ebizkaia.NewRenta(inv, rentaDetails(renta))
+		if err != nil {
+			return nil, ErrValidation.withCause(err)
+		}
+	}
+
 	req, err := ebizkaia.NewCreateRequest(sup, payload)
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("create request: %w", err)
 	}
 
-	var resp interface {
-		FirstErrorCode() string
-		FirstErrorDescription() string
-	}
+	var resp ebizkaia.Response
 	if model == ebizkaia.Modelo140 {
 		resp = new(ebizkaia.LROEPF140IngresosConFacturaConSGAltaRespuesta)
 	} else {
 		resp = new(ebizkaia.LROEPJ240FacturasEmitidasConSGAltaRespuesta)
 	}
 
-	err = c.sendRequest(ctx, req, eBizkaiaExecutePath, resp)
-	if errors.Is(err, ErrValidation) {
-		if resp.FirstErrorCode() == eBizkaiaN3RespCodeDuplicated {
-			return ErrDuplicate
-		}
+	return c.register(ctx, req, resp)
+}
 
-		if resp.FirstErrorDescription() != "" {
-			return ErrValidation.withCode(resp.FirstErrorCode()).withMessage(resp.FirstErrorDescription())
+// rentaDetails prepares the income details for Modelo 140.
+func rentaDetails(renta []*Renta) []*ebizkaia.RentaDetails {
+	out := make([]*ebizkaia.RentaDetails, 0, len(renta))
+	for _, r := range renta {
+		if r == nil {
+			continue
 		}
+		out = append(out, &ebizkaia.RentaDetails{
+			Activity:     r.Activity,
+			Territory:    r.Territory,
+			CadastralRef: r.CadastralRef,
+			IncomeAmount: r.IncomeAmount,
+			IncomeCause:  r.IncomeCause,
+		})
 	}
-
-	return err
+	return out
 }
 
 // Fetch retrieves the TicketBAI from the remote end-point for the given
@@ -130,7 +176,7 @@ func (c *EBizkaiaConn) Fetch(ctx context.Context, nif, name, year string, page i
 	}
 
 	resp := ebizkaia.LROEPJ240FacturasEmitidasConSGConsultaRespuesta{}
-	if err := c.sendRequest(ctx, d, eBizkaiaQueryPath, &resp); err != nil {
+	if _, err := c.sendRequest(ctx, d, eBizkaiaQueryPath, &resp); err != nil {
 		return nil, fmt.Errorf("sending fetch request: %w", err)
 	}
 
@@ -144,27 +190,411 @@ func (c *EBizkaiaConn) Fetch(ctx context.Context, nif, name, year string, page i
 
 // Cancel sends the cancellation request for the TickeBAI invoice to the remote
 // end-point.
-func (c *EBizkaiaConn) Cancel(ctx context.Context, inv *bill.Invoice, doc *convert.AnulaTicketBAI) error {
+func (c *EBizkaiaConn) Cancel(ctx context.Context, inv *bill.Invoice, doc *convert.AnulaTicketBAI) (*Receipt, error) {
 	payload, err := doc.Bytes()
 	if err != nil {
-		return fmt.Errorf("generating payload: %w", err)
+		return nil, fmt.Errorf("generating payload: %w", err)
 	}
+	return c.cancel(ctx, inv, doc, payload)
+}
+
+// CancelSigned sends the exact XML of the signed cancellation document.
+func (c *EBizkaiaConn) CancelSigned(ctx context.Context, inv *bill.Invoice, doc *convert.SignedCancelDocument) (*Receipt, error) {
+	payload, _ := doc.Bytes()
+	return c.cancel(ctx, inv, doc.AnulaTicketBAI, payload)
+}
 
+func (c *EBizkaiaConn) cancel(ctx context.Context, inv *bill.Invoice, doc *convert.AnulaTicketBAI, payload []byte) (*Receipt, error) {
+	model := modelFor(inv.Supplier.TaxID)
 	sup := &ebizkaia.Supplier{
 		Year:  doc.IssueYear(),
 		NIF:   doc.IDFactura.Emisor.NIF,
 		Name:  doc.IDFactura.Emisor.ApellidosNombreRazonSocial,
-		Model: modelFor(inv.Supplier.TaxID),
+		Model: model,
+	}
+	if model == ebizkaia.Modelo140 {
+		sup.Person = ebizkaia.NewPersonName(inv.Supplier)
 	}
+
 	req, err := ebizkaia.NewCancelRequest(sup, payload)
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var resp ebizkaia.Response
+	if model == ebizkaia.Modelo140 {
+		resp = new(ebizkaia.LROEPF140IngresosConFacturaConSGAnulacionRespuesta)
+	} else {
+		resp = new(ebizkaia.LROEPJ240FacturasEmitidasConSGAnulacionRespuesta)
+	}
+
+	return c.register(ctx, req, resp)
+}
+
+// PostReceived registers the invoice received by the customer on the given
+// date in chapter 2 of the LROE, as a received invoice under Modelo 240 or
+// as an expense under Modelo 140.
+func (c *EBizkaiaConn) PostReceived(ctx context.Context, inv *bill.Invoice, received cal.Date) (*Receipt, error) {
+	sup := receivedSupplier(inv)
+
+	rec, err := ebizkaia.NewFacturaRecibida(inv, sup.Model, received)
+	if err != nil {
+		return nil, ErrValidation.withCause(err)
+	}
+
+	req, err := ebizkaia.NewReceivedCreateRequest(sup, rec)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var resp ebizkaia.Response
+	if sup.Model == ebizkaia.Modelo140 {
+		resp = new(ebizkaia.LROEPF140GastosConFacturaAltaModifRespuesta)
+	} else {
+		resp = new(ebizkaia.LROEPJ240FacturasRecibidasAltaModifRespuesta)
+	}
+
+	return c.register(ctx, req, resp)
+}
+
+// CancelReceived cancels the record of an invoice previously registered with
+// PostReceived.
+func (c *EBizkaiaConn) CancelReceived(ctx context.Context, inv *bill.Invoice) (*Receipt, error) {
+	sup := receivedSupplier(inv)
+
+	id, err := ebizkaia.NewIDRecibida(inv)
+	if err != nil {
+		return nil, ErrValidation.withCause(err)
+	}
+
+	req, err := ebizkaia.NewReceivedCancelRequest(sup, id)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	var resp ebizkaia.Response
+	if sup.Model == ebizkaia.Modelo140 {
+		resp = new(ebizkaia.LROEPF140GastosConFacturaAnulacionRespuesta)
+	} else {
+		resp = new(ebizkaia.LROEPJ240FacturasRecibidasAnulacionRespuesta)
+	}
+
+	return c.register(ctx, req, resp)
+}
+
+// FetchReceived retrieves the records of the invoices received by the party
+// in the given year.
+func (c *EBizkaiaConn) FetchReceived(ctx context.Context, party *org.Party, year string, page int) ([]*ReceivedInvoice, error) {
+	sup := &ebizkaia.Supplier{
+		Year:  year,
+		NIF:   party.TaxID.Code.String(),
+		Name:  party.Name,
+		Model: modelFor(party.TaxID),
+	}
+	if sup.Model == ebizkaia.Modelo140 {
+		sup.Person = ebizkaia.NewPersonName(party)
+	}
+
+	req, err := ebizkaia.NewReceivedFetchRequest(sup, page, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch request: %w", err)
+	}
+
+	var recs []*ebizkaia.FacturaRecibidaType
+	if sup.Model == ebizkaia.Modelo140 {
+		resp := new(ebizkaia.LROEPF140GastosConFacturaConsultaRespuesta)
+		if _, err := c.sendRequest(ctx, req, eBizkaiaQueryPath, resp); err != nil {
+			return nil, fmt.Errorf("sending fetch request: %w", err)
+		}
+		recs = resp.Records()
+	} else {
+		resp := new(ebizkaia.LROEPJ240FacturasRecibidasConsultaRespuesta)
+		if _, err := c.sendRequest(ctx, req, eBizkaiaQueryPath, resp); err != nil {
+			return nil, fmt.Errorf("sending fetch request: %w", err)
+		}
+		recs = resp.Records()
+	}
+
+	out := make([]*ReceivedInvoice, len(recs))
+	for i, rec := range recs {
+		out[i] = newReceivedInvoice(rec)
+	}
+	return out, nil
+}
+
+// PostIncome registers the income without invoice of a natural person under
+// Modelo 140.
+func (c *EBizkaiaConn) PostIncome(ctx context.Context, party *org.Party, inc *Income) (*Receipt, error) {
+	sup, err := incomeSupplier(party, inc)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := newIngresoSinFactura(party, inc)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := ebizkaia.NewIncomeCreateRequest(sup, rec)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	return c.register(ctx, req, new(ebizkaia.LROEPF140IngresosSinFacturaAltaModifRespuesta))
+}
+
+// CancelIncome cancels the record of income registered with PostIncome.
+func (c *EBizkaiaConn) CancelIncome(ctx context.Context, party *org.Party, inc *Income) (*Receipt, error) {
+	sup, err := incomeSupplier(party, inc)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := ebizkaia.NewIncomeCancelRequest(sup, newIDIngresoSinFactura(inc))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	return c.register(ctx, req, new(ebizkaia.LROEPF140IngresosSinFacturaAnulacionRespuesta))
+}
+
+// FetchIncome retrieves a page of the records of income without invoice of a
+// natural person under Modelo 140.
+func (c *EBizkaiaConn) FetchIncome(ctx context.Context, party *org.Party, year string, page int) ([]*Income, error) {
+	if modelFor(party.TaxID) != ebizkaia.Modelo140 {
+		return nil, ErrValidation.withMessage("income without invoice is only supported under Modelo 140")
+	}
+	sup := &ebizkaia.Supplier{
+		Year:   year,
+		NIF:    party.TaxID.Code.String(),
+		Name:   party.Name,
+		Model:  ebizkaia.Modelo140,
+		Person: ebizkaia.NewPersonName(party),
+	}
+
+	req, err := ebizkaia.NewIncomeFetchRequest(sup, page)
+	if err != nil {
+		return nil, fmt.Errorf("fetch request: %w", err)
+	}
+
+	resp := new(ebizkaia.LROEPF140IngresosSinFacturaConsultaRespuesta)
+	if _, err := c.sendRequest(ctx, req, eBizkaiaQueryPath, resp); err != nil {
+		return nil, fmt.Errorf("sending fetch request: %w", err)
+	}
+
+	recs := resp.Records()
+	out := make([]*Income, len(recs))
+	for i, rec := range recs {
+		if out[i], err = newIncome(rec); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// PostIncomeInvoice registers an invoice issued by a natural person without
+// TicketBAI under Modelo 140, using the details of its unsigned document.
+func (c *EBizkaiaConn) PostIncomeInvoice(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI, renta []*Renta) (*Receipt, error) {
+	sup, err := incomeInvoiceSupplier(inv)
+	if err != nil {
+		return nil, err
+	}
+	details, err := ebizkaia.NewRenta(inv, rentaDetails(renta))
+	if err != nil {
+		return nil, ErrValidation.withCause(err)
+	}
+
+	req, err := ebizkaia.NewIncomeInvoiceCreateRequest(sup, ebizkaia.NewIngresoConFacturaSinSG(doc, details))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	return c.register(ctx, req, new(ebizkaia.LROEPF140IngresosConFacturaSinSGAltaModifRespuesta))
+}
+
+// CancelIncomeInvoice cancels the record of an invoice registered with
+// PostIncomeInvoice.
+func (c *EBizkaiaConn) CancelIncomeInvoice(ctx context.Context, inv *bill.Invoice) (*Receipt, error) {
+	sup, err := incomeInvoiceSupplier(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := ebizkaia.NewIncomeInvoiceCancelRequest(sup, &ebizkaia.IDIngresoConFacturaSinSGType{
+		SerieFactura:           inv.Series.String(),
+		NumFactura:             inv.Code.String(),
+		FechaExpedicionFactura: convert.FormatDate(inv.IssueDate),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	return c.register(ctx, req, new(ebizkaia.LROEPF140IngresosConFacturaSinSGAnulacionRespuesta))
+}
+
+// incomeInvoiceSupplier prepares the details of the natural person who issued
+// the invoice, which is only possible under Modelo 140.
+func incomeInvoiceSupplier(inv *bill.Invoice) (*ebizkaia.Supplier, error) {
+	if modelFor(inv.Supplier.TaxID) != ebizkaia.Modelo140 {
+		return nil, ErrValidation.withMessage("invoices without TicketBAI are only supported under Modelo 140")
+	}
+	return &ebizkaia.Supplier{
+		Year:     fmt.Sprintf("%d", inv.IssueDate.Year),
+		NIF:      inv.Supplier.TaxID.Code.String(),
+		Name:     inv.Supplier.Name,
+		Model:    ebizkaia.Modelo140,
+		Activity: inv.Supplier.Ext.Get(tbai.ExtKeyBIActivity).String(),
+		Person:   ebizkaia.NewPersonName(inv.Supplier),
+	}, nil
+}
+
+// incomeSupplier prepares the details of the natural person presenting the
+// income, which is only possible under Modelo 140.
+func incomeSupplier(party *org.Party, inc *Income) (*ebizkaia.Supplier, error) {
+	if modelFor(party.TaxID) != ebizkaia.Modelo140 {
+		return nil, ErrValidation.withMessage("income without invoice is only supported under Modelo 140")
+	}
+	return &ebizkaia.Supplier{
+		Year:     fmt.Sprintf("%d", inc.Date.Year),
+		NIF:      party.TaxID.Code.String(),
+		Name:     party.Name,
+		Model:    ebizkaia.Modelo140,
+		Activity: party.Ext.Get(tbai.ExtKeyBIActivity).String(),
+		Person:   ebizkaia.NewPersonName(party),
+	}, nil
+}
+
+func newIngresoSinFactura(party *org.Party, inc *Income) (*ebizkaia.IngresoSinFacturaType, error) {
+	if inc.Concept == "" {
+		return nil, ErrValidation.withMessage("income: missing concept")
+	}
+	if inc.Amount.Value() <= 0 {
+		return nil, ErrValidation.withMessage("income: amount must be positive")
+	}
+	activity := inc.Activity
+	if activity == "" {
+		activity = party.Ext.Get(tbai.ExtKeyBIActivity).String()
+	}
+	if activity == "" {
+		return nil, ErrValidation.withMessage("income: missing activity")
+	}
+
+	return &ebizkaia.IngresoSinFacturaType{
+		IDIngreso:          newIDIngresoSinFactura(inc),
+		ConceptoIngreso:    inc.Concept,
+		DescripcionIngreso: inc.Description,
+		ImporteIngreso:     inc.Amount.Rescale(2).String(),
+		Renta: &ebizkaia.RentaIngresosType{
+			DetalleRenta: []*ebizkaia.DetalleRentaIngresosType{
+				{
+					TerritorioAltaActividad:        inc.Territory,
+					Epigrafe:                       activity,
+					NumeroFijoOReferenciaCatastral: inc.CadastralRef,
+				},
+			},
+		},
+	}, nil
+}
+
+func newIDIngresoSinFactura(inc *Income) *ebizkaia.IDIngresoSinFacturaType {
+	return &ebizkaia.IDIngresoSinFacturaType{
+		FechaIngreso:  convert.FormatDate(inc.Date),
+		NumeroIngreso: inc.Reference,
+	}
+}
+
+func newIncome(rec *ebizkaia.IngresoSinFacturaType) (*Income, error) {
+	inc := &Income{
+		Concept:     rec.ConceptoIngreso,
+		Description: rec.DescripcionIngreso,
+	}
+	var err error
+	if id := rec.IDIngreso; id != nil {
+		if inc.Date, err = convert.ParseDate(id.FechaIngreso); err != nil {
+			return nil, fmt.Errorf("income date: %w", err)
+		}
+		inc.Reference = id.NumeroIngreso
+	}
+	if inc.Amount, err = num.AmountFromString(rec.ImporteIngreso); err != nil {
+		return nil, fmt.Errorf("income amount: %w", err)
+	}
+	if rec.Renta != nil && len(rec.Renta.DetalleRenta) > 0 {
+		d := rec.Renta.DetalleRenta[0]
+		inc.Activity = d.Epigrafe
+		inc.Territory = d.TerritorioAltaActividad
+		inc.CadastralRef = d.NumeroFijoOReferenciaCatastral
+	}
+	return inc, nil
+}
+
+// receivedSupplier prepares the details of the invoice's customer, who is the
+// one presenting the records of received invoices.
+func receivedSupplier(inv *bill.Invoice) *ebizkaia.Supplier {
+	sup := &ebizkaia.Supplier{
+		Year:     fmt.Sprintf("%d", inv.IssueDate.Year),
+		NIF:      inv.Customer.TaxID.Code.String(),
+		Name:     inv.Customer.Name,
+		Model:    modelFor(inv.Customer.TaxID),
+		Activity: inv.Customer.Ext.Get(tbai.ExtKeyBIActivity).String(),
+	}
+	if sup.Model == ebizkaia.Modelo140 {
+		sup.Person = ebizkaia.NewPersonName(inv.Customer)
+	}
+	return sup
+}
+
+func newReceivedInvoice(rec *ebizkaia.FacturaRecibidaType) *ReceivedInvoice {
+	ri := new(ReceivedInvoice)
+	if e := rec.EmisorFacturaRecibida; e != nil {
+		ri.SupplierNIF = e.NIF
+		if e.IDOtro != nil {
+			ri.SupplierNIF = e.IDOtro.ID
+		}
+		ri.SupplierName = e.ApellidosNombreRazonSocial
+	}
+	if h := rec.CabeceraFactura; h != nil {
+		ri.Series = h.SerieFactura
+		ri.Code = h.NumFactura
+		ri.IssueDate = h.FechaExpedicionFactura
+		ri.ReceivedDate = h.FechaRecepcion
+		ri.Type = h.TipoFactura
+	}
+	if d := rec.DatosFactura; d != nil {
+		ri.Total = d.ImporteTotalFactura
+	}
+	return ri
+}
+
+// register sends a registration request (alta or anulación) and classifies any
+// errors reported in the response registry.
+func (c *EBizkaiaConn) register(ctx context.Context, req *ebizkaia.Request, resp ebizkaia.Response) (*Receipt, error) {
+	regNum, err := c.sendRequest(ctx, req, eBizkaiaExecutePath, resp)
+	if errors.Is(err, ErrValidation) {
+		switch resp.FirstErrorCode() {
+		case eBizkaiaN3RespCodeDuplicated:
+			return nil, ErrDuplicate
+		case eBizkaiaN3RespCodeNotFound:
+			return nil, ErrNotFound.withCode(resp.FirstErrorCode()).withMessage(resp.FirstErrorDescription())
+		case eBizkaiaN3RespCodeCancelled:
+			return nil, ErrAlreadyCancelled.withCode(resp.FirstErrorCode()).withMessage(resp.FirstErrorDescription())
+		}
+
+		if resp.FirstErrorDescription() != "" {
+			return nil, ErrValidation.withCode(resp.FirstErrorCode()).withMessage(resp.FirstErrorDescription())
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return c.sendRequest(ctx, req, eBizkaiaExecutePath, nil)
+	return &Receipt{
+		ID:         regNum,
+		ReceivedAt: resp.PresentationDate(),
+	}, nil
 }
 
-func (c *EBizkaiaConn) sendRequest(ctx context.Context, doc *ebizkaia.Request, path string, resp interface{}) error {
+// sendRequest posts the request to the given path, decoding the response into
+// resp, and returns the registration number assigned by the gateway.
+func (c *EBizkaiaConn) sendRequest(ctx context.Context, doc *ebizkaia.Request, path string, resp interface{}) (string, error) {
 	r := c.client.R().
 		SetContext(ctx).
 		SetHeader("Content-Encoding", "gzip").
@@ -179,10 +609,10 @@ func (c *EBizkaiaConn) sendRequest(ctx context.Context, doc *ebizkaia.Request, p
 
 	res, err := r.Post(path)
 	if err != nil {
-		return ErrConnection.withCause(err)
+		return "", ErrConnection.withCause(err)
 	}
 	if res.StatusCode() != 200 {
-		return ErrConnection.withCode(fmt.Sprintf("%d", res.StatusCode()))
+		return "", ErrConnection.withCode(fmt.Sprintf("%d", res.StatusCode()))
 	}
 
 	code := res.Header().Get(eBizkaiaN3ResponseHeader)
@@ -194,12 +624,12 @@ func (c *EBizkaiaConn) sendRequest(ctx context.Context, doc *ebizkaia.Request, p
 		if !slices.Contains(serverErrors, code) {
 			// Not a server-side error, so the cause of it is in the request. We identify
 			// it as an ErrInvalidRequest to handle it downstream.
-			return ErrValidation.withCode(code).withMessage(msg)
+			return "", ErrValidation.withCode(code).withMessage(msg)
 		}
-		return ErrConnection.withCode(code).withMessage(msg)
+		return "", ErrConnection.withCode(code).withMessage(msg)
 	}
 
-	return nil
+	return res.Header().Get(eBizkaiaN3RegNumberHeader), nil
 }
 
 // convertToValidUTF8 determines the encoding of a string and converts it to
@@ -19,8 +19,6 @@ import (
 // Constants used in headers
 const (
 	concepto                    = "LROE"
-	apartado1                   = "1"
-	apartado1_1                 = "1.1"
 	Modelo240                   = "240"
 	Modelo140                   = "140"
 	schemaLROE240ConSGAlta      = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PJ_240_1_1_FacturasEmitidas_ConSG_AltaPeticion_V1_0_2.xsd"
@@ -31,6 +29,30 @@ const (
 	schemaLROE140ConSGAnulacion = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_1_1_Ingresos_ConfacturaConSG_AnulacionPeticion_V1_0_0.xsd"
 )
 
+// section identifies the LROE chapter (capítulo) and subchapter (subcapítulo)
+// that the records of a request belong to.
+type section struct {
+	capitulo    string
+	subcapitulo string
+}
+
+// LROE sections supported
+var (
+	sectionIssued   = section{capitulo: "1", subcapitulo: "1.1"} // invoices issued with software garante
+	sectionUnbacked = section{capitulo: "1", subcapitulo: "1.2"} // invoices issued without software garante, Modelo 140
+	sectionIncome   = section{capitulo: "1", subcapitulo: "1.3"} // income without invoice, Modelo 140
+	sectionReceived = section{capitulo: "2"}                     // received invoices, Modelo 240
+	sectionExpenses = section{capitulo: "2", subcapitulo: "2.1"} // expenses with invoice, Modelo 140
+)
+
+// apartado provides the code of the section used in the N3 header.
+func (s section) apartado() string {
+	if s.subcapitulo != "" {
+		return s.subcapitulo
+	}
+	return s.capitulo
+}
+
 const (
 	operacionEnumAlta                           = "A00"
 	operacionEnumAltaDevolucionViajeros         = "A01"
@@ -99,7 +121,15 @@ type LROEPJ240FacturasEmitidasConSGAltaPeticion struct {
 // LROEPJ240FacturasEmitidasConSGAltaRespuesta represents the response from the server
 // when uploading invoices.
 type LROEPJ240FacturasEmitidasConSGAltaRespuesta struct {
-	Registros *RegistrosFacturaConSGType
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// DatosPresentacionType contains the details about when and by whom a request
+// was presented.
+type DatosPresentacionType struct {
+	FechaPresentacion string
+	NIFPresentador    string
 }
 
 // RegistrosFacturaConSGType contains the response for all invoices proccessed in a upload request.
@@ -115,8 +145,10 @@ type RegistroFacturaConSGType struct {
 
 // SituacionRegistroType details about the outcome of uploading a single invoice.
 type SituacionRegistroType struct {
+	EstadoRegistro             string
 	CodigoErrorRegistro        string
 	DescripcionErrorRegistroES string
+	DescripcionErrorRegistroEU string
 }
 
 // LROEPJ240FacturasEmitidasConSGConsultaPeticion represents a request to fetch invoices.
@@ -181,6 +213,13 @@ type LROEPJ240FacturasEmitidasConSGAnulacionPeticion struct {
 	FacturasEmitidas *AnulacionesFacturasEmitidasConSGType
 }
 
+// LROEPJ240FacturasEmitidasConSGAnulacionRespuesta represents the response from the
+// server when cancelling invoices.
+type LROEPJ240FacturasEmitidasConSGAnulacionRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
 // FacturasEmitidasConSGCodificadoType holds an array of invoices
 // to send.
 type FacturasEmitidasConSGCodificadoType struct {
@@ -215,7 +254,8 @@ type LROEPF140IngresosConFacturaConSGAltaPeticion struct {
 // LROEPF140IngresosConFacturaConSGAltaRespuesta represents the response from the server
 // when uploading invoices under Modelo 140.
 type LROEPF140IngresosConFacturaConSGAltaRespuesta struct {
-	Registros *RegistrosFacturaConSGType
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
 }
 
 // LROEPF140IngresosConFacturaConSGConsultaPeticion represents a request to fetch invoices
@@ -244,6 +284,13 @@ type LROEPF140IngresosConFacturaConSGAnulacionPeticion struct {
 	Ingresos *AnulacionesIngresosConSGType
 }
 
+// LROEPF140IngresosConFacturaConSGAnulacionRespuesta represents the response from the
+// server when cancelling invoices under Modelo 140.
+type LROEPF140IngresosConFacturaConSGAnulacionRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
 // IngresosConSGCodificadoType holds an array of income records to send under Modelo 140.
 type IngresosConSGCodificadoType struct {
 	Ingreso []*IngresoConSGCodificadoType // max length 1000
@@ -260,9 +307,8 @@ type RentaIngresosType struct {
 	DetalleRenta []*DetalleRentaIngresosType // 1..10
 }
 
-// DetalleRentaIngresosType describes a single income detail entry. Only Epigrafe is
-// populated by the converter today; the remaining XSD fields are exposed for follow-up
-// wiring through GOBL extensions.
+// DetalleRentaIngresosType describes a single income detail entry, built from the
+// invoice by NewRenta.
 type DetalleRentaIngresosType struct {
 	TerritorioAltaActividad                  string `xml:",omitempty"`
 	Epigrafe                                 string
@@ -299,6 +345,14 @@ type Supplier struct {
 	Name     string // Name of the company
 	Model    string // Modelo140 or Modelo240; empty defaults to Modelo240
 	Activity string // IAE Epigrafe; only used when Model == Modelo140
+	// Person contains the split name of the supplier when a natural person,
+	// used instead of Name in the fields of the request header that expect
+	// it split. ApellidosNombreRazonSocial fields always use Name, as in the
+	// embedded TicketBAI documents.
+	Person *PersonName
+	// Renta contains the income details, only used when Model == Modelo140. If
+	// empty, a single entry with the Activity is used.
+	Renta []*DetalleRentaIngresosType
 }
 
 // NewCreateRequest assembles a new Create request
@@ -306,26 +360,22 @@ func NewCreateRequest(sup *Supplier, payload []byte) (*Request, error) {
 	if sup.Model == Modelo140 {
 		body := &LROEPF140IngresosConFacturaConSGAltaPeticion{
 			LROENamespace: schemaLROE140ConSGAlta,
-			Cabecera:      newCabeceraType(sup, operacionEnumAlta),
+			Cabecera:      newCabeceraType(sup, sectionIssued, operacionEnumAlta),
 			Ingresos: &IngresosConSGCodificadoType{
 				Ingreso: []*IngresoConSGCodificadoType{
 					{
 						TicketBai: base64.StdEncoding.EncodeToString(payload),
-						Renta: &RentaIngresosType{
-							DetalleRenta: []*DetalleRentaIngresosType{
-								{Epigrafe: sup.Activity},
-							},
-						},
+						Renta:     newRentaIngresos(sup),
 					},
 				},
 			},
 		}
-		return newRequest(sup, body)
+		return newRequest(sup, sectionIssued, body)
 	}
 
 	body := &LROEPJ240FacturasEmitidasConSGAltaPeticion{
 		LROENamespace: schemaLROE240ConSGAlta,
-		Cabecera:      newCabeceraType(sup, operacionEnumAlta),
+		Cabecera:      newCabeceraType(sup, sectionIssued, operacionEnumAlta),
 		FacturasEmitidas: &FacturasEmitidasConSGCodificadoType{
 			FacturaEmitida: []*DetalleEmitidaConSGCodificadoType{
 				{
@@ -335,7 +385,7 @@ func NewCreateRequest(sup *Supplier, payload []byte) (*Request, error) {
 		},
 	}
 
-	return newRequest(sup, body)
+	return newRequest(sup, sectionIssued, body)
 }
 
 // NewFetchRequest assembles a new Fetch request
@@ -355,25 +405,25 @@ func NewFetchRequest(sup *Supplier, page int, head *convert.CabeceraFactura) (*R
 	if sup.Model == Modelo140 {
 		body := &LROEPF140IngresosConFacturaConSGConsultaPeticion{
 			LROENamespace: schemaLROE140ConSGConsulta,
-			Cabecera:      newCabeceraType(sup, operacionEnumConsulta),
+			Cabecera:      newCabeceraType(sup, sectionIssued, operacionEnumConsulta),
 			FiltroConsultaIngresosConSG: &FiltroConsultaFacturasEmitidasType{
 				CabeceraFactura:   cabeceraFiltro,
 				NumPaginaConsulta: page,
 			},
 		}
-		return newRequest(sup, body)
+		return newRequest(sup, sectionIssued, body)
 	}
 
 	body := &LROEPJ240FacturasEmitidasConSGConsultaPeticion{
 		LROENamespace: schemaLROE240ConSGConsulta,
-		Cabecera:      newCabeceraType(sup, operacionEnumConsulta),
+		Cabecera:      newCabeceraType(sup, sectionIssued, operacionEnumConsulta),
 		FiltroConsultaFacturasEmitidasConSG: &FiltroConsultaFacturasEmitidasType{
 			CabeceraFactura:   cabeceraFiltro,
 			NumPaginaConsulta: page,
 		},
 	}
 
-	return newRequest(sup, body)
+	return newRequest(sup, sectionIssued, body)
 }
 
 // NewCancelRequest assembles a new Cancel request
@@ -381,7 +431,7 @@ func NewCancelRequest(sup *Supplier, payload []byte) (*Request, error) {
 	if sup.Model == Modelo140 {
 		body := &LROEPF140IngresosConFacturaConSGAnulacionPeticion{
 			LROENamespace: schemaLROE140ConSGAnulacion,
-			Cabecera:      newCabeceraType(sup, operacionEnumAnulacion),
+			Cabecera:      newCabeceraType(sup, sectionIssued, operacionEnumAnulacion),
 			Ingresos: &AnulacionesIngresosConSGType{
 				Ingreso: []*AnulacionIngresoConSGType{
 					{
@@ -390,12 +440,12 @@ func NewCancelRequest(sup *Supplier, payload []byte) (*Request, error) {
 				},
 			},
 		}
-		return newRequest(sup, body)
+		return newRequest(sup, sectionIssued, body)
 	}
 
 	body := &LROEPJ240FacturasEmitidasConSGAnulacionPeticion{
 		LROENamespace: schemaLROE240ConSGAnulacion,
-		Cabecera:      newCabeceraType(sup, operacionEnumAnulacion),
+		Cabecera:      newCabeceraType(sup, sectionIssued, operacionEnumAnulacion),
 		FacturasEmitidas: &AnulacionesFacturasEmitidasConSGType{
 			FacturaEmitida: []*AnulacionFacturaConSGType{
 				{
@@ -405,18 +455,29 @@ func NewCancelRequest(sup *Supplier, payload []byte) (*Request, error) {
 		},
 	}
 
-	return newRequest(sup, body)
+	return newRequest(sup, sectionIssued, body)
 }
 
-func newCabeceraType(sup *Supplier, op string) *CabeceraType {
+func newRentaIngresos(sup *Supplier) *RentaIngresosType {
+	if len(sup.Renta) > 0 {
+		return &RentaIngresosType{DetalleRenta: sup.Renta}
+	}
+	return &RentaIngresosType{
+		DetalleRenta: []*DetalleRentaIngresosType{
+			{Epigrafe: sup.Activity},
+		},
+	}
+}
+
+func newCabeceraType(sup *Supplier, sec section, op string) *CabeceraType {
 	model := sup.Model
 	if model == "" {
 		model = Modelo240
 	}
 	head := &CabeceraType{
 		Modelo:      model,
-		Capitulo:    apartado1, // nolint:misspell
-		Subcapitulo: apartado1_1,
+		Capitulo:    sec.capitulo, // nolint:misspell
+		Subcapitulo: sec.subcapitulo,
 		Operacion:   op,
 		Version:     "1.0",
 		Ejercicio:   sup.Year,
@@ -428,7 +489,7 @@ func newCabeceraType(sup *Supplier, op string) *CabeceraType {
 	return head
 }
 
-func newRequest(sup *Supplier, body any) (*Request, error) {
+func newRequest(sup *Supplier, sec section, body any) (*Request, error) {
 	req := new(Request)
 
 	bdata, err := xml.Marshal(body)
@@ -440,7 +501,7 @@ func newRequest(sup *Supplier, body any) (*Request, error) {
 		return nil, fmt.Errorf("compressing body: %w", err)
 	}
 
-	jhead := newN3Header(sup)
+	jhead := newN3Header(sup, sec)
 	req.Header, err = json.Marshal(jhead)
 	if err != nil {
 		return nil, fmt.Errorf("json header: %w", err)
@@ -449,7 +510,7 @@ func newRequest(sup *Supplier, body any) (*Request, error) {
 	return req, nil
 }
 
-func newN3Header(sup *Supplier) *N3Header {
+func newN3Header(sup *Supplier, sec section) *N3Header {
 	model := sup.Model
 	if model == "" {
 		model = Modelo240
@@ -457,7 +518,7 @@ func newN3Header(sup *Supplier) *N3Header {
 	// prepare the request data header
 	head := &N3Header{
 		Concepto: concepto,
-		Apartado: apartado1_1, // for invoices
+		Apartado: sec.apartado(),
 		Interesado: N3Interesado{
 			NIF:    sup.NIF,
 			Nombre: sup.Name,
@@ -467,6 +528,11 @@ func newN3Header(sup *Supplier) *N3Header {
 			Ejercicio: sup.Year,
 		},
 	}
+	if sup.Person != nil {
+		head.Interesado.Nombre = sup.Person.Nombre
+		head.Interesado.Apellido1 = sup.Person.Apellido1
+		head.Interesado.Apellido2 = sup.Person.Apellido2
+	}
 	return head
 }
 
@@ -485,38 +551,83 @@ func compressBody(data []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// FirstErrorCode returns the first error code in the response.
-func (r *LROEPJ240FacturasEmitidasConSGAltaRespuesta) FirstErrorCode() string {
-	if r.Registros == nil || len(r.Registros.Registro) == 0 {
+// Response defines the methods shared by all the LROE registration responses.
+type Response interface {
+	FirstErrorCode() string
+	FirstErrorDescription() string
+	PresentationDate() string
+}
+
+func (r *RegistrosFacturaConSGType) first() *SituacionRegistroType {
+	if r == nil || len(r.Registro) == 0 || r.Registro[0].SituacionRegistro == nil {
+		return new(SituacionRegistroType)
+	}
+	return r.Registro[0].SituacionRegistro
+}
+
+func (d *DatosPresentacionType) date() string {
+	if d == nil {
 		return ""
 	}
+	return d.FechaPresentacion
+}
 
-	return r.Registros.Registro[0].SituacionRegistro.CodigoErrorRegistro
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPJ240FacturasEmitidasConSGAltaRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
 }
 
 // FirstErrorDescription returns the first error description in the response.
 func (r *LROEPJ240FacturasEmitidasConSGAltaRespuesta) FirstErrorDescription() string {
-	if r.Registros == nil || len(r.Registros.Registro) == 0 {
-		return ""
-	}
+	return r.Registros.first().DescripcionErrorRegistroES
+}
 
-	return r.Registros.Registro[0].SituacionRegistro.DescripcionErrorRegistroES
+// PresentationDate returns the date the request was presented.
+func (r *LROEPJ240FacturasEmitidasConSGAltaRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
 }
 
 // FirstErrorCode returns the first error code in the response.
 func (r *LROEPF140IngresosConFacturaConSGAltaRespuesta) FirstErrorCode() string {
-	if r.Registros == nil || len(r.Registros.Registro) == 0 {
-		return ""
-	}
-
-	return r.Registros.Registro[0].SituacionRegistro.CodigoErrorRegistro
+	return r.Registros.first().CodigoErrorRegistro
 }
 
 // FirstErrorDescription returns the first error description in the response.
 func (r *LROEPF140IngresosConFacturaConSGAltaRespuesta) FirstErrorDescription() string {
-	if r.Registros == nil || len(r.Registros.Registro) == 0 {
-		return ""
-	}
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140IngresosConFacturaConSGAltaRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPJ240FacturasEmitidasConSGAnulacionRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPJ240FacturasEmitidasConSGAnulacionRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPJ240FacturasEmitidasConSGAnulacionRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140IngresosConFacturaConSGAnulacionRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140IngresosConFacturaConSGAnulacionRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
 
-	return r.Registros.Registro[0].SituacionRegistro.DescripcionErrorRegistroES
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140IngresosConFacturaConSGAnulacionRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
 }
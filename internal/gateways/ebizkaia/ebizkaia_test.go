@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"encoding/xml"
 	"io"
 	"testing"
 )
@@ -201,3 +202,43 @@ func TestLROEPF140AltaRespuestaFirstError(t *testing.T) {
 		t.Errorf("FirstErrorDescription = %q, want %q", got, "Algo falló")
 	}
 }
+
+func TestAnulacionRespuestaDecode(t *testing.T) {
+	data := []byte(`<LROEPJ240FacturasEmitidasConSGAnulacionRespuesta>
+	<DatosPresentacion>
+		<FechaPresentacion>01-02-2026 10:11:12</FechaPresentacion>
+		<NIFPresentador>A99800005</NIFPresentador>
+	</DatosPresentacion>
+	<Registros>
+		<Registro>
+			<SituacionRegistro>
+				<EstadoRegistro>Incorrecto</EstadoRegistro>
+				<CodigoErrorRegistro>B4_2000002</CodigoErrorRegistro>
+				<DescripcionErrorRegistroES>El registro debe existir</DescripcionErrorRegistroES>
+			</SituacionRegistro>
+		</Registro>
+	</Registros>
+</LROEPJ240FacturasEmitidasConSGAnulacionRespuesta>`)
+
+	var r Response = new(LROEPJ240FacturasEmitidasConSGAnulacionRespuesta)
+	if err := xml.Unmarshal(data, r); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := r.FirstErrorCode(); got != "B4_2000002" {
+		t.Errorf("FirstErrorCode = %q, want B4_2000002", got)
+	}
+	if got := r.FirstErrorDescription(); got != "El registro debe existir" {
+		t.Errorf("FirstErrorDescription = %q", got)
+	}
+	if got := r.PresentationDate(); got != "01-02-2026 10:11:12" {
+		t.Errorf("PresentationDate = %q", got)
+	}
+
+	empty := new(LROEPF140IngresosConFacturaConSGAnulacionRespuesta)
+	if got := empty.FirstErrorCode(); got != "" {
+		t.Errorf("FirstErrorCode on empty response = %q, want empty", got)
+	}
+	if got := empty.PresentationDate(); got != "" {
+		t.Errorf("PresentationDate on empty response = %q, want empty", got)
+	}
+}
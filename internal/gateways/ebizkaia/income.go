@@ -0,0 +1,328 @@
+package ebizkaia
+
+import (
+	"encoding/xml"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+)
+
+// Subchapter 1.2 of Modelo 140 contains the invoices issued by natural persons
+// without a software garante, for example before they were required to use
+// TicketBAI, and subchapter 1.3 the income that is not documented with an
+// invoice, such as subsidies or capital gains tied to the activity. Unlike
+// subchapter 1.1, records are not TicketBAI documents: invoices carry the
+// same invoice and recipient details, unsigned, and income is built directly
+// from its details.
+
+// Schemas for income with invoice without software garante and without invoice
+const (
+	schemaLROE140SinSGAlta           = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_1_2_Ingresos_ConfacturaSinSG_AltaModifPeticion_V1_0_1.xsd"
+	schemaLROE140SinSGAnulacion      = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_1_2_Ingresos_ConfacturaSinSG_AnulacionPeticion_V1_0_0.xsd"
+	schemaLROE140SinFacturaAlta      = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_1_3_Ingresos_SinFactura_AltaModifPeticion_V1_0_1.xsd"
+	schemaLROE140SinFacturaConsulta  = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_1_3_Ingresos_SinFactura_ConsultaPeticion_V1_0_0.xsd"
+	schemaLROE140SinFacturaAnulacion = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_1_3_Ingresos_SinFactura_AnulacionPeticion_V1_0_0.xsd"
+)
+
+// LROEPF140IngresosConFacturaSinSGAltaModifPeticion is used by individuals for
+// registering invoices issued without software garante under Modelo 140.
+type LROEPF140IngresosConFacturaSinSGAltaModifPeticion struct {
+	XMLName       xml.Name `xml:"lrpficfssgap:LROEPF140IngresosConFacturaSinSGAltaModifPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpficfssgap,attr"`
+
+	Cabecera *CabeceraType
+	Ingresos *IngresosConFacturaSinSGType
+}
+
+// LROEPF140IngresosConFacturaSinSGAltaModifRespuesta represents the response
+// from the server when registering invoices issued without software garante.
+type LROEPF140IngresosConFacturaSinSGAltaModifRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// LROEPF140IngresosConFacturaSinSGAnulacionPeticion is used by individuals for
+// cancelling invoices issued without software garante under Modelo 140.
+type LROEPF140IngresosConFacturaSinSGAnulacionPeticion struct {
+	XMLName       xml.Name `xml:"lrpficfssgan:LROEPF140IngresosConFacturaSinSGAnulacionPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpficfssgan,attr"`
+
+	Cabecera *CabeceraType
+	Ingresos *AnulacionesIngresosConFacturaSinSGType
+}
+
+// LROEPF140IngresosConFacturaSinSGAnulacionRespuesta represents the response
+// from the server when cancelling invoices issued without software garante.
+type LROEPF140IngresosConFacturaSinSGAnulacionRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// IngresosConFacturaSinSGType holds an array of invoices issued without
+// software garante.
+type IngresosConFacturaSinSGType struct {
+	Ingreso []*IngresoConFacturaSinSGType // max length 1000
+}
+
+// AnulacionesIngresosConFacturaSinSGType holds an array of invoices issued
+// without software garante to cancel.
+type AnulacionesIngresosConFacturaSinSGType struct {
+	Ingreso []*IDIngresoConFacturaSinSGType
+}
+
+// IngresoConFacturaSinSGType contains a single invoice issued without software
+// garante, built from the unsigned TicketBAI document by
+// NewIngresoConFacturaSinSG.
+type IngresoConFacturaSinSGType struct {
+	Factura *FacturaSinSGType
+	Renta   *RentaIngresosType
+}
+
+// FacturaSinSGType contains the details of an invoice issued without software
+// garante.
+type FacturaSinSGType struct {
+	CabeceraFactura *convert.CabeceraFactura
+	DatosFactura    *convert.DatosFactura
+	TipoDesglose    *convert.TipoDesglose
+	Destinatarios   *convert.Destinatarios `xml:",omitempty"`
+}
+
+// IDIngresoConFacturaSinSGType identifies an invoice issued without software
+// garante to cancel.
+type IDIngresoConFacturaSinSGType struct {
+	SerieFactura           string `xml:",omitempty"`
+	NumFactura             string
+	FechaExpedicionFactura string
+}
+
+// LROEPF140IngresosSinFacturaConsultaPeticion represents a request to fetch
+// income without invoice under Modelo 140.
+type LROEPF140IngresosSinFacturaConsultaPeticion struct {
+	XMLName       xml.Name `xml:"lrpfisfcp:LROEPF140IngresosSinFacturaConsultaPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpfisfcp,attr"`
+
+	Cabecera               *CabeceraType
+	FiltroConsultaIngresos *FiltroConsultaIngresosSinFacturaType
+}
+
+// LROEPF140IngresosSinFacturaConsultaRespuesta represents the response from
+// the server when fetching income without invoice.
+type LROEPF140IngresosSinFacturaConsultaRespuesta struct {
+	Ingresos *IngresosSinFacturaType
+}
+
+// FiltroConsultaIngresosSinFacturaType contains the details of a query of
+// income without invoice.
+type FiltroConsultaIngresosSinFacturaType struct {
+	NumPaginaConsulta int
+}
+
+// LROEPF140IngresosSinFacturaAltaModifPeticion is used by individuals for
+// registering income without invoice under Modelo 140.
+type LROEPF140IngresosSinFacturaAltaModifPeticion struct {
+	XMLName       xml.Name `xml:"lrpfisfap:LROEPF140IngresosSinFacturaAltaModifPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpfisfap,attr"`
+
+	Cabecera *CabeceraType
+	Ingresos *IngresosSinFacturaType
+}
+
+// LROEPF140IngresosSinFacturaAltaModifRespuesta represents the response from
+// the server when registering income without invoice.
+type LROEPF140IngresosSinFacturaAltaModifRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// LROEPF140IngresosSinFacturaAnulacionPeticion is used by individuals for
+// cancelling income without invoice under Modelo 140.
+type LROEPF140IngresosSinFacturaAnulacionPeticion struct {
+	XMLName       xml.Name `xml:"lrpfisfan:LROEPF140IngresosSinFacturaAnulacionPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpfisfan,attr"`
+
+	Cabecera *CabeceraType
+	Ingresos *AnulacionesIngresosSinFacturaType
+}
+
+// LROEPF140IngresosSinFacturaAnulacionRespuesta represents the response from
+// the server when cancelling income without invoice.
+type LROEPF140IngresosSinFacturaAnulacionRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// IngresosSinFacturaType holds an array of income records without invoice.
+type IngresosSinFacturaType struct {
+	Ingreso []*IngresoSinFacturaType // max length 1000
+}
+
+// AnulacionesIngresosSinFacturaType holds an array of income records without
+// invoice to cancel.
+type AnulacionesIngresosSinFacturaType struct {
+	Ingreso []*IDIngresoSinFacturaType
+}
+
+// IngresoSinFacturaType contains a single income record without invoice.
+type IngresoSinFacturaType struct {
+	IDIngreso          *IDIngresoSinFacturaType
+	ConceptoIngreso    string
+	DescripcionIngreso string `xml:",omitempty"`
+	ImporteIngreso     string
+	Renta              *RentaIngresosType
+}
+
+// IDIngresoSinFacturaType identifies an income record without invoice.
+type IDIngresoSinFacturaType struct {
+	FechaIngreso  string
+	NumeroIngreso string `xml:",omitempty"`
+}
+
+// NewIngresoConFacturaSinSG builds the record of an invoice issued without
+// software garante from its unsigned TicketBAI document and income details.
+func NewIngresoConFacturaSinSG(doc *convert.TicketBAI, renta []*DetalleRentaIngresosType) *IngresoConFacturaSinSGType {
+	rec := &IngresoConFacturaSinSGType{
+		Factura: &FacturaSinSGType{
+			CabeceraFactura: doc.Factura.CabeceraFactura,
+			DatosFactura:    doc.Factura.DatosFactura,
+			TipoDesglose:    doc.Factura.TipoDesglose,
+		},
+		Renta: &RentaIngresosType{DetalleRenta: renta},
+	}
+	if doc.Sujetos != nil {
+		rec.Factura.Destinatarios = doc.Sujetos.Destinatarios
+	}
+	return rec
+}
+
+// NewIncomeInvoiceCreateRequest assembles a request to register an invoice
+// issued without software garante. Only available under Modelo 140.
+func NewIncomeInvoiceCreateRequest(sup *Supplier, rec *IngresoConFacturaSinSGType) (*Request, error) {
+	body := &LROEPF140IngresosConFacturaSinSGAltaModifPeticion{
+		LROENamespace: schemaLROE140SinSGAlta,
+		Cabecera:      newCabeceraType(sup, sectionUnbacked, operacionEnumAlta),
+		Ingresos: &IngresosConFacturaSinSGType{
+			Ingreso: []*IngresoConFacturaSinSGType{rec},
+		},
+	}
+	return newRequest(sup, sectionUnbacked, body)
+}
+
+// NewIncomeInvoiceCancelRequest assembles a request to cancel the record of
+// an invoice issued without software garante. Only available under Modelo
+// 140.
+func NewIncomeInvoiceCancelRequest(sup *Supplier, id *IDIngresoConFacturaSinSGType) (*Request, error) {
+	body := &LROEPF140IngresosConFacturaSinSGAnulacionPeticion{
+		LROENamespace: schemaLROE140SinSGAnulacion,
+		Cabecera:      newCabeceraType(sup, sectionUnbacked, operacionEnumAnulacion),
+		Ingresos: &AnulacionesIngresosConFacturaSinSGType{
+			Ingreso: []*IDIngresoConFacturaSinSGType{id},
+		},
+	}
+	return newRequest(sup, sectionUnbacked, body)
+}
+
+// NewIncomeCreateRequest assembles a request to register income without
+// invoice. Only available under Modelo 140.
+func NewIncomeCreateRequest(sup *Supplier, rec *IngresoSinFacturaType) (*Request, error) {
+	body := &LROEPF140IngresosSinFacturaAltaModifPeticion{
+		LROENamespace: schemaLROE140SinFacturaAlta,
+		Cabecera:      newCabeceraType(sup, sectionIncome, operacionEnumAlta),
+		Ingresos: &IngresosSinFacturaType{
+			Ingreso: []*IngresoSinFacturaType{rec},
+		},
+	}
+	return newRequest(sup, sectionIncome, body)
+}
+
+// NewIncomeCancelRequest assembles a request to cancel the record of income
+// without invoice. Only available under Modelo 140.
+func NewIncomeCancelRequest(sup *Supplier, id *IDIngresoSinFacturaType) (*Request, error) {
+	body := &LROEPF140IngresosSinFacturaAnulacionPeticion{
+		LROENamespace: schemaLROE140SinFacturaAnulacion,
+		Cabecera:      newCabeceraType(sup, sectionIncome, operacionEnumAnulacion),
+		Ingresos: &AnulacionesIngresosSinFacturaType{
+			Ingreso: []*IDIngresoSinFacturaType{id},
+		},
+	}
+	return newRequest(sup, sectionIncome, body)
+}
+
+// NewIncomeFetchRequest assembles a request to fetch the records of income
+// without invoice. Only available under Modelo 140.
+func NewIncomeFetchRequest(sup *Supplier, page int) (*Request, error) {
+	body := &LROEPF140IngresosSinFacturaConsultaPeticion{
+		LROENamespace: schemaLROE140SinFacturaConsulta,
+		Cabecera:      newCabeceraType(sup, sectionIncome, operacionEnumConsulta),
+		FiltroConsultaIngresos: &FiltroConsultaIngresosSinFacturaType{
+			NumPaginaConsulta: page,
+		},
+	}
+	return newRequest(sup, sectionIncome, body)
+}
+
+// Records returns the income without invoice included in the response.
+func (r *LROEPF140IngresosSinFacturaConsultaRespuesta) Records() []*IngresoSinFacturaType {
+	if r.Ingresos == nil {
+		return nil
+	}
+	return r.Ingresos.Ingreso
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140IngresosConFacturaSinSGAltaModifRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140IngresosConFacturaSinSGAltaModifRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140IngresosConFacturaSinSGAltaModifRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140IngresosConFacturaSinSGAnulacionRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140IngresosConFacturaSinSGAnulacionRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140IngresosConFacturaSinSGAnulacionRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140IngresosSinFacturaAltaModifRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140IngresosSinFacturaAltaModifRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140IngresosSinFacturaAltaModifRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140IngresosSinFacturaAnulacionRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140IngresosSinFacturaAnulacionRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140IngresosSinFacturaAnulacionRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
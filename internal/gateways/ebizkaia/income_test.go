@@ -0,0 +1,176 @@
+package ebizkaia
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+)
+
+func TestNewIncomeCreateRequest(t *testing.T) {
+	sup := &Supplier{
+		Year:  "2024",
+		NIF:   "12345678Z",
+		Name:  "Ana Fernández García",
+		Model: Modelo140,
+	}
+	rec := &IngresoSinFacturaType{
+		IDIngreso: &IDIngresoSinFacturaType{
+			FechaIngreso: "10-05-2024",
+		},
+		ConceptoIngreso: "01",
+		ImporteIngreso:  "1500.00",
+		Renta: &RentaIngresosType{
+			DetalleRenta: []*DetalleRentaIngresosType{{Epigrafe: "722300"}},
+		},
+	}
+
+	req, err := NewIncomeCreateRequest(sup, rec)
+	if err != nil {
+		t.Fatalf("NewIncomeCreateRequest: %v", err)
+	}
+
+	body := gunzip(t, req.Payload)
+	checks := []string{
+		`lrpfisfap:LROEPF140IngresosSinFacturaAltaModifPeticion`,
+		`<Modelo>140</Modelo>`,
+		`<Capitulo>1</Capitulo><Subcapitulo>1.3</Subcapitulo>`,
+		`<Ingresos><Ingreso><IDIngreso><FechaIngreso>10-05-2024</FechaIngreso></IDIngreso>`,
+		`<ImporteIngreso>1500.00</ImporteIngreso>`,
+		`<Epigrafe>722300</Epigrafe>`,
+	}
+	for _, want := range checks {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("payload missing %q\npayload:\n%s", want, body)
+		}
+	}
+
+	var jhead N3Header
+	if err := json.Unmarshal(req.Header, &jhead); err != nil {
+		t.Fatalf("json header: %v", err)
+	}
+	if jhead.Apartado != "1.3" {
+		t.Errorf("N3 header apa = %q, want 1.3", jhead.Apartado)
+	}
+}
+
+func TestNewIncomeCancelRequest(t *testing.T) {
+	sup := &Supplier{Year: "2024", NIF: "12345678Z", Name: "Ana Fernández García", Model: Modelo140}
+
+	req, err := NewIncomeCancelRequest(sup, &IDIngresoSinFacturaType{FechaIngreso: "10-05-2024", NumeroIngreso: "S-1"})
+	if err != nil {
+		t.Fatalf("NewIncomeCancelRequest: %v", err)
+	}
+
+	body := gunzip(t, req.Payload)
+	want := `<Ingreso><FechaIngreso>10-05-2024</FechaIngreso><NumeroIngreso>S-1</NumeroIngreso></Ingreso>`
+	if !bytes.Contains(body, []byte(want)) {
+		t.Errorf("payload missing %q\npayload:\n%s", want, body)
+	}
+}
+
+func TestNewIncomeFetchRequest(t *testing.T) {
+	sup := &Supplier{Year: "2024", NIF: "12345678Z", Name: "Ana Fernández García", Model: Modelo140}
+
+	req, err := NewIncomeFetchRequest(sup, 2)
+	if err != nil {
+		t.Fatalf("NewIncomeFetchRequest: %v", err)
+	}
+
+	body := gunzip(t, req.Payload)
+	checks := []string{
+		`lrpfisfcp:LROEPF140IngresosSinFacturaConsultaPeticion`,
+		`<Operacion>C00</Operacion>`,
+		`<Capitulo>1</Capitulo><Subcapitulo>1.3</Subcapitulo>`,
+		`<FiltroConsultaIngresos><NumPaginaConsulta>2</NumPaginaConsulta></FiltroConsultaIngresos>`,
+	}
+	for _, want := range checks {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("payload missing %q\npayload:\n%s", want, body)
+		}
+	}
+}
+
+func TestNewIncomeInvoiceCreateRequest(t *testing.T) {
+	sup := &Supplier{Year: "2024", NIF: "12345678Z", Name: "Ana Fernández García", Model: Modelo140}
+	doc := &convert.TicketBAI{
+		Sujetos: &convert.Sujetos{
+			Emisor: &convert.Emisor{NIF: "12345678Z", ApellidosNombreRazonSocial: "Ana Fernández García"},
+			Destinatarios: &convert.Destinatarios{
+				IDDestinatario: []*convert.IDDestinatario{
+					{NIF: "B98602642", ApellidosNombreRazonSocial: "Provide One S.L."},
+				},
+			},
+		},
+		Factura: &convert.Factura{
+			CabeceraFactura: &convert.CabeceraFactura{
+				SerieFactura:           "FREEL",
+				NumFactura:             "001",
+				FechaExpedicionFactura: "15-03-2024",
+				FacturaSimplificada:    "N",
+			},
+			DatosFactura: &convert.DatosFactura{
+				DescripcionFactura:  "Consultoría",
+				ImporteTotalFactura: "1210.00",
+			},
+		},
+	}
+	rec := NewIngresoConFacturaSinSG(doc, []*DetalleRentaIngresosType{{Epigrafe: "722300"}})
+
+	req, err := NewIncomeInvoiceCreateRequest(sup, rec)
+	if err != nil {
+		t.Fatalf("NewIncomeInvoiceCreateRequest: %v", err)
+	}
+
+	body := gunzip(t, req.Payload)
+	checks := []string{
+		`lrpficfssgap:LROEPF140IngresosConFacturaSinSGAltaModifPeticion`,
+		`<Capitulo>1</Capitulo><Subcapitulo>1.2</Subcapitulo>`,
+		`<Ingresos><Ingreso><Factura><CabeceraFactura><SerieFactura>FREEL</SerieFactura><NumFactura>001</NumFactura>`,
+		`<ImporteTotalFactura>1210.00</ImporteTotalFactura>`,
+		`<Destinatarios><IDDestinatario><NIF>B98602642</NIF>`,
+		`</Factura><Renta><DetalleRenta><Epigrafe>722300</Epigrafe>`,
+	}
+	for _, want := range checks {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("payload missing %q\npayload:\n%s", want, body)
+		}
+	}
+	if bytes.Contains(body, []byte("TicketBai")) {
+		t.Errorf("payload should not contain a TicketBAI document\npayload:\n%s", body)
+	}
+
+	var jhead N3Header
+	if err := json.Unmarshal(req.Header, &jhead); err != nil {
+		t.Fatalf("json header: %v", err)
+	}
+	if jhead.Apartado != "1.2" {
+		t.Errorf("N3 header apa = %q, want 1.2", jhead.Apartado)
+	}
+}
+
+func TestNewIncomeInvoiceCancelRequest(t *testing.T) {
+	sup := &Supplier{Year: "2024", NIF: "12345678Z", Name: "Ana Fernández García", Model: Modelo140}
+
+	req, err := NewIncomeInvoiceCancelRequest(sup, &IDIngresoConFacturaSinSGType{
+		SerieFactura:           "FREEL",
+		NumFactura:             "001",
+		FechaExpedicionFactura: "15-03-2024",
+	})
+	if err != nil {
+		t.Fatalf("NewIncomeInvoiceCancelRequest: %v", err)
+	}
+
+	body := gunzip(t, req.Payload)
+	checks := []string{
+		`<Operacion>AN0</Operacion>`,
+		`<Subcapitulo>1.2</Subcapitulo>`,
+		`<Ingreso><SerieFactura>FREEL</SerieFactura><NumFactura>001</NumFactura><FechaExpedicionFactura>15-03-2024</FechaExpedicionFactura></Ingreso>`,
+	}
+	for _, want := range checks {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("payload missing %q\npayload:\n%s", want, body)
+		}
+	}
+}
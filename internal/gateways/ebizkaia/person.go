@@ -0,0 +1,88 @@
+package ebizkaia
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/invopop/gobl/org"
+)
+
+// PersonName contains the name of a natural person (persona física) split
+// into the parts expected by Batuz.
+type PersonName struct {
+	Nombre    string
+	Apellido1 string
+	Apellido2 string
+}
+
+// NewPersonName determines the name parts of the natural person behind the
+// party. The first person in the party with a name is used if available,
+// otherwise the party's name is split assuming it is either formatted as
+// "Surnames, Name" or "Name Surname1 Surname2".
+func NewPersonName(party *org.Party) *PersonName {
+	if party == nil {
+		return nil
+	}
+	for _, p := range party.People {
+		if p == nil || p.Name == nil || p.Name.Surname == "" {
+			continue
+		}
+		return &PersonName{
+			Nombre:    strings.TrimSpace(p.Name.Given),
+			Apellido1: strings.TrimSpace(p.Name.Surname),
+			Apellido2: strings.TrimSpace(p.Name.Surname2),
+		}
+	}
+	return parsePersonName(party.Name)
+}
+
+// surnameParticles are joined with the word that follows them to keep
+// compound surnames such as "de la Fuente" together.
+var surnameParticles = []string{"de", "del", "la", "las", "los", "y", "san"}
+
+func parsePersonName(name string) *PersonName {
+	if surnames, given, ok := strings.Cut(name, ","); ok {
+		pn := &PersonName{Nombre: strings.TrimSpace(given)}
+		parts := nameParts(surnames)
+		if len(parts) > 0 {
+			pn.Apellido1 = parts[0]
+			pn.Apellido2 = strings.Join(parts[1:], " ")
+		}
+		return pn
+	}
+
+	parts := nameParts(name)
+	switch len(parts) {
+	case 0:
+		return nil
+	case 1:
+		return &PersonName{Nombre: parts[0]}
+	case 2:
+		return &PersonName{Nombre: parts[0], Apellido1: parts[1]}
+	}
+	n := len(parts)
+	return &PersonName{
+		Nombre:    strings.Join(parts[:n-2], " "),
+		Apellido1: parts[n-2],
+		Apellido2: parts[n-1],
+	}
+}
+
+// nameParts splits the name into words, keeping surname particles together
+// with the word that follows them.
+func nameParts(name string) []string {
+	parts := []string{}
+	prefix := ""
+	for _, w := range strings.Fields(name) {
+		if slices.Contains(surnameParticles, strings.ToLower(w)) {
+			prefix += w + " "
+			continue
+		}
+		parts = append(parts, prefix+w)
+		prefix = ""
+	}
+	if prefix != "" {
+		parts = append(parts, strings.TrimSpace(prefix))
+	}
+	return parts
+}
@@ -0,0 +1,92 @@
+package ebizkaia
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/invopop/gobl/org"
+)
+
+func TestNewPersonName(t *testing.T) {
+	tests := []struct {
+		name  string
+		party *org.Party
+		want  PersonName
+	}{
+		{
+			name:  "name and two surnames",
+			party: &org.Party{Name: "Ana Fernández García"},
+			want:  PersonName{Nombre: "Ana", Apellido1: "Fernández", Apellido2: "García"},
+		},
+		{
+			name:  "compound name",
+			party: &org.Party{Name: "Jose Antonio Etxeberria Garcia"},
+			want:  PersonName{Nombre: "Jose Antonio", Apellido1: "Etxeberria", Apellido2: "Garcia"},
+		},
+		{
+			name:  "single surname",
+			party: &org.Party{Name: "John Smith"},
+			want:  PersonName{Nombre: "John", Apellido1: "Smith"},
+		},
+		{
+			name:  "surnames first",
+			party: &org.Party{Name: "De la Fuente Ruiz, María"},
+			want:  PersonName{Nombre: "María", Apellido1: "De la Fuente", Apellido2: "Ruiz"},
+		},
+		{
+			name:  "compound surname",
+			party: &org.Party{Name: "María de la Fuente Ruiz"},
+			want:  PersonName{Nombre: "María", Apellido1: "de la Fuente", Apellido2: "Ruiz"},
+		},
+		{
+			name: "from people",
+			party: &org.Party{
+				Name: "Ana Fernández García",
+				People: []*org.Person{
+					{Name: &org.Name{Given: "Ana", Surname: "De la Fuente", Surname2: "Ruiz"}},
+				},
+			},
+			want: PersonName{Nombre: "Ana", Apellido1: "De la Fuente", Apellido2: "Ruiz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPersonName(tt.party)
+			if got == nil {
+				t.Fatal("NewPersonName returned nil")
+			}
+			if *got != tt.want {
+				t.Errorf("NewPersonName = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCreateRequestPersonName(t *testing.T) {
+	sup := &Supplier{
+		Year:   "2026",
+		NIF:    "12345678Z",
+		Name:   "Ana Fernández García",
+		Model:  Modelo140,
+		Person: &PersonName{Nombre: "Ana", Apellido1: "Fernández", Apellido2: "García"},
+	}
+	req, err := NewCreateRequest(sup, []byte("<TicketBai>fake</TicketBai>"))
+	if err != nil {
+		t.Fatalf("NewCreateRequest: %v", err)
+	}
+
+	var jhead N3Header
+	if err := json.Unmarshal(req.Header, &jhead); err != nil {
+		t.Fatalf("json header: %v", err)
+	}
+	want := N3Interesado{NIF: "12345678Z", Nombre: "Ana", Apellido1: "Fernández", Apellido2: "García"}
+	if jhead.Interesado != want {
+		t.Errorf("N3 interesado = %+v, want %+v", jhead.Interesado, want)
+	}
+
+	body := gunzip(t, req.Payload)
+	if !bytes.Contains(body, []byte("<ApellidosNombreRazonSocial>Ana Fernández García</ApellidosNombreRazonSocial>")) {
+		t.Errorf("payload should keep the supplier's name in ObligadoTributario:\n%s", body)
+	}
+}
@@ -0,0 +1,557 @@
+package ebizkaia
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/currency"
+	"github.com/invopop/gobl/num"
+	"github.com/invopop/gobl/regimes/es"
+	"github.com/invopop/gobl/tax"
+)
+
+// Chapter 2 of the LROE contains the invoices received by companies (Modelo
+// 240) and the expenses with invoice of natural persons (Modelo 140). Unlike
+// chapter 1, records are not TicketBAI documents and are built directly from
+// the received invoice.
+
+// Schemas for received invoices and expenses
+const (
+	schemaLROE240RecibidasAlta      = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PJ_240_2_FacturasRecibidas_AltaModifPeticion_V1_0_1.xsd"
+	schemaLROE240RecibidasConsulta  = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PJ_240_2_FacturasRecibidas_ConsultaPeticion_V1_0_0.xsd"
+	schemaLROE240RecibidasAnulacion = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PJ_240_2_FacturasRecibidas_AnulacionPeticion_V1_0_0.xsd"
+	schemaLROE140GastosAlta         = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_2_1_Gastos_Confactura_AltaModifPeticion_V1_0_1.xsd"
+	schemaLROE140GastosConsulta     = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_2_1_Gastos_Confactura_ConsultaPeticion_V1_0_0.xsd"
+	schemaLROE140GastosAnulacion    = "https://www.batuz.eus/fitxategiak/batuz/LROE/esquemas/LROE_PF_140_2_1_Gastos_Confactura_AnulacionPeticion_V1_0_0.xsd"
+)
+
+// Received invoice types (TipoFactura)
+const (
+	tipoFacturaCompleta      = "F1"
+	tipoFacturaSimplificada  = "F2"
+	tipoFacturaRectificativa = "R1"
+)
+
+// Kinds of acquisition (CompraBienesCorrientesGastosBienesInversion)
+const (
+	compraBienesCorrientes = "C"
+	compraGastos           = "G"
+)
+
+// LROEPJ240FacturasRecibidasAltaModifPeticion is used by companies for
+// registering received invoices.
+type LROEPJ240FacturasRecibidasAltaModifPeticion struct {
+	XMLName       xml.Name `xml:"lrpjfrap:LROEPJ240FacturasRecibidasAltaModifPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpjfrap,attr"`
+
+	Cabecera          *CabeceraType
+	FacturasRecibidas *FacturasRecibidasType
+}
+
+// LROEPJ240FacturasRecibidasAltaModifRespuesta represents the response from
+// the server when registering received invoices.
+type LROEPJ240FacturasRecibidasAltaModifRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// LROEPJ240FacturasRecibidasAnulacionPeticion is used by companies for
+// cancelling received invoices.
+type LROEPJ240FacturasRecibidasAnulacionPeticion struct {
+	XMLName       xml.Name `xml:"lrpjfran:LROEPJ240FacturasRecibidasAnulacionPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpjfran,attr"`
+
+	Cabecera          *CabeceraType
+	FacturasRecibidas *AnulacionesFacturasRecibidasType
+}
+
+// LROEPJ240FacturasRecibidasAnulacionRespuesta represents the response from
+// the server when cancelling received invoices.
+type LROEPJ240FacturasRecibidasAnulacionRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// LROEPJ240FacturasRecibidasConsultaPeticion represents a request to fetch
+// received invoices.
+type LROEPJ240FacturasRecibidasConsultaPeticion struct {
+	XMLName       xml.Name `xml:"lrpjfrcp:LROEPJ240FacturasRecibidasConsultaPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpjfrcp,attr"`
+
+	Cabecera                        *CabeceraType
+	FiltroConsultaFacturasRecibidas *FiltroConsultaFacturasRecibidasType
+}
+
+// LROEPJ240FacturasRecibidasConsultaRespuesta represents the response from
+// the server when fetching received invoices.
+type LROEPJ240FacturasRecibidasConsultaRespuesta struct {
+	FacturasRecibidas *FacturasRecibidasType
+}
+
+// LROEPF140GastosConFacturaAltaModifPeticion is used by individuals for
+// registering expenses with invoice under Modelo 140.
+type LROEPF140GastosConFacturaAltaModifPeticion struct {
+	XMLName       xml.Name `xml:"lrpfgcfap:LROEPF140GastosConFacturaAltaModifPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpfgcfap,attr"`
+
+	Cabecera *CabeceraType
+	Gastos   *GastosType
+}
+
+// LROEPF140GastosConFacturaAltaModifRespuesta represents the response from
+// the server when registering expenses under Modelo 140.
+type LROEPF140GastosConFacturaAltaModifRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// LROEPF140GastosConFacturaAnulacionPeticion is used by individuals for
+// cancelling expenses with invoice under Modelo 140.
+type LROEPF140GastosConFacturaAnulacionPeticion struct {
+	XMLName       xml.Name `xml:"lrpfgcfan:LROEPF140GastosConFacturaAnulacionPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpfgcfan,attr"`
+
+	Cabecera *CabeceraType
+	Gastos   *AnulacionesGastosType
+}
+
+// LROEPF140GastosConFacturaAnulacionRespuesta represents the response from
+// the server when cancelling expenses under Modelo 140.
+type LROEPF140GastosConFacturaAnulacionRespuesta struct {
+	DatosPresentacion *DatosPresentacionType
+	Registros         *RegistrosFacturaConSGType
+}
+
+// LROEPF140GastosConFacturaConsultaPeticion represents a request to fetch
+// expenses with invoice under Modelo 140.
+type LROEPF140GastosConFacturaConsultaPeticion struct {
+	XMLName       xml.Name `xml:"lrpfgcfcp:LROEPF140GastosConFacturaConsultaPeticion"`
+	LROENamespace string   `xml:"xmlns:lrpfgcfcp,attr"`
+
+	Cabecera             *CabeceraType
+	FiltroConsultaGastos *FiltroConsultaFacturasRecibidasType
+}
+
+// LROEPF140GastosConFacturaConsultaRespuesta represents the response from the
+// server when fetching expenses under Modelo 140.
+type LROEPF140GastosConFacturaConsultaRespuesta struct {
+	Gastos *GastosType
+}
+
+// FacturasRecibidasType holds an array of received invoices.
+type FacturasRecibidasType struct {
+	FacturaRecibida []*FacturaRecibidaType // max length 1000
+}
+
+// GastosType holds an array of expenses under Modelo 140.
+type GastosType struct {
+	Gasto []*FacturaRecibidaType // max length 1000
+}
+
+// AnulacionesFacturasRecibidasType holds an array of received invoices to
+// cancel.
+type AnulacionesFacturasRecibidasType struct {
+	FacturaRecibida []*IDRecibidaType
+}
+
+// AnulacionesGastosType holds an array of expenses to cancel under Modelo 140.
+type AnulacionesGastosType struct {
+	Gasto []*IDRecibidaType
+}
+
+// FacturaRecibidaType contains the details of a received invoice, built from
+// the GOBL invoice by NewFacturaRecibida.
+type FacturaRecibidaType struct {
+	EmisorFacturaRecibida *EmisorFacturaRecibidaType
+	CabeceraFactura       *CabeceraFacturaRecibidaType
+	DatosFactura          *DatosFacturaRecibidaType
+	IVA                   *IVARecibidaType `xml:",omitempty"`
+	Renta                 *RentaGastosType `xml:",omitempty"` // only Modelo 140
+}
+
+// EmisorFacturaRecibidaType identifies the supplier of a received invoice.
+type EmisorFacturaRecibidaType struct {
+	NIF                        string          `xml:",omitempty"`
+	IDOtro                     *convert.IDOtro `xml:",omitempty"`
+	ApellidosNombreRazonSocial string
+}
+
+// CabeceraFacturaRecibidaType contains the header of a received invoice.
+type CabeceraFacturaRecibidaType struct {
+	SerieFactura           string `xml:",omitempty"`
+	NumFactura             string
+	FechaExpedicionFactura string
+	FechaRecepcion         string `xml:",omitempty"`
+	TipoFactura            string `xml:",omitempty"`
+}
+
+// DatosFacturaRecibidaType contains the main details of a received invoice.
+type DatosFacturaRecibidaType struct {
+	DescripcionOperacion string
+	Claves               *convert.Claves
+	ImporteTotalFactura  string
+}
+
+// IVARecibidaType contains the VAT breakdown of a received invoice.
+type IVARecibidaType struct {
+	DetalleIVA []*DetalleIVARecibidaType
+}
+
+// DetalleIVARecibidaType describes the VAT supported for a single rate.
+type DetalleIVARecibidaType struct {
+	CompraBienesCorrientesGastosBienesInversion string
+	InversionSujetoPasivo                       string
+	BaseImponible                               string
+	TipoImpositivo                              string `xml:",omitempty"`
+	CuotaIVASoportada                           string `xml:",omitempty"`
+	CuotaIVADeducible                           string `xml:",omitempty"`
+	TipoRecargoEquivalencia                     string `xml:",omitempty"`
+	CuotaRecargoEquivalencia                    string `xml:",omitempty"`
+}
+
+// RentaGastosType wraps the expense detail breakdown for Modelo 140.
+type RentaGastosType struct {
+	DetalleRenta []*DetalleRentaGastosType
+}
+
+// DetalleRentaGastosType describes a single expense detail entry.
+type DetalleRentaGastosType struct {
+	Epigrafe             string
+	CriterioCobrosYPagos string `xml:",omitempty"`
+}
+
+// IDRecibidaType identifies a received invoice to cancel.
+type IDRecibidaType struct {
+	EmisorFacturaRecibida *EmisorFacturaRecibidaType
+	CabeceraFactura       *IDCabeceraRecibidaType
+}
+
+// IDCabeceraRecibidaType contains the header fields that identify a received
+// invoice.
+type IDCabeceraRecibidaType struct {
+	SerieFactura           string `xml:",omitempty"`
+	NumFactura             string
+	FechaExpedicionFactura string
+}
+
+// FiltroConsultaFacturasRecibidasType contains the details of a received
+// invoice query.
+type FiltroConsultaFacturasRecibidasType struct {
+	EmisorFacturaRecibida *EmisorFacturaRecibidaType   `xml:",omitempty"`
+	CabeceraFactura       *CabeceraFacturaConsultaType `xml:",omitempty"`
+	NumPaginaConsulta     int
+}
+
+// NewFacturaRecibida builds the LROE record of an invoice received by the
+// customer on the given date. Amounts are converted to euros and credit notes
+// are registered as corrective invoices with negative amounts. The expense
+// details are only included for Modelo 140.
+func NewFacturaRecibida(inv *bill.Invoice, model string, received cal.Date) (*FacturaRecibidaType, error) {
+	if inv.Supplier == nil {
+		return nil, fmt.Errorf("missing supplier")
+	}
+	if inv.Totals == nil {
+		return nil, fmt.Errorf("missing totals")
+	}
+	if inv.Currency != currency.CodeEmpty && inv.Currency != currency.EUR {
+		var err error
+		if inv, err = inv.ConvertInto(currency.EUR); err != nil {
+			return nil, fmt.Errorf("converting to EUR: %w", err)
+		}
+	}
+
+	description, err := convert.NewDescription(inv, convert.DescriptionFromNotes(), convert.DescriptionFromLines())
+	if err != nil {
+		return nil, err
+	}
+	emisor, err := newEmisorFacturaRecibida(inv)
+	if err != nil {
+		return nil, err
+	}
+	claves, err := convert.NewClaves(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	sign := num.MakeAmount(1, 0)
+	if inv.Type == bill.InvoiceTypeCreditNote {
+		sign = sign.Negate()
+	}
+
+	rec := &FacturaRecibidaType{
+		EmisorFacturaRecibida: emisor,
+		CabeceraFactura: &CabeceraFacturaRecibidaType{
+			SerieFactura:           inv.Series.String(),
+			NumFactura:             inv.Code.String(),
+			FechaExpedicionFactura: convert.FormatDate(inv.IssueDate),
+			FechaRecepcion:         convert.FormatDate(received),
+			TipoFactura:            tipoFacturaRecibida(inv),
+		},
+		DatosFactura: &DatosFacturaRecibidaType{
+			DescripcionOperacion: description,
+			Claves:               claves,
+			ImporteTotalFactura:  inv.Totals.TotalWithTax.Multiply(sign).Rescale(2).String(),
+		},
+		IVA: newIVARecibida(inv, sign),
+	}
+	if model == Modelo140 {
+		rec.Renta = newRentaGastos(inv)
+	}
+
+	return rec, nil
+}
+
+// NewIDRecibida builds the reference used to cancel the LROE record of a
+// received invoice.
+func NewIDRecibida(inv *bill.Invoice) (*IDRecibidaType, error) {
+	if inv.Supplier == nil {
+		return nil, fmt.Errorf("missing supplier")
+	}
+	emisor, err := newEmisorFacturaRecibida(inv)
+	if err != nil {
+		return nil, err
+	}
+	return &IDRecibidaType{
+		EmisorFacturaRecibida: emisor,
+		CabeceraFactura: &IDCabeceraRecibidaType{
+			SerieFactura:           inv.Series.String(),
+			NumFactura:             inv.Code.String(),
+			FechaExpedicionFactura: convert.FormatDate(inv.IssueDate),
+		},
+	}, nil
+}
+
+// NewReceivedCreateRequest assembles a request to register a received invoice,
+// as a received invoice under Modelo 240 or an expense under Modelo 140.
+func NewReceivedCreateRequest(sup *Supplier, rec *FacturaRecibidaType) (*Request, error) {
+	if sup.Model == Modelo140 {
+		body := &LROEPF140GastosConFacturaAltaModifPeticion{
+			LROENamespace: schemaLROE140GastosAlta,
+			Cabecera:      newCabeceraType(sup, sectionExpenses, operacionEnumAlta),
+			Gastos: &GastosType{
+				Gasto: []*FacturaRecibidaType{rec},
+			},
+		}
+		return newRequest(sup, sectionExpenses, body)
+	}
+
+	body := &LROEPJ240FacturasRecibidasAltaModifPeticion{
+		LROENamespace: schemaLROE240RecibidasAlta,
+		Cabecera:      newCabeceraType(sup, sectionReceived, operacionEnumAlta),
+		FacturasRecibidas: &FacturasRecibidasType{
+			FacturaRecibida: []*FacturaRecibidaType{rec},
+		},
+	}
+
+	return newRequest(sup, sectionReceived, body)
+}
+
+// NewReceivedCancelRequest assembles a request to cancel the record of a
+// received invoice.
+func NewReceivedCancelRequest(sup *Supplier, id *IDRecibidaType) (*Request, error) {
+	if sup.Model == Modelo140 {
+		body := &LROEPF140GastosConFacturaAnulacionPeticion{
+			LROENamespace: schemaLROE140GastosAnulacion,
+			Cabecera:      newCabeceraType(sup, sectionExpenses, operacionEnumAnulacion),
+			Gastos: &AnulacionesGastosType{
+				Gasto: []*IDRecibidaType{id},
+			},
+		}
+		return newRequest(sup, sectionExpenses, body)
+	}
+
+	body := &LROEPJ240FacturasRecibidasAnulacionPeticion{
+		LROENamespace: schemaLROE240RecibidasAnulacion,
+		Cabecera:      newCabeceraType(sup, sectionReceived, operacionEnumAnulacion),
+		FacturasRecibidas: &AnulacionesFacturasRecibidasType{
+			FacturaRecibida: []*IDRecibidaType{id},
+		},
+	}
+
+	return newRequest(sup, sectionReceived, body)
+}
+
+// NewReceivedFetchRequest assembles a request to fetch the records of
+// received invoices, optionally filtered by supplier.
+func NewReceivedFetchRequest(sup *Supplier, page int, emisor *EmisorFacturaRecibidaType) (*Request, error) {
+	filter := &FiltroConsultaFacturasRecibidasType{
+		EmisorFacturaRecibida: emisor,
+		NumPaginaConsulta:     page,
+	}
+
+	if sup.Model == Modelo140 {
+		body := &LROEPF140GastosConFacturaConsultaPeticion{
+			LROENamespace:        schemaLROE140GastosConsulta,
+			Cabecera:             newCabeceraType(sup, sectionExpenses, operacionEnumConsulta),
+			FiltroConsultaGastos: filter,
+		}
+		return newRequest(sup, sectionExpenses, body)
+	}
+
+	body := &LROEPJ240FacturasRecibidasConsultaPeticion{
+		LROENamespace:                   schemaLROE240RecibidasConsulta,
+		Cabecera:                        newCabeceraType(sup, sectionReceived, operacionEnumConsulta),
+		FiltroConsultaFacturasRecibidas: filter,
+	}
+
+	return newRequest(sup, sectionReceived, body)
+}
+
+// Records returns the received invoices included in the response.
+func (r *LROEPJ240FacturasRecibidasConsultaRespuesta) Records() []*FacturaRecibidaType {
+	if r.FacturasRecibidas == nil {
+		return nil
+	}
+	return r.FacturasRecibidas.FacturaRecibida
+}
+
+// Records returns the expenses included in the response.
+func (r *LROEPF140GastosConFacturaConsultaRespuesta) Records() []*FacturaRecibidaType {
+	if r.Gastos == nil {
+		return nil
+	}
+	return r.Gastos.Gasto
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPJ240FacturasRecibidasAltaModifRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPJ240FacturasRecibidasAltaModifRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPJ240FacturasRecibidasAltaModifRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140GastosConFacturaAltaModifRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140GastosConFacturaAltaModifRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140GastosConFacturaAltaModifRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPJ240FacturasRecibidasAnulacionRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPJ240FacturasRecibidasAnulacionRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPJ240FacturasRecibidasAnulacionRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+// FirstErrorCode returns the first error code in the response.
+func (r *LROEPF140GastosConFacturaAnulacionRespuesta) FirstErrorCode() string {
+	return r.Registros.first().CodigoErrorRegistro
+}
+
+// FirstErrorDescription returns the first error description in the response.
+func (r *LROEPF140GastosConFacturaAnulacionRespuesta) FirstErrorDescription() string {
+	return r.Registros.first().DescripcionErrorRegistroES
+}
+
+// PresentationDate returns the date the request was presented.
+func (r *LROEPF140GastosConFacturaAnulacionRespuesta) PresentationDate() string {
+	return r.DatosPresentacion.date()
+}
+
+func newEmisorFacturaRecibida(inv *bill.Invoice) (*EmisorFacturaRecibidaType, error) {
+	e := &EmisorFacturaRecibidaType{
+		ApellidosNombreRazonSocial: inv.Supplier.Name,
+	}
+	e.NIF, e.IDOtro = convert.PartyIdentity(inv.Supplier)
+	if e.NIF == "" && e.IDOtro == nil {
+		return nil, fmt.Errorf("supplier: missing tax ID or identity")
+	}
+	return e, nil
+}
+
+func tipoFacturaRecibida(inv *bill.Invoice) string {
+	switch {
+	case inv.Type == bill.InvoiceTypeCreditNote || inv.Type == bill.InvoiceTypeCorrective:
+		if len(inv.Preceding) > 0 {
+			if code := inv.Preceding[0].Ext.Get(tbai.ExtKeyCorrection); code != cbc.CodeEmpty {
+				return code.String()
+			}
+		}
+		return tipoFacturaRectificativa
+	case inv.HasTags(tax.TagSimplified):
+		return tipoFacturaSimplificada
+	}
+	return tipoFacturaCompleta
+}
+
+func newIVARecibida(inv *bill.Invoice, sign num.Amount) *IVARecibidaType {
+	if inv.Totals.Taxes == nil {
+		return nil
+	}
+	vat := inv.Totals.Taxes.Category(tax.CategoryVAT)
+	if vat == nil {
+		return nil
+	}
+
+	iva := new(IVARecibidaType)
+	for _, rate := range vat.Rates {
+		isp := "N"
+		if inv.HasTags(tax.TagReverseCharge) || rate.Ext.Get(tbai.ExtKeyExempt) == "S2" {
+			isp = "S"
+		}
+		compra := compraGastos
+		if rate.Ext.Get(tbai.ExtKeyProduct) == "goods" {
+			compra = compraBienesCorrientes
+		}
+		d := &DetalleIVARecibidaType{
+			CompraBienesCorrientesGastosBienesInversion: compra,
+			InversionSujetoPasivo:                       isp,
+			BaseImponible:                               rate.Base.Multiply(sign).Rescale(2).String(),
+		}
+		if rate.Percent != nil {
+			amount := rate.Amount.Multiply(sign).Rescale(2).String()
+			d.TipoImpositivo = rate.Percent.Amount().Rescale(2).String()
+			d.CuotaIVASoportada = amount
+			d.CuotaIVADeducible = amount
+		}
+		if rate.Surcharge != nil {
+			d.TipoRecargoEquivalencia = rate.Surcharge.Percent.Amount().Rescale(2).String()
+			d.CuotaRecargoEquivalencia = rate.Surcharge.Amount.Multiply(sign).Rescale(2).String()
+		}
+		iva.DetalleIVA = append(iva.DetalleIVA, d)
+	}
+	return iva
+}
+
+func newRentaGastos(inv *bill.Invoice) *RentaGastosType {
+	d := new(DetalleRentaGastosType)
+	if inv.Customer != nil {
+		d.Epigrafe = inv.Customer.Ext.Get(tbai.ExtKeyBIActivity).String()
+	}
+	if inv.HasTags(es.TagCashBasis) {
+		d.CriterioCobrosYPagos = "S"
+	}
+	return &RentaGastosType{
+		DetalleRenta: []*DetalleRentaGastosType{d},
+	}
+}
@@ -0,0 +1,228 @@
+package ebizkaia
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+)
+
+func TestNewFacturaRecibida(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+
+	rec, err := NewFacturaRecibida(inv, Modelo240, cal.MakeDate(2024, 3, 20))
+	if err != nil {
+		t.Fatalf("NewFacturaRecibida: %v", err)
+	}
+
+	if rec.EmisorFacturaRecibida.NIF != "12345678Z" {
+		t.Errorf("NIF = %q, want 12345678Z", rec.EmisorFacturaRecibida.NIF)
+	}
+	head := rec.CabeceraFactura
+	if head.SerieFactura != "FREEL" || head.NumFactura != "001" {
+		t.Errorf("invoice = %s-%s, want FREEL-001", head.SerieFactura, head.NumFactura)
+	}
+	if head.FechaExpedicionFactura != "15-03-2024" {
+		t.Errorf("FechaExpedicionFactura = %q, want 15-03-2024", head.FechaExpedicionFactura)
+	}
+	if head.FechaRecepcion != "20-03-2024" {
+		t.Errorf("FechaRecepcion = %q, want 20-03-2024", head.FechaRecepcion)
+	}
+	if head.TipoFactura != "F1" {
+		t.Errorf("TipoFactura = %q, want F1", head.TipoFactura)
+	}
+	if rec.DatosFactura.DescripcionOperacion != "Consulting services rendered in March 2024" {
+		t.Errorf("DescripcionOperacion = %q", rec.DatosFactura.DescripcionOperacion)
+	}
+	if rec.DatosFactura.ImporteTotalFactura != "726.00" {
+		t.Errorf("ImporteTotalFactura = %q, want 726.00", rec.DatosFactura.ImporteTotalFactura)
+	}
+	if rec.Renta != nil {
+		t.Errorf("Renta should only be included for Modelo 140")
+	}
+
+	if rec.IVA == nil || len(rec.IVA.DetalleIVA) != 1 {
+		t.Fatalf("IVA = %+v, want 1 detail", rec.IVA)
+	}
+	d := rec.IVA.DetalleIVA[0]
+	want := DetalleIVARecibidaType{
+		CompraBienesCorrientesGastosBienesInversion: "G",
+		InversionSujetoPasivo:                       "N",
+		BaseImponible:                               "600.00",
+		TipoImpositivo:                              "21.00",
+		CuotaIVASoportada:                           "126.00",
+		CuotaIVADeducible:                           "126.00",
+	}
+	if *d != want {
+		t.Errorf("DetalleIVA = %+v, want %+v", *d, want)
+	}
+}
+
+func TestNewFacturaRecibidaModelo140(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	inv.Customer.Ext = inv.Customer.Ext.Set(tbai.ExtKeyBIActivity, "861000")
+
+	rec, err := NewFacturaRecibida(inv, Modelo140, cal.MakeDate(2024, 3, 20))
+	if err != nil {
+		t.Fatalf("NewFacturaRecibida: %v", err)
+	}
+	if rec.Renta == nil || len(rec.Renta.DetalleRenta) != 1 {
+		t.Fatalf("Renta = %+v, want 1 detail", rec.Renta)
+	}
+	if rec.Renta.DetalleRenta[0].Epigrafe != "861000" {
+		t.Errorf("Epigrafe = %q, want 861000", rec.Renta.DetalleRenta[0].Epigrafe)
+	}
+}
+
+func TestNewFacturaRecibidaCreditNote(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	inv.Type = bill.InvoiceTypeCreditNote
+
+	rec, err := NewFacturaRecibida(inv, Modelo240, cal.MakeDate(2024, 3, 20))
+	if err != nil {
+		t.Fatalf("NewFacturaRecibida: %v", err)
+	}
+	if rec.CabeceraFactura.TipoFactura != "R1" {
+		t.Errorf("TipoFactura = %q, want R1", rec.CabeceraFactura.TipoFactura)
+	}
+	if rec.DatosFactura.ImporteTotalFactura != "-726.00" {
+		t.Errorf("ImporteTotalFactura = %q, want -726.00", rec.DatosFactura.ImporteTotalFactura)
+	}
+	d := rec.IVA.DetalleIVA[0]
+	if d.BaseImponible != "-600.00" || d.CuotaIVASoportada != "-126.00" {
+		t.Errorf("DetalleIVA = %+v, want negative amounts", *d)
+	}
+}
+
+func TestNewReceivedCreateRequest(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+
+	tests := []struct {
+		model    string
+		apartado string
+		checks   []string
+	}{
+		{
+			model:    Modelo240,
+			apartado: "2",
+			checks: []string{
+				`lrpjfrap:LROEPJ240FacturasRecibidasAltaModifPeticion`,
+				`<Capitulo>2</Capitulo>`,
+				`<FacturasRecibidas><FacturaRecibida>`,
+				`<NIF>12345678Z</NIF>`,
+			},
+		},
+		{
+			model:    Modelo140,
+			apartado: "2.1",
+			checks: []string{
+				`lrpfgcfap:LROEPF140GastosConFacturaAltaModifPeticion`,
+				`<Capitulo>2</Capitulo><Subcapitulo>2.1</Subcapitulo>`,
+				`<Gastos><Gasto>`,
+				`<Renta><DetalleRenta>`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			sup := &Supplier{
+				Year:  "2024",
+				NIF:   "B64847106",
+				Name:  "Cliente Consulting S.L.",
+				Model: tt.model,
+			}
+			rec, err := NewFacturaRecibida(inv, tt.model, cal.MakeDate(2024, 3, 20))
+			if err != nil {
+				t.Fatalf("NewFacturaRecibida: %v", err)
+			}
+
+			req, err := NewReceivedCreateRequest(sup, rec)
+			if err != nil {
+				t.Fatalf("NewReceivedCreateRequest: %v", err)
+			}
+
+			body := gunzip(t, req.Payload)
+			for _, want := range tt.checks {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("payload missing %q\npayload:\n%s", want, body)
+				}
+			}
+			if tt.model == Modelo240 && bytes.Contains(body, []byte("Subcapitulo")) {
+				t.Errorf("payload should not contain Subcapitulo:\n%s", body)
+			}
+
+			var jhead N3Header
+			if err := json.Unmarshal(req.Header, &jhead); err != nil {
+				t.Fatalf("json header: %v", err)
+			}
+			if jhead.Apartado != tt.apartado {
+				t.Errorf("N3 header apa = %q, want %s", jhead.Apartado, tt.apartado)
+			}
+		})
+	}
+}
+
+func TestNewReceivedCancelRequest(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	sup := &Supplier{Year: "2024", NIF: "B64847106", Name: "Cliente Consulting S.L."}
+
+	id, err := NewIDRecibida(inv)
+	if err != nil {
+		t.Fatalf("NewIDRecibida: %v", err)
+	}
+	req, err := NewReceivedCancelRequest(sup, id)
+	if err != nil {
+		t.Fatalf("NewReceivedCancelRequest: %v", err)
+	}
+
+	body := gunzip(t, req.Payload)
+	want := `<FacturaRecibida><EmisorFacturaRecibida><NIF>12345678Z</NIF>`
+	if !bytes.Contains(body, []byte(want)) {
+		t.Errorf("payload missing %q\npayload:\n%s", want, body)
+	}
+	if !bytes.Contains(body, []byte(`<Operacion>AN0</Operacion>`)) {
+		t.Errorf("payload missing cancel operation:\n%s", body)
+	}
+}
+
+func TestReceivedConsultaRespuesta(t *testing.T) {
+	data := []byte(`<LROEPJ240FacturasRecibidasConsultaRespuesta>
+		<FacturasRecibidas>
+			<FacturaRecibida>
+				<EmisorFacturaRecibida><NIF>12345678Z</NIF></EmisorFacturaRecibida>
+				<CabeceraFactura><NumFactura>001</NumFactura></CabeceraFactura>
+			</FacturaRecibida>
+		</FacturasRecibidas>
+	</LROEPJ240FacturasRecibidasConsultaRespuesta>`)
+
+	resp := new(LROEPJ240FacturasRecibidasConsultaRespuesta)
+	if err := xml.Unmarshal(data, resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	recs := resp.Records()
+	if len(recs) != 1 || recs[0].CabeceraFactura.NumFactura != "001" {
+		t.Errorf("Records() = %+v, want invoice 001", recs)
+	}
+
+	if recs := new(LROEPF140GastosConFacturaConsultaRespuesta).Records(); recs != nil {
+		t.Errorf("Records() = %+v, want nil", recs)
+	}
+}
+
+func TestNewFacturaRecibidaUnidentifiedSupplier(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	inv.Supplier.TaxID = nil
+	inv.Supplier.Identities = nil
+
+	if _, err := NewFacturaRecibida(inv, Modelo240, cal.MakeDate(2024, 3, 20)); err == nil {
+		t.Error("expected error for a supplier without tax ID or identity")
+	}
+	if _, err := NewIDRecibida(inv); err == nil {
+		t.Error("expected error for a supplier without tax ID or identity")
+	}
+}
@@ -0,0 +1,181 @@
+package ebizkaia
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/currency"
+	"github.com/invopop/gobl/num"
+	"github.com/invopop/gobl/regimes/es"
+)
+
+// maxDetalleRenta is the maximum number of income details per record.
+const maxDetalleRenta = 10
+
+type rentaGroup struct {
+	detalle *DetalleRentaIngresosType
+	amount  num.Amount
+	details *RentaDetails
+}
+
+// RentaDetails contains the income details of an activity reported under
+// Modelo 140 that cannot be determined from the invoice.
+type RentaDetails struct {
+	// Activity (epígrafe) the details apply to. When empty, they apply to all
+	// the activities of the invoice without details of their own.
+	Activity string
+	// Territory where the activity is registered (TerritorioAltaActividad).
+	Territory string
+	// CadastralRef identifies the property of rental income
+	// (NumeroFijoOReferenciaCatastral).
+	CadastralRef string
+	// IncomeAmount is the income for IRPF purposes of the activity, as a
+	// positive amount also for credit notes. Only reported when it differs
+	// from the VAT base (ImporteIngresoIRPF).
+	IncomeAmount *num.Amount
+	// IncomeCause is the reason code when the income for IRPF purposes
+	// differs from the VAT base (CausaIngresoIRPFDiferenteBaseImpoIVA).
+	IncomeCause string
+}
+
+// NewRenta builds the income details (DetalleRenta) reported under Modelo 140
+// from the invoice, with an entry for each activity (epígrafe). The activity
+// is taken from each line's item extension, falling back to the supplier's,
+// and the rest of the details from those provided for the activity, if any.
+func NewRenta(inv *bill.Invoice, details []*RentaDetails) ([]*DetalleRentaIngresosType, error) {
+	if inv.Currency != currency.CodeEmpty && inv.Currency != currency.EUR {
+		var err error
+		if inv, err = inv.ConvertInto(currency.EUR); err != nil {
+			return nil, fmt.Errorf("converting to EUR: %w", err)
+		}
+	}
+
+	var activity cbc.Code
+	if inv.Supplier != nil {
+		activity = inv.Supplier.Ext.Get(tbai.ExtKeyBIActivity)
+	}
+	criterio := ""
+	if inv.HasTags(es.TagCashBasis) {
+		criterio = "S"
+	}
+
+	groups := []*rentaGroup{}
+	byKey := make(map[cbc.Code]*rentaGroup)
+	add := func(epigrafe cbc.Code, amount num.Amount) {
+		g, ok := byKey[epigrafe]
+		if !ok {
+			rd := rentaDetailsFor(details, epigrafe.String())
+			g = &rentaGroup{
+				detalle: &DetalleRentaIngresosType{
+					TerritorioAltaActividad:        rd.Territory,
+					Epigrafe:                       epigrafe.String(),
+					NumeroFijoOReferenciaCatastral: rd.CadastralRef,
+					CriterioCobrosYPagos:           criterio,
+				},
+				amount:  num.MakeAmount(0, 2),
+				details: rd,
+			}
+			byKey[epigrafe] = g
+			groups = append(groups, g)
+		}
+		g.amount = g.amount.Add(amount)
+	}
+
+	for _, line := range inv.Lines {
+		epigrafe := activity
+		if line.Item != nil {
+			if v := line.Item.Ext.Get(tbai.ExtKeyBIActivity); v != cbc.CodeEmpty {
+				epigrafe = v
+			}
+		}
+		amount := num.MakeAmount(0, 2)
+		if line.Total != nil {
+			amount = *line.Total
+		}
+		add(epigrafe, amount)
+	}
+	if len(groups) == 0 {
+		add(activity, num.MakeAmount(0, 2))
+	}
+
+	if len(groups) > maxDetalleRenta {
+		return nil, fmt.Errorf("too many income details (%d), max %d", len(groups), maxDetalleRenta)
+	}
+	for _, rd := range details {
+		if rd.Activity != "" && byKey[cbc.Code(rd.Activity)] == nil {
+			return nil, fmt.Errorf("income details: activity %s not in invoice", rd.Activity)
+		}
+		if rd.Activity == "" && rd.IncomeAmount != nil && len(groups) > 1 {
+			return nil, errors.New("income details: activity required for the income amount of invoices with several activities")
+		}
+	}
+	if inv.Totals != nil {
+		spreadTotal(groups, inv.Totals.Total)
+	}
+
+	sign := num.MakeAmount(1, 0)
+	if inv.Type == bill.InvoiceTypeCreditNote {
+		sign = sign.Negate()
+	}
+
+	out := make([]*DetalleRentaIngresosType, len(groups))
+	for i, g := range groups {
+		if ia := g.details.IncomeAmount; ia != nil {
+			base := g.amount.Rescale(2)
+			income := ia.Multiply(sign).Rescale(2)
+			if income.Compare(base) != 0 {
+				if g.details.IncomeCause == "" {
+					return nil, fmt.Errorf("income details: activity %s: cause required when the income differs from the VAT base", g.detalle.Epigrafe)
+				}
+				g.detalle.IngresoAComputarIRPFDiferenteBaseImpoIVA = "S"
+				g.detalle.CausaIngresoIRPFDiferenteBaseImpoIVA = g.details.IncomeCause
+				g.detalle.ImporteIngresoIRPF = income.String()
+			}
+		}
+		out[i] = g.detalle
+	}
+	return out, nil
+}
+
+// rentaDetailsFor provides the details of the activity, or the ones for all
+// the activities if none match.
+func rentaDetailsFor(details []*RentaDetails, activity string) *RentaDetails {
+	var def *RentaDetails
+	for _, rd := range details {
+		switch rd.Activity {
+		case activity:
+			return rd
+		case "":
+			def = rd
+		}
+	}
+	if def == nil {
+		def = new(RentaDetails)
+	}
+	return def
+}
+
+// spreadTotal adjusts the amounts of the groups so that they add up to the
+// invoice's total without taxes, which is the VAT base compared with the
+// income for IRPF purposes, spreading document level discounts and
+// charges, or taxes included in the prices, in proportion to the amount of
+// each group. Rounding differences go to the last group.
+func spreadTotal(groups []*rentaGroup, total num.Amount) {
+	sum := num.MakeAmount(0, 2)
+	for _, g := range groups {
+		sum = sum.Add(g.amount)
+	}
+	diff := total.Rescale(2).Subtract(sum.Rescale(2))
+	rest := diff
+	for i, g := range groups {
+		adj := rest
+		if i < len(groups)-1 && !sum.IsZero() {
+			adj = diff.Multiply(g.amount).Divide(sum).Rescale(2)
+		}
+		g.amount = g.amount.Rescale(2).Add(adj)
+		rest = rest.Subtract(adj)
+	}
+}
@@ -0,0 +1,221 @@
+package ebizkaia
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/num"
+	"github.com/invopop/gobl/org"
+	"github.com/invopop/gobl/regimes/es"
+	"github.com/invopop/gobl/tax"
+)
+
+func TestNewRentaSupplierActivity(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+
+	renta, err := NewRenta(inv, nil)
+	if err != nil {
+		t.Fatalf("NewRenta: %v", err)
+	}
+	if len(renta) != 1 {
+		t.Fatalf("len(renta) = %d, want 1", len(renta))
+	}
+	if renta[0].Epigrafe != "722300" {
+		t.Errorf("Epigrafe = %q, want 722300", renta[0].Epigrafe)
+	}
+	if renta[0].IngresoAComputarIRPFDiferenteBaseImpoIVA != "" || renta[0].ImporteIngresoIRPF != "" {
+		t.Errorf("IRPF income fields should be empty: %+v", renta[0])
+	}
+}
+
+func TestNewRentaSplitByActivity(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	inv.SetTags(es.TagCashBasis)
+	inv.Lines = append(inv.Lines,
+		&bill.Line{
+			Index:    2,
+			Quantity: num.MakeAmount(1, 0),
+			Item: &org.Item{
+				Name:  "Office rental",
+				Price: num.NewAmount(500, 0),
+				Ext:   tax.Extensions{tbai.ExtKeyBIActivity: "861000"},
+			},
+			Taxes: tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+		},
+		&bill.Line{
+			Index:    3,
+			Quantity: num.MakeAmount(2, 0),
+			Item:     &org.Item{Name: "Training", Price: num.NewAmount(100, 0)},
+			Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+		},
+	)
+	if err := inv.Calculate(); err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+
+	income := num.MakeAmount(45000, 2)
+	renta, err := NewRenta(inv, []*RentaDetails{
+		{
+			Territory: "01",
+		},
+		{
+			Activity:     "861000",
+			Territory:    "01",
+			CadastralRef: "1234567AB1234C0001XY",
+			IncomeAmount: &income,
+			IncomeCause:  "01",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRenta: %v", err)
+	}
+	if len(renta) != 2 {
+		t.Fatalf("len(renta) = %d, want 2", len(renta))
+	}
+
+	checks := []struct {
+		got, want string
+	}{
+		{renta[0].Epigrafe, "722300"},
+		{renta[0].TerritorioAltaActividad, "01"},
+		{renta[0].CriterioCobrosYPagos, "S"},
+		{renta[0].IngresoAComputarIRPFDiferenteBaseImpoIVA, ""},
+		{renta[0].ImporteIngresoIRPF, ""},
+		{renta[0].NumeroFijoOReferenciaCatastral, ""},
+		{renta[1].Epigrafe, "861000"},
+		{renta[1].TerritorioAltaActividad, "01"},
+		{renta[1].NumeroFijoOReferenciaCatastral, "1234567AB1234C0001XY"},
+		{renta[1].IngresoAComputarIRPFDiferenteBaseImpoIVA, "S"},
+		{renta[1].CausaIngresoIRPFDiferenteBaseImpoIVA, "01"},
+		{renta[1].ImporteIngresoIRPF, "450.00"},
+	}
+	for i, c := range checks {
+		if c.got != c.want {
+			t.Errorf("check %d: got %q, want %q", i, c.got, c.want)
+		}
+	}
+}
+
+func TestNewRentaTooManyEntries(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	for i := 0; i < maxDetalleRenta; i++ {
+		inv.Lines = append(inv.Lines, &bill.Line{
+			Index:    i + 2,
+			Quantity: num.MakeAmount(1, 0),
+			Item: &org.Item{
+				Name:  "Service",
+				Price: num.NewAmount(10, 0),
+				Ext:   tax.Extensions{tbai.ExtKeyBIActivity: cbc.Code(strconv.Itoa(100000 + i))},
+			},
+			Taxes: tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+		})
+	}
+	if err := inv.Calculate(); err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+
+	if _, err := NewRenta(inv, nil); err == nil {
+		t.Error("expected error with more than 10 income details")
+	}
+}
+
+func TestNewRentaCreditNote(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	inv.Type = bill.InvoiceTypeCreditNote
+	inv.Discounts = []*bill.Discount{{
+		Reason: "Promotion",
+		Amount: num.MakeAmount(10000, 2),
+		Taxes:  tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+	}}
+	if err := inv.Calculate(); err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+
+	income := num.MakeAmount(5000, 2)
+	renta, err := NewRenta(inv, []*RentaDetails{{IncomeAmount: &income, IncomeCause: "01"}})
+	if err != nil {
+		t.Fatalf("NewRenta: %v", err)
+	}
+	if renta[0].ImporteIngresoIRPF != "-50.00" {
+		t.Errorf("ImporteIngresoIRPF = %q, want -50.00", renta[0].ImporteIngresoIRPF)
+	}
+}
+
+func TestNewRentaIncomeMatchingBase(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	income := inv.Totals.Total
+
+	renta, err := NewRenta(inv, []*RentaDetails{{IncomeAmount: &income, IncomeCause: "01"}})
+	if err != nil {
+		t.Fatalf("NewRenta: %v", err)
+	}
+	if renta[0].IngresoAComputarIRPFDiferenteBaseImpoIVA != "" || renta[0].ImporteIngresoIRPF != "" {
+		t.Errorf("IRPF income fields should be empty: %+v", renta[0])
+	}
+}
+
+func TestNewRentaErrors(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	income := num.MakeAmount(100, 0)
+
+	if _, err := NewRenta(inv, []*RentaDetails{{IncomeAmount: &income}}); err == nil {
+		t.Error("expected error without the cause of a different income")
+	}
+	if _, err := NewRenta(inv, []*RentaDetails{{Activity: "999999", Territory: "01"}}); err == nil {
+		t.Error("expected error with an activity not in the invoice")
+	}
+}
+
+func TestNewRentaSpreadDiscounts(t *testing.T) {
+	inv := test.LoadInvoice("invoice-bi-pf-modelo140.json")
+	inv.Lines = []*bill.Line{
+		{
+			Index:    1,
+			Quantity: num.MakeAmount(1, 0),
+			Item:     &org.Item{Name: "Consulting", Price: num.NewAmount(300, 0)},
+			Taxes:    tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+		},
+		{
+			Index:    2,
+			Quantity: num.MakeAmount(1, 0),
+			Item: &org.Item{
+				Name:  "Office rental",
+				Price: num.NewAmount(100, 0),
+				Ext:   tax.Extensions{tbai.ExtKeyBIActivity: "861000"},
+			},
+			Taxes: tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+		},
+	}
+	inv.Discounts = []*bill.Discount{{
+		Reason: "Promotion",
+		Amount: num.MakeAmount(4000, 2),
+		Taxes:  tax.Set{&tax.Combo{Category: tax.CategoryVAT, Rate: "general"}},
+	}}
+	if err := inv.Calculate(); err != nil {
+		t.Fatalf("calculate: %v", err)
+	}
+
+	// The VAT bases after the discount are 270.00 and 90.00, so only the
+	// second activity reports a different income.
+	a1, a2 := num.MakeAmount(27000, 2), num.MakeAmount(10000, 2)
+	renta, err := NewRenta(inv, []*RentaDetails{
+		{Activity: "722300", IncomeAmount: &a1, IncomeCause: "01"},
+		{Activity: "861000", IncomeAmount: &a2, IncomeCause: "01"},
+	})
+	if err != nil {
+		t.Fatalf("NewRenta: %v", err)
+	}
+	if len(renta) != 2 {
+		t.Fatalf("len(renta) = %d, want 2", len(renta))
+	}
+	if renta[0].ImporteIngresoIRPF != "" {
+		t.Errorf("ImporteIngresoIRPF = %q, want empty", renta[0].ImporteIngresoIRPF)
+	}
+	if renta[1].ImporteIngresoIRPF != "100.00" {
+		t.Errorf("ImporteIngresoIRPF = %q, want 100.00", renta[1].ImporteIngresoIRPF)
+	}
+}
@@ -3,6 +3,7 @@
 package gateways
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -14,8 +15,10 @@ import (
 	"github.com/invopop/gobl.ticketbai/ca"
 	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
 	"github.com/invopop/gobl/l10n"
-	"github.com/invopop/xmldsig"
+	"github.com/invopop/gobl/num"
+	"github.com/invopop/gobl/org"
 )
 
 // Environment defines the environment to use for connections
@@ -29,11 +32,32 @@ const (
 
 // Standard gateway error responses. Keys match the ones from main package.
 var (
-	ErrConnection = newError("connection")
-	ErrValidation = newError("validation")
-	ErrDuplicate  = newError("duplicate")
+	ErrConnection       = newError("connection")
+	ErrValidation       = newError("validation")
+	ErrDuplicate        = newError("duplicate")
+	ErrNotFound         = newError("not-found")
+	ErrAlreadyCancelled = newError("already-cancelled")
 )
 
+// Response codes from the Gipuzkoa and Araba gateways that are used to classify
+// the errors when cancelling documents. Both gateways share the same list.
+const (
+	tbaiRespCodeNotFound  = "011" // “No existe la factura a anular”
+	tbaiRespCodeCancelled = "012" // “La factura ya ha sido anulada”
+)
+
+// Receipt contains the details provided by the gateway once a document has
+// been accepted.
+type Receipt struct {
+	// ID assigned to the document or request by the gateway: the TicketBAI
+	// identifier in Gipuzkoa and Araba, or the registration number in Bizkaia.
+	ID string `json:"id,omitempty"`
+	// ReceivedAt contains the reception date as provided by the gateway.
+	ReceivedAt string `json:"received_at,omitempty"`
+	// CSV is the secure verification code, if provided.
+	CSV string `json:"csv,omitempty"`
+}
+
 // Error allows for structured responses from the gateway to be able to
 // response codes and messages.
 type Error struct {
@@ -115,24 +139,147 @@ func (e *Error) Is(target error) bool {
 type Connection interface {
 	// Post sends the complete TicketBAI document to the remote end-point. We assume
 	// the document has been fully prepared and signed.
-	Post(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI) error
-	Cancel(ctx context.Context, inv *bill.Invoice, doc *convert.AnulaTicketBAI) error
+	Post(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI) (*Receipt, error)
+	// Cancel sends the signed cancellation document to the remote end-point.
+	Cancel(ctx context.Context, inv *bill.Invoice, doc *convert.AnulaTicketBAI) (*Receipt, error)
+	// PostSigned sends the exact XML of a signed TicketBAI document.
+	PostSigned(ctx context.Context, inv *bill.Invoice, doc *convert.SignedDocument) (*Receipt, error)
+	// CancelSigned sends the exact XML of a signed cancellation document.
+	CancelSigned(ctx context.Context, inv *bill.Invoice, doc *convert.SignedCancelDocument) (*Receipt, error)
 }
 
-// New instantiates a new connection for the given zone and environment.
-func New(env Environment, zone l10n.Code, cert *xmldsig.Certificate) (Connection, error) {
-	tlsConf, err := cert.TLSAuthConfig()
-	if err != nil {
-		return nil, fmt.Errorf("preparing TLS config: %w", err)
-	}
+// ReceivedConnection is implemented by the connections to gateways that also
+// register the invoices received by the taxpayer, currently only Bizkaia.
+type ReceivedConnection interface {
+	// PostReceived registers the invoice received by the customer on the given date.
+	PostReceived(ctx context.Context, inv *bill.Invoice, received cal.Date) (*Receipt, error)
+	// CancelReceived cancels the record of a received invoice.
+	CancelReceived(ctx context.Context, inv *bill.Invoice) (*Receipt, error)
+	// FetchReceived retrieves the records of the invoices received by the party.
+	FetchReceived(ctx context.Context, party *org.Party, year string, page int) ([]*ReceivedInvoice, error)
+}
 
-	certs, err := rootCAPool()
-	if err != nil {
-		return nil, fmt.Errorf("preparing cert pool: %w", err)
+// ReceivedInvoice contains the main details of a received invoice as
+// registered in the gateway.
+type ReceivedInvoice struct {
+	SupplierNIF  string `json:"supplier_nif,omitempty"`
+	SupplierName string `json:"supplier_name,omitempty"`
+	Series       string `json:"series,omitempty"`
+	Code         string `json:"code"`
+	Type         string `json:"type,omitempty"`
+	IssueDate    string `json:"issue_date"`
+	ReceivedDate string `json:"received_date,omitempty"`
+	Total        string `json:"total"`
+}
+
+// IncomeConnection is implemented by the connections to gateways that register
+// the income of natural persons not documented with a TicketBAI invoice,
+// currently only Bizkaia under Modelo 140.
+type IncomeConnection interface {
+	// PostIncome registers the income of the party.
+	PostIncome(ctx context.Context, party *org.Party, inc *Income) (*Receipt, error)
+	// CancelIncome cancels the record of the party's income.
+	CancelIncome(ctx context.Context, party *org.Party, inc *Income) (*Receipt, error)
+	// FetchIncome retrieves a page of the records of the party's income in
+	// the given year.
+	FetchIncome(ctx context.Context, party *org.Party, year string, page int) ([]*Income, error)
+	// PostIncomeInvoice registers an invoice issued without TicketBAI, using
+	// the details of its unsigned document, along with the income details.
+	PostIncomeInvoice(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI, renta []*Renta) (*Receipt, error)
+	// CancelIncomeInvoice cancels the record of an invoice issued without
+	// TicketBAI.
+	CancelIncomeInvoice(ctx context.Context, inv *bill.Invoice) (*Receipt, error)
+}
+
+// Income describes income tied to an economic activity that is not
+// documented with an invoice, such as subsidies or capital gains.
+type Income struct {
+	// Date when the income was accrued.
+	Date cal.Date `json:"date"`
+	// Reference used to tell apart the income on the same date, optional.
+	Reference string `json:"ref,omitempty"`
+	// Concept code of the income from the LROE list of concepts.
+	Concept string `json:"concept"`
+	// Description of the income, optional.
+	Description string `json:"description,omitempty"`
+	// Amount of the income, in euros.
+	Amount num.Amount `json:"amount"`
+	// Activity code (epígrafe) the income is tied to. Defaults to the
+	// party's es-tbai-bi-activity extension.
+	Activity string `json:"activity,omitempty"`
+	// Territory where the activity is registered, optional.
+	Territory string `json:"territory,omitempty"`
+	// CadastralRef identifies the property for rental income, optional.
+	CadastralRef string `json:"cadastral_ref,omitempty"`
+}
+
+// RentaConnection is implemented by the connections to gateways that report
+// the income details of natural persons along with their invoices, currently
+// only Bizkaia under Modelo 140.
+type RentaConnection interface {
+	// PostWithRenta sends the TicketBAI document along with the income details.
+	PostWithRenta(ctx context.Context, inv *bill.Invoice, doc *convert.TicketBAI, renta []*Renta) (*Receipt, error)
+	// PostSignedWithRenta sends the exact XML of a signed TicketBAI document
+	// along with the income details.
+	PostSignedWithRenta(ctx context.Context, inv *bill.Invoice, doc *convert.SignedDocument, renta []*Renta) (*Receipt, error)
+}
+
+// Renta contains the details of the income of an activity of a natural
+// person reported along with an invoice under Modelo 140 in Bizkaia, which
+// cannot be determined from the invoice.
+type Renta struct {
+	// Activity code (epígrafe) the details apply to. When empty, they apply to
+	// all the activities of the invoice without details of their own.
+	Activity string `json:"activity,omitempty"`
+	// Territory where the activity is registered (TerritorioAltaActividad).
+	Territory string `json:"territory,omitempty"`
+	// CadastralRef identifies the property for rental income
+	// (NumeroFijoOReferenciaCatastral).
+	CadastralRef string `json:"cadastral_ref,omitempty"`
+	// IncomeAmount is the income for IRPF purposes, in euros and positive
+	// also for credit notes. It is only reported when different from the VAT
+	// base of the activity (ImporteIngresoIRPF).
+	IncomeAmount *num.Amount `json:"income_amount,omitempty"`
+	// IncomeCause is the reason code when the income for IRPF purposes is
+	// different from the VAT base (CausaIngresoIRPFDiferenteBaseImpoIVA).
+	IncomeCause string `json:"income_cause,omitempty"`
+}
+
+// Trust defines how the servers of the gateways are verified.
+type Trust struct {
+	// Roots contains the root certificates trusted to verify the servers,
+	// instead of the ones embedded in the ca package.
+	Roots *x509.CertPool
+	// Pins contains the certificates expected in the chain presented by the
+	// server. When set, at least one must match by public key.
+	Pins []*x509.Certificate
+}
+
+// New instantiates a new connection for the given zone and environment,
+// authenticating with the client certificate. The servers are verified
+// according to the trust settings, or against the embedded root
+// certificates if nil.
+func New(env Environment, zone l10n.Code, cert tls.Certificate, trust *Trust) (Connection, error) {
+	if trust == nil {
+		trust = new(Trust)
+	}
+	certs := trust.Roots
+	if certs == nil {
+		var err error
+		certs, err = ca.Pool()
+		if err != nil {
+			return nil, fmt.Errorf("preparing cert pool: %w", err)
+		}
 	}
 
-	tlsConf.RootCAs = certs
-	tlsConf.Renegotiation = tls.RenegotiateOnceAsClient
+	tlsConf := &tls.Config{
+		Certificates:  []tls.Certificate{cert},
+		RootCAs:       certs,
+		Renegotiation: tls.RenegotiateOnceAsClient,
+	}
+	if len(trust.Pins) > 0 {
+		tlsConf.VerifyConnection = verifyPins(trust.Pins)
+	}
 
 	switch zone {
 	case convert.ZoneBI:
@@ -146,20 +293,32 @@ func New(env Environment, zone l10n.Code, cert *xmldsig.Certificate) (Connection
 	return nil, fmt.Errorf("zone %s not supported", zone)
 }
 
-func rootCAPool() (*x509.CertPool, error) {
-	certs := x509.NewCertPool()
-	files, err := ca.Content.ReadDir(".")
-	if err != nil {
-		return nil, err
+// tbaiError provides the standard error that matches the result code returned
+// by the Gipuzkoa and Araba gateways.
+func tbaiError(code string) *Error {
+	switch code {
+	case tbaiRespCodeNotFound:
+		return ErrNotFound
+	case tbaiRespCodeCancelled:
+		return ErrAlreadyCancelled
 	}
-	for _, f := range files {
-		data, err := ca.Content.ReadFile(f.Name())
-		if err != nil {
-			return nil, err
+	return ErrValidation
+}
+
+// verifyPins ensures that the chain presented by the server, already
+// verified against the roots, contains one of the pinned public keys, so
+// that renewed certificates with the same key are still accepted.
+func verifyPins(pins []*x509.Certificate) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, c := range cs.PeerCertificates {
+			for _, p := range pins {
+				if bytes.Equal(c.RawSubjectPublicKeyInfo, p.RawSubjectPublicKeyInfo) {
+					return nil
+				}
+			}
 		}
-		certs.AppendCertsFromPEM(data)
+		return fmt.Errorf("server certificate for %s does not match pinned certificates", cs.ServerName)
 	}
-	return certs, nil
 }
 
 func debug() bool {
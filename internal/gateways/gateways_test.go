@@ -0,0 +1,67 @@
+package gateways
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// respond starts a server that replies to every request with the recorded
+// gateway response.
+func respond(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(test.Path("test", "data", "responses", name))
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(data) // nolint:errcheck
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCancelErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want *Error
+		code string
+	}{
+		{"should report invoices not found", "tbai-cancel-not-found.xml", ErrNotFound, "011"},
+		{"should report invoices already cancelled", "tbai-cancel-already-cancelled.xml", ErrAlreadyCancelled, "012"},
+		{"should report other errors as validation errors", "tbai-cancel-invalid-signature.xml", ErrValidation, "006"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := respond(t, tt.file)
+			ctx := context.Background()
+
+			ss := newGipuzkoa(EnvironmentSandbox, new(tls.Config))
+			ss.client.SetBaseURL(srv.URL)
+			_, err := ss.post(ctx, gipuzkoaCancelPath, []byte("<AnulaTicketBai/>"))
+			assertError(t, err, tt.want, tt.code)
+
+			vi := newAraba(EnvironmentSandbox, new(tls.Config))
+			vi.client.SetBaseURL(srv.URL)
+			_, err = vi.post(ctx, arabaCancelPath, []byte("<AnulaTicketBai/>"))
+			assertError(t, err, tt.want, tt.code)
+		})
+	}
+}
+
+func assertError(t *testing.T, err error, want *Error, code string) {
+	t.Helper()
+	require.ErrorIs(t, err, want)
+	var ge *Error
+	require.True(t, errors.As(err, &ge))
+	assert.Equal(t, code, ge.Code())
+	assert.NotEmpty(t, ge.Message())
+}
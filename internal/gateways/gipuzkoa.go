@@ -73,24 +73,36 @@ func newGipuzkoa(env Environment, tlsConfig *tls.Config) *GipuzkoaConn {
 }
 
 // Post sends the complete TicketBAI document to the Gipuzkoa API.
-func (c *GipuzkoaConn) Post(ctx context.Context, _ *bill.Invoice, doc *convert.TicketBAI) error {
+func (c *GipuzkoaConn) Post(ctx context.Context, _ *bill.Invoice, doc *convert.TicketBAI) (*Receipt, error) {
 	payload, err := doc.Bytes()
 	if err != nil {
-		return fmt.Errorf("generating payload: %w", err)
+		return nil, fmt.Errorf("generating payload: %w", err)
 	}
 	return c.post(ctx, gipuzkoaExecutePath, payload)
 }
 
 // Cancel will send a request to the Gipuzkoa API to cancel a previously issued document.
-func (c *GipuzkoaConn) Cancel(ctx context.Context, _ *bill.Invoice, doc *convert.AnulaTicketBAI) error {
+func (c *GipuzkoaConn) Cancel(ctx context.Context, _ *bill.Invoice, doc *convert.AnulaTicketBAI) (*Receipt, error) {
 	payload, err := doc.Bytes()
 	if err != nil {
-		return fmt.Errorf("generating payload: %w", err)
+		return nil, fmt.Errorf("generating payload: %w", err)
 	}
 	return c.post(ctx, gipuzkoaCancelPath, payload)
 }
 
-func (c *GipuzkoaConn) post(ctx context.Context, path string, payload []byte) error {
+// PostSigned sends the exact XML of the signed TicketBAI document.
+func (c *GipuzkoaConn) PostSigned(ctx context.Context, _ *bill.Invoice, doc *convert.SignedDocument) (*Receipt, error) {
+	payload, _ := doc.Bytes()
+	return c.post(ctx, gipuzkoaExecutePath, payload)
+}
+
+// CancelSigned sends the exact XML of the signed cancellation document.
+func (c *GipuzkoaConn) CancelSigned(ctx context.Context, _ *bill.Invoice, doc *convert.SignedCancelDocument) (*Receipt, error) {
+	payload, _ := doc.Bytes()
+	return c.post(ctx, gipuzkoaCancelPath, payload)
+}
+
+func (c *GipuzkoaConn) post(ctx context.Context, path string, payload []byte) (*Receipt, error) {
 	out := new(GipuzkoaResponse)
 	req := c.client.R().
 		SetContext(ctx).
@@ -102,20 +114,24 @@ func (c *GipuzkoaConn) post(ctx context.Context, path string, payload []byte) er
 
 	res, err := req.Post(path)
 	if err != nil {
-		return ErrConnection.withCause(err)
+		return nil, ErrConnection.withCause(err)
 	}
 	if res.StatusCode() != http.StatusOK {
-		return ErrValidation.withCode(strconv.Itoa(res.StatusCode()))
+		return nil, ErrValidation.withCode(strconv.Itoa(res.StatusCode()))
 	}
 
 	if out.Output.Status != gipuzkoaStatusReceived {
 		err := ErrValidation
 		if len(out.Output.Errors) > 0 {
 			e1 := out.Output.Errors[0]
-			err = err.withMessage(e1.Description).withCode(e1.Code)
+			err = tbaiError(e1.Code).withMessage(e1.Description).withCode(e1.Code)
 		}
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &Receipt{
+		ID:         out.Output.ID,
+		ReceivedAt: out.Output.Data,
+		CSV:        out.Output.CSV,
+	}, nil
 }
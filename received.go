@@ -0,0 +1,104 @@
+package ticketbai
+
+import (
+	"context"
+
+	"github.com/invopop/gobl"
+	"github.com/invopop/gobl.ticketbai/internal/gateways"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/l10n"
+	"github.com/invopop/gobl/org"
+)
+
+// ReceivedInvoice contains the main details of a received invoice as
+// registered in the gateway.
+type ReceivedInvoice = gateways.ReceivedInvoice
+
+// PostReceived registers the invoice in the envelope, received by its
+// customer on the given date, in the Bizkaia LROE (chapter 2). Companies
+// register it as a received invoice under Modelo 240 and natural persons as an
+// expense under Modelo 140, according to the customer's tax ID.
+func (c *Client) PostReceived(ctx context.Context, env *gobl.Envelope, received cal.Date) (*Receipt, error) {
+	gw, err := c.receivedConnection()
+	if err != nil {
+		return nil, err
+	}
+	inv, err := receivedInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	if received.IsZero() {
+		return nil, ErrValidation.withMessage("missing received date")
+	}
+	if received.Before(inv.IssueDate.Date) {
+		return nil, ErrValidation.withMessage("received date before the issue date")
+	}
+	r, err := gw.PostReceived(ctx, inv, received)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// CancelReceived cancels the record of a received invoice previously
+// registered with PostReceived.
+func (c *Client) CancelReceived(ctx context.Context, env *gobl.Envelope) (*Receipt, error) {
+	gw, err := c.receivedConnection()
+	if err != nil {
+		return nil, err
+	}
+	inv, err := receivedInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gw.CancelReceived(ctx, inv)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// FetchReceived retrieves a page of the records of the invoices received by
+// the party in the given year.
+func (c *Client) FetchReceived(ctx context.Context, party *org.Party, year string, page int) ([]*ReceivedInvoice, error) {
+	gw, err := c.receivedConnection()
+	if err != nil {
+		return nil, err
+	}
+	if party == nil || !hasSpanishTaxID(party) {
+		return nil, ErrValidation.withMessage("party must have a spanish tax ID")
+	}
+	out, err := gw.FetchReceived(ctx, party, year, page)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return out, nil
+}
+
+// receivedConnection provides the client's gateway connection if it supports
+// received invoices.
+func (c *Client) receivedConnection() (gateways.ReceivedConnection, error) {
+	gw, ok := c.gw.(gateways.ReceivedConnection)
+	if !ok || c.zone != ZoneBI {
+		return nil, ErrValidation.withMessage("received invoices are only supported in Bizkaia")
+	}
+	return gw, nil
+}
+
+// receivedInvoice extracts the invoice from the envelope, ensuring the
+// customer, who registers it, can be identified.
+func receivedInvoice(env *gobl.Envelope) (*bill.Invoice, error) {
+	inv, ok := env.Extract().(*bill.Invoice)
+	if !ok {
+		return nil, ErrValidation.withMessage("only invoices are supported")
+	}
+	if inv.Customer == nil || !hasSpanishTaxID(inv.Customer) {
+		return nil, ErrValidation.withMessage("customer must have a spanish tax ID")
+	}
+	return inv, nil
+}
+
+func hasSpanishTaxID(party *org.Party) bool {
+	return party.TaxID != nil && party.TaxID.Country == l10n.ES.Tax() && party.TaxID.Code != ""
+}
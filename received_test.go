@@ -0,0 +1,79 @@
+package ticketbai_test
+
+import (
+	"context"
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostReceived(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("registers and fetches received invoices", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("invoice-bi-pf-modelo140.json")
+
+		_, err := tc.PostReceived(ctx, env, cal.MakeDate(2024, 3, 20))
+		require.NoError(t, err)
+
+		inv := env.Extract().(*bill.Invoice)
+		out, err := tc.FetchReceived(ctx, inv.Customer, "2024", 1)
+		require.NoError(t, err)
+		require.Len(t, out, 1)
+		assert.Equal(t, "FREEL", out[0].Series)
+		assert.Equal(t, "001", out[0].Code)
+	})
+
+	t.Run("cancels received invoices", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("sample-invoice.json")
+
+		_, err := tc.CancelReceived(ctx, env)
+		require.NoError(t, err)
+	})
+
+	t.Run("requires a spanish customer", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("invoice-es-nl-b2c.json")
+
+		_, err := tc.PostReceived(ctx, env, cal.MakeDate(2024, 3, 20))
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "customer must have a spanish tax ID")
+	})
+
+	t.Run("requires a received date", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("invoice-bi-pf-modelo140.json")
+
+		_, err := tc.PostReceived(ctx, env, cal.Date{})
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "missing received date")
+	})
+
+	t.Run("refuses received dates before the issue date", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("invoice-bi-pf-modelo140.json")
+
+		_, err := tc.PostReceived(ctx, env, cal.MakeDate(2024, 3, 14))
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "received date before the issue date")
+
+		_, err = tc.PostReceived(ctx, env, cal.MakeDate(2024, 3, 15))
+		require.NoError(t, err)
+	})
+
+	t.Run("only in Bizkaia", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneSS)
+		env := test.LoadEnvelope("sample-invoice.json")
+
+		_, err := tc.PostReceived(ctx, env, cal.MakeDate(2022, 2, 1))
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "only supported in Bizkaia")
+	})
+}
@@ -0,0 +1,423 @@
+// Package register produces the register of issued invoices (libro registro
+// de facturas expedidas) from signed TicketBAI documents, with one row per
+// invoice and VAT breakdown line, so that it can be handed over to the
+// accountants as CSV or JSON for the periodic filings.
+package register
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/num"
+)
+
+// Operation types used to distinguish the breakdowns of invoices issued to
+// foreign customers.
+const (
+	OperationServices = "services" // PrestacionServicios
+	OperationGoods    = "goods"    // Entrega
+)
+
+// Row describes a single line of the register: the details of the invoice
+// along with one of the lines of its VAT breakdown.
+type Row struct {
+	NIF    string   `json:"nif"`
+	Series string   `json:"series,omitempty"`
+	Number string   `json:"number"`
+	Date   cal.Date `json:"date"`
+	// OperationDate is only set when it differs from the issue date.
+	OperationDate   *cal.Date `json:"operation_date,omitempty"`
+	Simplified      bool      `json:"simplified,omitempty"`
+	Corrective      string    `json:"corrective,omitempty"`      // R1 to R5
+	CorrectiveType  string    `json:"corrective_type,omitempty"` // S or I
+	CustomerID      string    `json:"customer_id,omitempty"`
+	CustomerName    string    `json:"customer_name,omitempty"`
+	CustomerCountry string    `json:"customer_country,omitempty"`
+	// Regimes contains the VAT regime keys of the invoice, such as 01.
+	Regimes []string `json:"regimes"`
+	// Operation is empty unless the breakdown is split between goods and
+	// services.
+	Operation string `json:"operation,omitempty"`
+	// Type is the TipoNoExenta (S1, S2) of subject amounts, the exemption
+	// cause (E1 to E6) of exempt amounts, or the cause (OT, RL, ...) of
+	// amounts not subject to VAT.
+	Type            string      `json:"type"`
+	Base            num.Amount  `json:"base"`
+	Rate            string      `json:"rate,omitempty"`
+	Amount          num.Amount  `json:"amount"`
+	SurchargeRate   string      `json:"surcharge_rate,omitempty"`
+	SurchargeAmount *num.Amount `json:"surcharge_amount,omitempty"`
+	// Total is the total of the whole invoice, repeated in each of its rows.
+	Total num.Amount `json:"total"`
+	// ReplacesSimplified is set on complete invoices issued to replace
+	// simplified invoices (FacturaEmitidaSustitucionSimplificada), whose
+	// amounts were already accounted for by the simplified invoices.
+	ReplacesSimplified bool `json:"replaces_simplified,omitempty"`
+	// ReplacedBase, ReplacedAmount and ReplacedSurcharge contain the totals of
+	// the invoices replaced by a corrective invoice by substitution
+	// (ImporteRectificacionSustitutiva), repeated in each of its rows. They
+	// are not known by rate.
+	ReplacedBase      *num.Amount `json:"replaced_base,omitempty"`
+	ReplacedAmount    *num.Amount `json:"replaced_amount,omitempty"`
+	ReplacedSurcharge *num.Amount `json:"replaced_surcharge,omitempty"`
+}
+
+// Register contains the rows of the invoices added, in the order they were
+// added.
+type Register struct {
+	rows []*Row
+}
+
+// columns lists the CSV header, in the same order as the values provided by
+// Row.record.
+var columns = []string{
+	"nif",
+	"series",
+	"number",
+	"date",
+	"operation_date",
+	"simplified",
+	"corrective",
+	"corrective_type",
+	"customer_id",
+	"customer_name",
+	"customer_country",
+	"regimes",
+	"operation",
+	"type",
+	"base",
+	"rate",
+	"amount",
+	"surcharge_rate",
+	"surcharge_amount",
+	"total",
+	"replaces_simplified",
+	"replaced_base",
+	"replaced_amount",
+	"replaced_surcharge",
+}
+
+// New creates an empty register.
+func New() *Register {
+	return new(Register)
+}
+
+// Add includes the rows of the signed document in the register. Documents
+// should be added in the order they were issued, along with their
+// cancellations, so that invoices issued again after being cancelled are
+// kept.
+func (r *Register) Add(doc *convert.TicketBAI) error {
+	if doc == nil || doc.Signature == nil {
+		return errors.New("document has not been signed")
+	}
+	if doc.Factura == nil || doc.Factura.CabeceraFactura == nil || doc.Factura.DatosFactura == nil {
+		return errors.New("document has no invoice")
+	}
+	inv, err := newRow(doc)
+	if err != nil {
+		return err
+	}
+	if doc.Factura.TipoDesglose == nil {
+		return fmt.Errorf("invoice %s: missing VAT breakdown", invoiceName(inv))
+	}
+	var rows []*Row
+	td := doc.Factura.TipoDesglose
+	if td.DesgloseFactura != nil {
+		rows, err = breakdownRows(rows, inv, "", td.DesgloseFactura)
+	}
+	if dto := td.DesgloseTipoOperacion; dto != nil {
+		if err == nil && dto.PrestacionServicios != nil {
+			rows, err = breakdownRows(rows, inv, OperationServices, dto.PrestacionServicios)
+		}
+		if err == nil && dto.Entrega != nil {
+			rows, err = breakdownRows(rows, inv, OperationGoods, dto.Entrega)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invoice %s: %w", invoiceName(inv), err)
+	}
+	r.rows = append(r.rows, rows...)
+	return nil
+}
+
+// Cancel removes the rows of the invoice cancelled by the signed document
+// from the register. An error is returned if the invoice was not added
+// before.
+func (r *Register) Cancel(doc *convert.AnulaTicketBAI) error {
+	if doc == nil || doc.Signature == nil {
+		return errors.New("document has not been signed")
+	}
+	if doc.IDFactura == nil || doc.IDFactura.Emisor == nil || doc.IDFactura.CabeceraFactura == nil {
+		return errors.New("document has no invoice")
+	}
+	h := doc.IDFactura.CabeceraFactura
+	date, err := convert.ParseDate(h.FechaExpedicionFactura)
+	if err != nil {
+		return err
+	}
+	key := &Row{
+		NIF:    doc.IDFactura.Emisor.NIF,
+		Series: h.SerieFactura,
+		Number: h.NumFactura,
+		Date:   date,
+	}
+	// A new slice is used so that the rows previously provided are kept
+	rows := make([]*Row, 0, len(r.rows))
+	found := false
+	for _, row := range r.rows {
+		if sameInvoice(row, key) {
+			found = true
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if !found {
+		return fmt.Errorf("invoice %s: not in register", invoiceName(key))
+	}
+	r.rows = rows
+	return nil
+}
+
+// Rows provides a copy of the list of rows of the register.
+func (r *Register) Rows() []*Row {
+	return append([]*Row(nil), r.rows...)
+}
+
+// WriteCSV writes the register as CSV, including a header with the names of
+// the columns. Regime keys are separated by spaces.
+func (r *Register) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range r.rows {
+		if err := cw.Write(row.record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the register as an indented JSON array of rows.
+func (r *Register) WriteJSON(w io.Writer) error {
+	rows := r.rows
+	if rows == nil {
+		rows = []*Row{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func (row *Row) record() []string {
+	od := ""
+	if row.OperationDate != nil {
+		od = row.OperationDate.String()
+	}
+	simplified := ""
+	if row.Simplified {
+		simplified = "S"
+	}
+	rs := ""
+	if row.ReplacesSimplified {
+		rs = "S"
+	}
+	return []string{
+		row.NIF,
+		row.Series,
+		row.Number,
+		row.Date.String(),
+		od,
+		simplified,
+		row.Corrective,
+		row.CorrectiveType,
+		row.CustomerID,
+		row.CustomerName,
+		row.CustomerCountry,
+		strings.Join(row.Regimes, " "),
+		row.Operation,
+		row.Type,
+		row.Base.String(),
+		row.Rate,
+		row.Amount.String(),
+		row.SurchargeRate,
+		optionalAmount(row.SurchargeAmount),
+		row.Total.String(),
+		rs,
+		optionalAmount(row.ReplacedBase),
+		optionalAmount(row.ReplacedAmount),
+		optionalAmount(row.ReplacedSurcharge),
+	}
+}
+
+func optionalAmount(a *num.Amount) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+// newRow prepares the details of the invoice shared by all of its rows.
+func newRow(doc *convert.TicketBAI) (*Row, error) {
+	h := doc.Factura.CabeceraFactura
+	d := doc.Factura.DatosFactura
+	row := &Row{
+		Series:             h.SerieFactura,
+		Number:             h.NumFactura,
+		Simplified:         h.FacturaSimplificada == "S",
+		ReplacesSimplified: h.FacturaEmitidaSustitucionSimplificada == "S",
+		Regimes:            []string{},
+	}
+	if doc.Sujetos != nil && doc.Sujetos.Emisor != nil {
+		row.NIF = doc.Sujetos.Emisor.NIF
+	}
+	date, err := convert.ParseDate(h.FechaExpedicionFactura)
+	if err != nil {
+		return nil, err
+	}
+	row.Date = date
+	if d.FechaOperacion != "" && d.FechaOperacion != h.FechaExpedicionFactura {
+		od, err := convert.ParseDate(d.FechaOperacion)
+		if err != nil {
+			return nil, err
+		}
+		row.OperationDate = &od
+	}
+	if fr := h.FacturaRectificativa; fr != nil {
+		row.Corrective = fr.Codigo
+		row.CorrectiveType = fr.Tipo
+		if irs := fr.ImporteRectificacionSustitutiva; irs != nil {
+			if err := replacedAmounts(row, irs); err != nil {
+				return nil, fmt.Errorf("invoice %s: %w", invoiceName(row), err)
+			}
+		}
+	}
+	if doc.Sujetos != nil && doc.Sujetos.Destinatarios != nil && len(doc.Sujetos.Destinatarios.IDDestinatario) > 0 {
+		// Co-recipients are not included, the first one is the customer
+		c := doc.Sujetos.Destinatarios.IDDestinatario[0]
+		row.CustomerName = c.ApellidosNombreRazonSocial
+		if c.IDOtro != nil {
+			row.CustomerID = c.IDOtro.ID
+			row.CustomerCountry = c.IDOtro.CodigoPais
+		} else {
+			row.CustomerID = c.NIF
+		}
+	}
+	if d.Claves != nil {
+		for _, k := range d.Claves.IDClave {
+			row.Regimes = append(row.Regimes, k.ClaveRegimenIvaOpTrascendencia)
+		}
+	}
+	if row.Total, err = parseAmount(d.ImporteTotalFactura); err != nil {
+		return nil, fmt.Errorf("invoice %s: total: %w", invoiceName(row), err)
+	}
+	return row, nil
+}
+
+// replacedAmounts sets the totals of the invoices replaced by a corrective
+// invoice by substitution.
+func replacedAmounts(row *Row, irs *convert.ImporteRectificacionSustitutiva) error {
+	base, err := parseAmount(irs.BaseRectificada)
+	if err != nil {
+		return fmt.Errorf("replaced base: %w", err)
+	}
+	amount, err := parseAmount(irs.CuotaRectificada)
+	if err != nil {
+		return fmt.Errorf("replaced amount: %w", err)
+	}
+	row.ReplacedBase = &base
+	row.ReplacedAmount = &amount
+	if irs.CuotaRecargoRectificada != "" {
+		sa, err := parseAmount(irs.CuotaRecargoRectificada)
+		if err != nil {
+			return fmt.Errorf("replaced surcharge: %w", err)
+		}
+		row.ReplacedSurcharge = &sa
+	}
+	return nil
+}
+
+// breakdownRows appends a copy of the invoice row for each line of the
+// breakdown: subject amounts first, then exempt and non-subject amounts.
+func breakdownRows(rows []*Row, inv *Row, op string, df *convert.DesgloseFactura) ([]*Row, error) {
+	add := func(typ string, base num.Amount) *Row {
+		row := *inv
+		row.Operation = op
+		row.Type = typ
+		row.Base = base.Rescale(2)
+		row.Amount = num.MakeAmount(0, 2)
+		rows = append(rows, &row)
+		return &row
+	}
+	if s := df.Sujeta; s != nil {
+		if s.NoExenta != nil {
+			for _, dne := range s.NoExenta.DetalleNoExenta {
+				if dne.DesgloseIVA == nil {
+					continue
+				}
+				for _, di := range dne.DesgloseIVA.DetalleIVA {
+					base, err := parseAmount(di.BaseImponible)
+					if err != nil {
+						return nil, fmt.Errorf("base: %w", err)
+					}
+					amount, err := parseAmount(di.CuotaImpuesto)
+					if err != nil {
+						return nil, fmt.Errorf("amount: %w", err)
+					}
+					row := add(dne.TipoNoExenta, base)
+					row.Rate = di.TipoImpositivo
+					row.Amount = amount
+					if di.TipoRecargoEquivalencia != "" {
+						sa, err := parseAmount(di.CuotaRecargoEquivalencia)
+						if err != nil {
+							return nil, fmt.Errorf("surcharge amount: %w", err)
+						}
+						row.SurchargeRate = di.TipoRecargoEquivalencia
+						row.SurchargeAmount = &sa
+					}
+				}
+			}
+		}
+		if s.Exenta != nil {
+			for _, de := range s.Exenta.DetalleExenta {
+				base, err := parseAmount(de.BaseImponible)
+				if err != nil {
+					return nil, fmt.Errorf("exempt base: %w", err)
+				}
+				add(de.CausaExencion, base)
+			}
+		}
+	}
+	if ns := df.NoSujeta; ns != nil {
+		for _, dns := range ns.DetalleNoSujeta {
+			add(dns.Causa, dns.Importe)
+		}
+	}
+	return rows, nil
+}
+
+func sameInvoice(a, b *Row) bool {
+	return a.NIF == b.NIF &&
+		a.Series == b.Series &&
+		a.Number == b.Number &&
+		a.Date == b.Date
+}
+
+func invoiceName(row *Row) string {
+	return row.Series + row.Number
+}
+
+func parseAmount(val string) (num.Amount, error) {
+	a, err := num.AmountFromString(val)
+	if err != nil {
+		return num.Amount{}, err
+	}
+	return a.Rescale(2), nil
+}
@@ -0,0 +1,181 @@
+package register_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/register"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedCancel(t *testing.T, doc *convert.TicketBAI) *convert.AnulaTicketBAI {
+	t.Helper()
+	cd, err := convert.NewAnulaTicketBAIFromDocument(doc)
+	require.NoError(t, err)
+	require.NoError(t, cd.Sign("TEST", test.LoadCertificate(), convert.IssuerRoleSupplier, convert.ZoneBI))
+	return cd
+}
+
+func newRegister(t *testing.T) *register.Register {
+	t.Helper()
+	r := register.New()
+	cancelled := test.LoadSignedDocument("invoice-es-es-b2c.xml").TicketBAI
+	require.NoError(t, r.Add(test.LoadSignedDocument("sample-invoice.xml").TicketBAI))
+	require.NoError(t, r.Add(cancelled))
+	require.NoError(t, r.Add(test.LoadSignedDocument("sample-invoice-export.xml").TicketBAI))
+	require.NoError(t, r.Add(test.LoadSignedDocument("credit-note-es-es-tbai.xml").TicketBAI))
+	require.NoError(t, r.Cancel(signedCancel(t, cancelled)))
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	r := newRegister(t)
+	rows := r.Rows()
+	require.Len(t, rows, 6)
+
+	t.Run("should include the VAT details", func(t *testing.T) {
+		row := rows[0]
+		assert.Equal(t, "S7836107H", row.NIF)
+		assert.Equal(t, "TEST", row.Series)
+		assert.Equal(t, "001", row.Number)
+		assert.Equal(t, "2022-02-01", row.Date.String())
+		assert.Equal(t, "54387763P", row.CustomerID)
+		assert.Equal(t, []string{"01"}, row.Regimes)
+		assert.Empty(t, row.Operation)
+		assert.Equal(t, "S1", row.Type)
+		assert.Equal(t, "900.00", row.Base.String())
+		assert.Equal(t, "21.00", row.Rate)
+		assert.Equal(t, "189.00", row.Amount.String())
+		assert.Equal(t, "5.20", row.SurchargeRate)
+		require.NotNil(t, row.SurchargeAmount)
+		assert.Equal(t, "46.80", row.SurchargeAmount.String())
+		assert.Equal(t, "1089.00", row.Total.String())
+	})
+
+	t.Run("should split exempt amounts by operation", func(t *testing.T) {
+		assert.Equal(t, register.OperationServices, rows[1].Operation)
+		assert.Equal(t, "S1", rows[1].Type)
+		assert.Equal(t, "MX", rows[1].CustomerCountry)
+		assert.Equal(t, "EKU9003173C9", rows[1].CustomerID)
+		assert.Equal(t, register.OperationGoods, rows[2].Operation)
+		assert.Equal(t, "E1", rows[2].Type)
+		assert.Equal(t, "600.00", rows[2].Base.String())
+		assert.Equal(t, "0.00", rows[2].Amount.String())
+		assert.Empty(t, rows[2].Rate)
+		assert.Equal(t, "E2", rows[3].Type)
+		assert.Equal(t, "100.00", rows[3].Base.String())
+	})
+
+	t.Run("should include credit notes", func(t *testing.T) {
+		assert.Equal(t, "FR", rows[4].Series)
+		assert.Equal(t, "R2", rows[4].Corrective)
+		assert.Equal(t, "I", rows[4].CorrectiveType)
+		assert.Equal(t, "-1620.00", rows[4].Base.String())
+		assert.Equal(t, "-340.20", rows[4].Amount.String())
+		assert.Equal(t, "-5.00", rows[5].Base.String())
+		assert.Equal(t, "-1965.20", rows[5].Total.String())
+	})
+
+	t.Run("should include the amounts replaced by substitution", func(t *testing.T) {
+		doc := test.LoadSignedDocument("credit-note-es-es-tbai.xml").TicketBAI
+		fr := doc.Factura.CabeceraFactura.FacturaRectificativa
+		fr.Tipo = convert.CorrectiveTypeSubstitution
+		fr.ImporteRectificacionSustitutiva = &convert.ImporteRectificacionSustitutiva{
+			BaseRectificada:  "2000.00",
+			CuotaRectificada: "420.00",
+		}
+		r := register.New()
+		require.NoError(t, r.Add(doc))
+		rows := r.Rows()
+		require.Len(t, rows, 2)
+		for _, row := range rows {
+			assert.Equal(t, "S", row.CorrectiveType)
+			require.NotNil(t, row.ReplacedBase)
+			assert.Equal(t, "2000.00", row.ReplacedBase.String())
+			require.NotNil(t, row.ReplacedAmount)
+			assert.Equal(t, "420.00", row.ReplacedAmount.String())
+			assert.Nil(t, row.ReplacedSurcharge)
+		}
+
+		fr.ImporteRectificacionSustitutiva.BaseRectificada = "x"
+		assert.ErrorContains(t, register.New().Add(doc), "replaced base")
+	})
+
+	t.Run("should flag invoices replacing simplified invoices", func(t *testing.T) {
+		doc := test.LoadSignedDocument("sample-invoice.xml").TicketBAI
+		doc.Factura.CabeceraFactura.FacturaEmitidaSustitucionSimplificada = "S"
+		r := register.New()
+		require.NoError(t, r.Add(doc))
+		assert.True(t, r.Rows()[0].ReplacesSimplified)
+		assert.False(t, rows[0].ReplacesSimplified)
+	})
+
+	t.Run("should keep the rows provided before a cancellation", func(t *testing.T) {
+		r := register.New()
+		cancelled := test.LoadSignedDocument("invoice-es-es-b2c.xml").TicketBAI
+		require.NoError(t, r.Add(cancelled))
+		require.NoError(t, r.Add(test.LoadSignedDocument("sample-invoice.xml").TicketBAI))
+		before := r.Rows()
+		require.NoError(t, r.Cancel(signedCancel(t, cancelled)))
+		assert.Equal(t, "SIMPL", before[0].Series)
+		assert.Equal(t, "TEST", before[len(before)-1].Series)
+		assert.Len(t, r.Rows(), 1)
+	})
+
+	t.Run("should remove cancelled invoices", func(t *testing.T) {
+		for _, row := range rows {
+			assert.NotEqual(t, "SIMPL", row.Series)
+		}
+		err := r.Cancel(signedCancel(t, test.LoadSignedDocument("invoice-es-es-b2c.xml").TicketBAI))
+		assert.ErrorContains(t, err, "invoice SIMPL0002: not in register")
+	})
+}
+
+func TestRegisterErrors(t *testing.T) {
+	r := register.New()
+	doc := test.LoadSignedDocument("sample-invoice.xml").TicketBAI
+	doc.Signature = nil
+	assert.ErrorContains(t, r.Add(doc), "document has not been signed")
+	assert.ErrorContains(t, r.Add(nil), "document has not been signed")
+	assert.Empty(t, r.Rows())
+}
+
+func TestRegisterCSV(t *testing.T) {
+	r := newRegister(t)
+	buf := new(bytes.Buffer)
+	require.NoError(t, r.WriteCSV(buf))
+
+	records, err := csv.NewReader(buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 7)
+	assert.Equal(t, "nif", records[0][0])
+	assert.Equal(t, []string{
+		"S7836107H", "TEST", "001", "2022-02-01", "", "",
+		"", "", "54387763P", "Sample Customer", "", "01",
+		"", "S1", "900.00", "21.00", "189.00", "5.20", "46.80", "1089.00",
+		"", "", "", "",
+	}, records[1])
+}
+
+func TestRegisterJSON(t *testing.T) {
+	r := newRegister(t)
+	buf := new(bytes.Buffer)
+	require.NoError(t, r.WriteJSON(buf))
+
+	var rows []map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rows))
+	require.Len(t, rows, 6)
+	assert.Equal(t, "900.00", rows[0]["base"])
+	assert.Equal(t, "46.80", rows[0]["surcharge_amount"])
+	assert.Equal(t, "E1", rows[2]["type"])
+	assert.NotContains(t, rows[2], "surcharge_amount")
+
+	buf.Reset()
+	require.NoError(t, register.New().WriteJSON(buf))
+	assert.Equal(t, "[]\n", buf.String())
+}
@@ -0,0 +1,49 @@
+package ticketbai_test
+
+import (
+	"context"
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/num"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostWithRenta(t *testing.T) {
+	ctx := context.Background()
+	income := num.MakeAmount(45000, 2)
+	renta := &ticketbai.Renta{
+		Territory:    "01",
+		IncomeAmount: &income,
+		IncomeCause:  "01",
+	}
+
+	t.Run("should post the income details", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("invoice-bi-pf-modelo140.json")
+
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		require.NoError(t, tc.Sign(td, env))
+
+		_, err = tc.Post(ctx, env, td, ticketbai.WithRenta(renta))
+		require.NoError(t, err)
+	})
+
+	t.Run("should refuse the income details outside Bizkaia", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneSS)
+		env := test.LoadEnvelope("invoice-ss.json")
+
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		require.NoError(t, tc.Sign(td, env))
+
+		_, err = tc.Post(ctx, env, td, ticketbai.WithRenta(renta))
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "only supported in Bizkaia")
+	})
+}
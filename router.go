@@ -0,0 +1,177 @@
+package ticketbai
+
+import (
+	"context"
+
+	"github.com/invopop/gobl"
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl/l10n"
+)
+
+// Router holds a Client for each of the zones being served, each with its own
+// gateway connection, licences and certificate, and dispatches envelopes to
+// the one matching the zone of the invoice.
+type Router struct {
+	clients map[l10n.Code]*Client
+	zones   *ZoneResolver
+}
+
+// NewRouter creates a new Router from the provided clients. Each client must
+// have been created for a different zone.
+func NewRouter(clients ...*Client) (*Router, error) {
+	r := &Router{
+		clients: make(map[l10n.Code]*Client),
+	}
+	for _, c := range clients {
+		if c == nil {
+			continue
+		}
+		if _, ok := r.clients[c.zone]; ok {
+			return nil, ErrValidation.withMessage("duplicate client for zone '%s'", c.zone)
+		}
+		r.clients[c.zone] = c
+	}
+	if len(r.clients) == 0 {
+		return nil, ErrValidation.withMessage("at least one client is required")
+	}
+	return r, nil
+}
+
+// SetZoneResolver defines the resolver used to pick the client for invoices
+// that do not include the es-tbai-region extension. The same resolver should
+// usually be provided to each of the clients with WithZoneResolver.
+func (r *Router) SetZoneResolver(zr *ZoneResolver) {
+	r.zones = zr
+}
+
+// Zones returns the list of zones served by the router.
+func (r *Router) Zones() []l10n.Code {
+	zones := make([]l10n.Code, 0, len(r.clients))
+	for _, z := range []l10n.Code{ZoneBI, ZoneSS, ZoneVI} {
+		if _, ok := r.clients[z]; ok {
+			zones = append(zones, z)
+		}
+	}
+	return zones
+}
+
+// ClientFor returns the client to use for the invoice in the envelope.
+func (r *Router) ClientFor(env *gobl.Envelope) (*Client, error) {
+	zone, _ := r.zones.ResolveEnvelope(env)
+	if zone == "" {
+		return nil, ErrValidation.withMessage("invalid zone")
+	}
+	c, ok := r.clients[zone]
+	if !ok {
+		return nil, ErrValidation.withMessage("no client for zone '%s'", zone)
+	}
+	return c, nil
+}
+
+// Convert creates a new TicketBAI document from the provided GOBL Envelope
+// using the client of the invoice's zone.
+func (r *Router) Convert(env *gobl.Envelope, opts ...convert.Option) (*convert.TicketBAI, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.Convert(env, opts...)
+}
+
+// Reissue creates a new TicketBAI document for a previously cancelled invoice
+// using the client of the invoice's zone.
+func (r *Router) Reissue(env *gobl.Envelope, opts ...convert.Option) (*convert.TicketBAI, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.Reissue(env, opts...)
+}
+
+// Fingerprint generates the fingerprint for the TicketBAI document using the
+// software details of the invoice's zone.
+func (r *Router) Fingerprint(env *gobl.Envelope, d *convert.TicketBAI, prev *convert.ChainData) error {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return err
+	}
+	return c.Fingerprint(d, prev)
+}
+
+// Sign signs the TicketBAI document with the certificate and policy of the
+// invoice's zone.
+func (r *Router) Sign(d *convert.TicketBAI, env *gobl.Envelope) error {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return err
+	}
+	return c.Sign(d, env)
+}
+
+// Post sends the document to the gateway of the invoice's zone.
+func (r *Router) Post(ctx context.Context, env *gobl.Envelope, d *convert.TicketBAI, opts ...PostOption) (*Receipt, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.Post(ctx, env, d, opts...)
+}
+
+// PostSigned sends the exact XML of the signed document to the gateway of the
+// invoice's zone.
+func (r *Router) PostSigned(ctx context.Context, env *gobl.Envelope, d *convert.SignedDocument, opts ...PostOption) (*Receipt, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.PostSigned(ctx, env, d, opts...)
+}
+
+// GenerateCancel creates a new AnulaTicketBAI document for the invoice in the
+// envelope using the client of the invoice's zone.
+func (r *Router) GenerateCancel(env *gobl.Envelope) (*convert.AnulaTicketBAI, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.GenerateCancel(env)
+}
+
+// FingerprintCancel generates the fingerprint for the AnulaTicketBAI document
+// using the software details of the invoice's zone.
+func (r *Router) FingerprintCancel(env *gobl.Envelope, cd *convert.AnulaTicketBAI) error {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return err
+	}
+	return c.FingerprintCancel(cd)
+}
+
+// SignCancel signs the AnulaTicketBAI document with the certificate and policy
+// of the invoice's zone.
+func (r *Router) SignCancel(cd *convert.AnulaTicketBAI, env *gobl.Envelope) error {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return err
+	}
+	return c.SignCancel(cd, env)
+}
+
+// Cancel sends the cancellation document to the gateway of the invoice's zone.
+func (r *Router) Cancel(ctx context.Context, env *gobl.Envelope, cd *convert.AnulaTicketBAI) (*Receipt, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.Cancel(ctx, env, cd)
+}
+
+// CancelSigned sends the exact XML of the signed cancellation document to the
+// gateway of the invoice's zone.
+func (r *Router) CancelSigned(ctx context.Context, env *gobl.Envelope, cd *convert.SignedCancelDocument) (*Receipt, error) {
+	c, err := r.ClientFor(env)
+	if err != nil {
+		return nil, err
+	}
+	return c.CancelSigned(ctx, env, cd)
+}
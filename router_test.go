@@ -0,0 +1,73 @@
+package ticketbai_test
+
+import (
+	"context"
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/l10n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	ctx := context.Background()
+	bi := loadTBAIClient(t, ticketbai.ZoneBI)
+	ss := loadTBAIClient(t, ticketbai.ZoneSS)
+
+	t.Run("should refuse duplicate zones", func(t *testing.T) {
+		_, err := ticketbai.NewRouter(bi, loadTBAIClient(t, ticketbai.ZoneBI))
+		assert.ErrorContains(t, err, "duplicate client for zone 'BI'")
+	})
+
+	t.Run("should dispatch to the invoice zone", func(t *testing.T) {
+		r, err := ticketbai.NewRouter(bi, ss)
+		require.NoError(t, err)
+		assert.Equal(t, []l10n.Code{ticketbai.ZoneBI, ticketbai.ZoneSS}, r.Zones())
+
+		env := test.LoadEnvelope("invoice-ss.json")
+		c, err := r.ClientFor(env)
+		require.NoError(t, err)
+		assert.Equal(t, ticketbai.ZoneSS, c.Zone())
+
+		td, err := r.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, r.Fingerprint(env, td, nil))
+		require.NoError(t, r.Sign(td, env))
+		_, err = r.Post(ctx, env, td)
+		require.NoError(t, err)
+	})
+
+	t.Run("should fail for zones without a client", func(t *testing.T) {
+		r, err := ticketbai.NewRouter(bi, ss)
+		require.NoError(t, err)
+
+		_, err = r.Convert(test.LoadEnvelope("invoice-vi.json"))
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "no client for zone 'VI'")
+	})
+
+	t.Run("should refuse to convert for another zone", func(t *testing.T) {
+		env := test.LoadEnvelope("invoice-ss.json")
+		_, err := bi.Convert(env)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "invoice zone 'SS' does not match client zone 'BI'")
+	})
+
+	t.Run("should refuse to sign or post for another zone", func(t *testing.T) {
+		env := test.LoadEnvelope("invoice-ss.json")
+		td, err := ss.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, ss.Fingerprint(td, nil))
+
+		err = bi.Sign(td, env)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "invoice zone 'SS' does not match client zone 'BI'")
+
+		require.NoError(t, ss.Sign(td, env))
+		_, err = bi.Post(ctx, env, td)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "invoice zone 'SS' does not match client zone 'BI'")
+	})
+}
@@ -0,0 +1,97 @@
+package ticketbai_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostSigned(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should post the persisted document", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("sample-invoice2.json")
+
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		require.NoError(t, tc.Sign(td, env))
+		data, err := td.Bytes()
+		require.NoError(t, err)
+
+		sd, err := ticketbai.ParseSignedDocument(data)
+		require.NoError(t, err)
+		_, err = tc.PostSigned(ctx, env, sd)
+		require.NoError(t, err)
+
+		cd, err := tc.GenerateCancelFromDocument(sd.TicketBAI)
+		require.NoError(t, err)
+		require.NoError(t, tc.FingerprintCancel(cd))
+		require.NoError(t, tc.SignCancel(cd, env))
+		data, err = cd.Bytes()
+		require.NoError(t, err)
+
+		scd, err := ticketbai.ParseSignedCancelDocument(data)
+		require.NoError(t, err)
+		_, err = tc.CancelSigned(ctx, env, scd)
+		require.NoError(t, err)
+		assert.Equal(t, stampValue(env, tbai.StampCode), stampValue(env, ticketbai.StampCancel))
+	})
+
+	t.Run("should check the document matches the invoice", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneBI)
+		env := test.LoadEnvelope("sample-invoice2.json")
+
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		require.NoError(t, tc.Sign(td, env))
+		data, err := td.Bytes()
+		require.NoError(t, err)
+		sd, err := ticketbai.ParseSignedDocument(data)
+		require.NoError(t, err)
+
+		cd, err := tc.GenerateCancelFromDocument(sd.TicketBAI)
+		require.NoError(t, err)
+		require.NoError(t, tc.FingerprintCancel(cd))
+		require.NoError(t, tc.SignCancel(cd, env))
+		data, err = cd.Bytes()
+		require.NoError(t, err)
+		scd, err := ticketbai.ParseSignedCancelDocument(data)
+		require.NoError(t, err)
+
+		other := test.LoadEnvelope("sample-invoice.json")
+		_, err = tc.PostSigned(ctx, other, sd)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "signed document invoice SAMPLE002 does not match the invoice")
+
+		_, err = tc.CancelSigned(ctx, other, scd)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "does not match the invoice")
+		assert.Empty(t, stampValue(other, ticketbai.StampCancel))
+
+		sd.Sujetos.Emisor.NIF = "B12345678"
+		_, err = tc.PostSigned(ctx, env, sd)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+		assert.ErrorContains(t, err, "signed document supplier B12345678 does not match the invoice")
+	})
+
+	t.Run("should check the zone", func(t *testing.T) {
+		tc := loadTBAIClient(t, ticketbai.ZoneSS)
+		env := test.LoadEnvelope("invoice-es-es-b2c.json")
+		data, err := os.ReadFile(test.Path("test", "data", "out", "invoice-es-es-b2c.xml"))
+		require.NoError(t, err)
+
+		sd, err := ticketbai.ParseSignedDocument(data)
+		require.NoError(t, err)
+		_, err = tc.PostSigned(ctx, env, sd)
+		assert.ErrorIs(t, err, ticketbai.ErrValidation)
+	})
+}
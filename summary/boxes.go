@@ -0,0 +1,120 @@
+package summary
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/invopop/gobl/num"
+)
+
+// Box contains the amount of a numbered box of the VAT return.
+type Box struct {
+	Code   string     `json:"code"`
+	Amount num.Amount `json:"amount"`
+}
+
+// rateBox defines the boxes for the base and the amount of a rate.
+type rateBox struct {
+	base   string
+	amount string
+}
+
+// Boxes of the accrued VAT of Modelo 300 in Bizkaia and Gipuzkoa, which
+// follow the numbering of the state Modelo 303, by rate.
+var (
+	modelo300Rates = map[string]rateBox{
+		"0.00":  {base: "150", amount: "152"},
+		"4.00":  {base: "01", amount: "03"},
+		"5.00":  {base: "153", amount: "155"},
+		"10.00": {base: "04", amount: "06"},
+		"21.00": {base: "07", amount: "09"},
+	}
+	modelo300Surcharges = map[string]rateBox{
+		"0.50": {base: "16", amount: "18"},
+		"1.40": {base: "19", amount: "21"},
+		"5.20": {base: "22", amount: "24"},
+	}
+	modelo300Modified          = rateBox{base: "14", amount: "15"}
+	modelo300ModifiedSurcharge = rateBox{base: "25", amount: "26"}
+	modelo300Accrued           = "27"
+)
+
+// Boxes of Modelo 300 with the additional information of the operations
+// without accrued VAT, by exemption or non-subject cause. Other causes, such
+// as E1 or OT, have no box in the quarterly return.
+var modelo300Causes = map[string]string{
+	"E5": "59",  // intra-community deliveries
+	"E2": "60",  // exports
+	"E3": "60",  // operations treated as exports
+	"RL": "120", // not subject by the location rules
+}
+
+// modelo300ReverseCharge is the box of the operations where the customer
+// accounts for the VAT.
+const modelo300ReverseCharge = "122"
+
+// Modelo300 maps the totals to the boxes of the quarterly VAT return of
+// Bizkaia and Gipuzkoa (Modelo 300) that are filled from the issued invoices:
+// the accrued VAT and the additional information. The deductible VAT and the
+// result must be completed from the received invoices. Boxes are sorted by
+// number, and an error is returned for the rates that have no box in the
+// form.
+func (s *Summary) Modelo300() ([]*Box, error) {
+	boxes := make(map[string]num.Amount)
+	add := func(code string, a num.Amount) {
+		if b, ok := boxes[code]; ok {
+			a = b.Add(a)
+		}
+		boxes[code] = a
+	}
+	for _, rt := range s.Rates {
+		rb, ok := modelo300Rates[rt.Rate]
+		if !ok {
+			return nil, fmt.Errorf("rate %s: no box in Modelo 300", rt.Rate)
+		}
+		add(rb.base, rt.Base)
+		add(rb.amount, rt.Amount)
+	}
+	for _, st := range s.Surcharges {
+		rb, ok := modelo300Surcharges[st.Rate]
+		if !ok {
+			return nil, fmt.Errorf("surcharge rate %s: no box in Modelo 300", st.Rate)
+		}
+		add(rb.base, st.Base)
+		add(rb.amount, st.Amount)
+	}
+	if m := s.Modified; m != nil {
+		add(modelo300Modified.base, m.Base)
+		add(modelo300Modified.amount, m.Amount)
+		if !m.Surcharge.IsZero() {
+			add(modelo300ModifiedSurcharge.base, m.SurchargeBase)
+			add(modelo300ModifiedSurcharge.amount, m.Surcharge)
+		}
+	}
+	add(modelo300Accrued, s.Accrued)
+	for _, ct := range s.Exempt {
+		if code, ok := modelo300Causes[ct.Cause]; ok {
+			add(code, ct.Base)
+		}
+	}
+	for _, ct := range s.NotSubject {
+		if code, ok := modelo300Causes[ct.Cause]; ok {
+			add(code, ct.Base)
+		}
+	}
+	if !s.ReverseCharge.IsZero() {
+		add(modelo300ReverseCharge, s.ReverseCharge)
+	}
+
+	out := make([]*Box, 0, len(boxes))
+	for code, a := range boxes {
+		out = append(out, &Box{Code: code, Amount: a})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].Code)
+		b, _ := strconv.Atoi(out[j].Code)
+		return a < b
+	})
+	return out, nil
+}
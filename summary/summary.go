@@ -0,0 +1,237 @@
+// Package summary adds up the VAT breakdowns of the issued invoices of a
+// period, such as a quarter, to prepare the periodic VAT return of the foral
+// treasuries. Totals are built from the rows of the register package, so
+// cancelled invoices are already excluded and credit notes subtract their
+// amounts.
+//
+// Totals are arranged in the sections of the VAT return, and can be mapped to
+// the numbered boxes of Modelo 300 with Modelo300.
+package summary
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/invopop/gobl.ticketbai/register"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/num"
+)
+
+// Period defines the dates covered by the summary, both inclusive.
+type Period struct {
+	From cal.Date `json:"from"`
+	To   cal.Date `json:"to"`
+}
+
+// Summary contains the totals of the period, arranged in the same sections
+// as the VAT return.
+type Summary struct {
+	Period Period `json:"period"`
+	// Rates contains the bases and accrued VAT of subject operations (S1) by
+	// rate, sorted from the lowest rate.
+	Rates []*RateTotal `json:"rates"`
+	// Surcharges contains the bases and equivalence surcharge by surcharge
+	// rate, sorted from the lowest rate.
+	Surcharges []*RateTotal `json:"surcharges,omitempty"`
+	// ReverseCharge is the base of subject operations where the customer
+	// accounts for the VAT (S2).
+	ReverseCharge num.Amount `json:"reverse_charge"`
+	// Exempt contains the bases of exempt operations by cause, such as E2
+	// for exports or E5 for intra-community deliveries.
+	Exempt []*CauseTotal `json:"exempt,omitempty"`
+	// NotSubject contains the bases of operations not subject to VAT by
+	// cause, such as OT or RL for the location rules.
+	NotSubject []*CauseTotal `json:"not_subject,omitempty"`
+	// Modified contains the totals of the invoices replaced by corrective
+	// invoices by substitution, as negative amounts, while the amounts that
+	// replace them are included by rate.
+	Modified *ModifiedTotal `json:"modified,omitempty"`
+	// Accrued is the sum of the VAT and equivalence surcharge of all rates,
+	// less the amounts modified.
+	Accrued num.Amount `json:"accrued"`
+	// Warnings lists the invoices of the period that were not included in
+	// the totals and must be accounted for separately.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// RateTotal contains the totals of a single rate.
+type RateTotal struct {
+	Rate   string     `json:"rate"`
+	Base   num.Amount `json:"base"`
+	Amount num.Amount `json:"amount"`
+}
+
+// ModifiedTotal contains the changes to the bases and accrued VAT of
+// previous invoices, which are not known by rate.
+type ModifiedTotal struct {
+	Base   num.Amount `json:"base"`
+	Amount num.Amount `json:"amount"`
+	// SurchargeBase and Surcharge are only set for the invoices replaced
+	// with equivalence surcharge.
+	SurchargeBase num.Amount `json:"surcharge_base"`
+	Surcharge     num.Amount `json:"surcharge"`
+}
+
+// CauseTotal contains the base of the operations with a single exemption or
+// non-subject cause.
+type CauseTotal struct {
+	Cause string     `json:"cause"`
+	Base  num.Amount `json:"base"`
+}
+
+// Quarter provides the period of the given quarter, from 1 to 4.
+func Quarter(year, quarter int) Period {
+	from := time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 3, -1)
+	return Period{From: cal.DateOf(from), To: cal.DateOf(to)}
+}
+
+// Contains returns true if the date is within the period.
+func (p Period) Contains(date cal.Date) bool {
+	d := date.String()
+	return d >= p.From.String() && d <= p.To.String()
+}
+
+// New adds up the rows of the register accrued in the period, which are the
+// ones with an operation date, or issue date if none, within the period.
+//
+// Corrective invoices by substitution add their amounts by rate and subtract
+// the totals of the invoices they replace as modifications. Those without the
+// totals replaced are skipped with a warning. Complete invoices issued to
+// replace simplified invoices are not included, as their amounts were already
+// accrued by the simplified invoices.
+func New(rows []*register.Row, p Period) (*Summary, error) {
+	s := &Summary{
+		Period:        p,
+		Rates:         []*RateTotal{},
+		ReverseCharge: num.MakeAmount(0, 2),
+		Accrued:       num.MakeAmount(0, 2),
+	}
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		date := row.Date
+		if row.OperationDate != nil {
+			date = *row.OperationDate
+		}
+		if !p.Contains(date) || row.ReplacesSimplified {
+			continue
+		}
+		if row.CorrectiveType == "S" {
+			key := row.NIF + "\x00" + row.Series + "\x00" + row.Number
+			first := !seen[key]
+			seen[key] = true
+			if row.ReplacedBase == nil || row.ReplacedAmount == nil {
+				if first {
+					s.Warnings = append(s.Warnings, fmt.Sprintf("invoice %s%s: corrective invoice by substitution without the amounts replaced not included", row.Series, row.Number))
+				}
+				continue
+			}
+			if first {
+				s.modify(row)
+			}
+		}
+		if err := s.add(row); err != nil {
+			return nil, fmt.Errorf("invoice %s%s: %w", row.Series, row.Number, err)
+		}
+	}
+	sortRates(s.Rates)
+	sortRates(s.Surcharges)
+	sortCauses(s.Exempt)
+	sortCauses(s.NotSubject)
+	return s, nil
+}
+
+func (s *Summary) add(row *register.Row) error {
+	switch {
+	case row.Type == "S1":
+		rt := rateTotal(&s.Rates, row.Rate)
+		rt.Base = rt.Base.Add(row.Base)
+		rt.Amount = rt.Amount.Add(row.Amount)
+		s.Accrued = s.Accrued.Add(row.Amount)
+		if row.SurchargeAmount != nil {
+			st := rateTotal(&s.Surcharges, row.SurchargeRate)
+			st.Base = st.Base.Add(row.Base)
+			st.Amount = st.Amount.Add(*row.SurchargeAmount)
+			s.Accrued = s.Accrued.Add(*row.SurchargeAmount)
+		}
+	case row.Type == "S2":
+		s.ReverseCharge = s.ReverseCharge.Add(row.Base)
+	case row.Rate == "" && len(row.Type) == 2 && row.Type[0] == 'E':
+		ct := causeTotal(&s.Exempt, row.Type)
+		ct.Base = ct.Base.Add(row.Base)
+	case row.Rate == "":
+		ct := causeTotal(&s.NotSubject, row.Type)
+		ct.Base = ct.Base.Add(row.Base)
+	default:
+		return fmt.Errorf("unexpected type %s", row.Type)
+	}
+	return nil
+}
+
+// modify subtracts the totals of the invoices replaced by a corrective
+// invoice by substitution, once per invoice.
+func (s *Summary) modify(row *register.Row) {
+	if s.Modified == nil {
+		s.Modified = &ModifiedTotal{
+			Base:          num.MakeAmount(0, 2),
+			Amount:        num.MakeAmount(0, 2),
+			SurchargeBase: num.MakeAmount(0, 2),
+			Surcharge:     num.MakeAmount(0, 2),
+		}
+	}
+	m := s.Modified
+	m.Base = m.Base.Subtract(*row.ReplacedBase)
+	m.Amount = m.Amount.Subtract(*row.ReplacedAmount)
+	s.Accrued = s.Accrued.Subtract(*row.ReplacedAmount)
+	if row.ReplacedSurcharge != nil {
+		m.SurchargeBase = m.SurchargeBase.Subtract(*row.ReplacedBase)
+		m.Surcharge = m.Surcharge.Subtract(*row.ReplacedSurcharge)
+		s.Accrued = s.Accrued.Subtract(*row.ReplacedSurcharge)
+	}
+}
+
+func rateTotal(list *[]*RateTotal, rate string) *RateTotal {
+	for _, rt := range *list {
+		if rt.Rate == rate {
+			return rt
+		}
+	}
+	rt := &RateTotal{
+		Rate:   rate,
+		Base:   num.MakeAmount(0, 2),
+		Amount: num.MakeAmount(0, 2),
+	}
+	*list = append(*list, rt)
+	return rt
+}
+
+func causeTotal(list *[]*CauseTotal, cause string) *CauseTotal {
+	for _, ct := range *list {
+		if ct.Cause == cause {
+			return ct
+		}
+	}
+	ct := &CauseTotal{
+		Cause: cause,
+		Base:  num.MakeAmount(0, 2),
+	}
+	*list = append(*list, ct)
+	return ct
+}
+
+// sortRates orders the totals by rate, which are always valid amounts as
+// generated by the convert package.
+func sortRates(list []*RateTotal) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, _ := num.AmountFromString(list[i].Rate)
+		b, _ := num.AmountFromString(list[j].Rate)
+		return a.Compare(b) < 0
+	})
+}
+
+func sortCauses(list []*CauseTotal) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Cause < list[j].Cause
+	})
+}
@@ -0,0 +1,253 @@
+package summary_test
+
+import (
+	"testing"
+
+	"github.com/invopop/gobl.ticketbai/convert"
+	"github.com/invopop/gobl.ticketbai/register"
+	"github.com/invopop/gobl.ticketbai/summary"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/num"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRegister(t *testing.T) *register.Register {
+	t.Helper()
+	r := register.New()
+	for _, name := range []string{
+		"sample-invoice.xml",
+		"sample-invoice-export.xml",
+		"invoice-es-es-reverse-charge.xml",
+		"invoice-es-nl-tbai-exempt.xml",
+		"credit-note-es-es-tbai.xml",
+	} {
+		require.NoError(t, r.Add(test.LoadSignedDocument(name).TicketBAI))
+	}
+
+	cancelled := test.LoadSignedDocument("invoice-es-es-b2c.xml").TicketBAI
+	require.NoError(t, r.Add(cancelled))
+	cd, err := convert.NewAnulaTicketBAIFromDocument(cancelled)
+	require.NoError(t, err)
+	require.NoError(t, cd.Sign("TEST", test.LoadCertificate(), convert.IssuerRoleSupplier, convert.ZoneBI))
+	require.NoError(t, r.Cancel(cd))
+	return r
+}
+
+func TestQuarter(t *testing.T) {
+	p := summary.Quarter(2022, 1)
+	assert.Equal(t, "2022-01-01", p.From.String())
+	assert.Equal(t, "2022-03-31", p.To.String())
+	p = summary.Quarter(2024, 4)
+	assert.Equal(t, "2024-10-01", p.From.String())
+	assert.Equal(t, "2024-12-31", p.To.String())
+	assert.True(t, p.Contains(cal.MakeDate(2024, 12, 31)))
+	assert.False(t, p.Contains(cal.MakeDate(2025, 1, 1)))
+}
+
+func TestSummary(t *testing.T) {
+	r := newRegister(t)
+
+	t.Run("should add up the period", func(t *testing.T) {
+		s, err := summary.New(r.Rows(), summary.Quarter(2022, 1))
+		require.NoError(t, err)
+
+		require.Len(t, s.Rates, 2)
+		assert.Equal(t, "0.00", s.Rates[0].Rate)
+		assert.Equal(t, "-5.00", s.Rates[0].Base.String())
+		assert.Equal(t, "0.00", s.Rates[0].Amount.String())
+		assert.Equal(t, "21.00", s.Rates[1].Rate)
+		assert.Equal(t, "-420.00", s.Rates[1].Base.String())
+		assert.Equal(t, "-88.20", s.Rates[1].Amount.String())
+
+		require.Len(t, s.Surcharges, 1)
+		assert.Equal(t, "5.20", s.Surcharges[0].Rate)
+		assert.Equal(t, "900.00", s.Surcharges[0].Base.String())
+		assert.Equal(t, "46.80", s.Surcharges[0].Amount.String())
+
+		assert.Equal(t, "1800.00", s.ReverseCharge.String())
+
+		require.Len(t, s.Exempt, 2)
+		assert.Equal(t, "E1", s.Exempt[0].Cause)
+		assert.Equal(t, "2220.00", s.Exempt[0].Base.String())
+		assert.Equal(t, "E2", s.Exempt[1].Cause)
+		assert.Equal(t, "100.00", s.Exempt[1].Base.String())
+		assert.Empty(t, s.NotSubject)
+
+		assert.Equal(t, "-41.40", s.Accrued.String())
+	})
+
+	t.Run("should skip other periods", func(t *testing.T) {
+		s, err := summary.New(r.Rows(), summary.Quarter(2022, 2))
+		require.NoError(t, err)
+		assert.Empty(t, s.Rates)
+		assert.Empty(t, s.Exempt)
+		assert.True(t, s.Accrued.IsZero())
+	})
+}
+
+func TestSummaryNotSubject(t *testing.T) {
+	date := cal.MakeDate(2022, 2, 1)
+	rows := []*register.Row{
+		{Series: "A", Number: "1", Date: date, Type: "OT", Base: num.MakeAmount(10000, 2)},
+		{Series: "A", Number: "2", Date: date, Type: "RL", Base: num.MakeAmount(5000, 2)},
+		{Series: "A", Number: "3", Date: date, Type: "OT", Base: num.MakeAmount(-2500, 2)},
+	}
+	s, err := summary.New(rows, summary.Quarter(2022, 1))
+	require.NoError(t, err)
+	require.Len(t, s.NotSubject, 2)
+	assert.Equal(t, "OT", s.NotSubject[0].Cause)
+	assert.Equal(t, "75.00", s.NotSubject[0].Base.String())
+	assert.Equal(t, "RL", s.NotSubject[1].Cause)
+	assert.Equal(t, "50.00", s.NotSubject[1].Base.String())
+}
+
+func TestSummarySubstitution(t *testing.T) {
+	replacedBase := num.MakeAmount(8000, 2)
+	replacedAmount := num.MakeAmount(1680, 2)
+	rows := []*register.Row{
+		{
+			Series:         "R",
+			Number:         "1",
+			Date:           cal.MakeDate(2022, 2, 1),
+			CorrectiveType: "S",
+			Type:           "S1",
+			Rate:           "21.00",
+			Base:           num.MakeAmount(10000, 2),
+			Amount:         num.MakeAmount(2100, 2),
+			ReplacedBase:   &replacedBase,
+			ReplacedAmount: &replacedAmount,
+		},
+		{
+			Series:         "R",
+			Number:         "1",
+			Date:           cal.MakeDate(2022, 2, 1),
+			CorrectiveType: "S",
+			Type:           "E1",
+			Base:           num.MakeAmount(5000, 2),
+			ReplacedBase:   &replacedBase,
+			ReplacedAmount: &replacedAmount,
+		},
+		{
+			Series:         "R",
+			Number:         "2",
+			Date:           cal.MakeDate(2022, 2, 1),
+			CorrectiveType: "S",
+			Type:           "S1",
+			Rate:           "21.00",
+			Base:           num.MakeAmount(3000, 2),
+			Amount:         num.MakeAmount(630, 2),
+		},
+		{
+			Series:             "F",
+			Number:             "1",
+			Date:               cal.MakeDate(2022, 2, 1),
+			ReplacesSimplified: true,
+			Type:               "S1",
+			Rate:               "21.00",
+			Base:               num.MakeAmount(2000, 2),
+			Amount:             num.MakeAmount(420, 2),
+		},
+		{
+			Series: "A",
+			Number: "1",
+			Date:   cal.MakeDate(2022, 2, 1),
+			Type:   "S1",
+			Rate:   "21.00",
+			Base:   num.MakeAmount(1000, 2),
+			Amount: num.MakeAmount(210, 2),
+		},
+	}
+	s, err := summary.New(rows, summary.Quarter(2022, 1))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"invoice R2: corrective invoice by substitution without the amounts replaced not included"}, s.Warnings)
+	require.Len(t, s.Rates, 1)
+	assert.Equal(t, "110.00", s.Rates[0].Base.String())
+	assert.Equal(t, "23.10", s.Rates[0].Amount.String())
+	require.Len(t, s.Exempt, 1)
+	assert.Equal(t, "50.00", s.Exempt[0].Base.String())
+	require.NotNil(t, s.Modified)
+	assert.Equal(t, "-80.00", s.Modified.Base.String())
+	assert.Equal(t, "-16.80", s.Modified.Amount.String())
+	assert.True(t, s.Modified.Surcharge.IsZero())
+	assert.Equal(t, "6.30", s.Accrued.String())
+}
+
+func TestModelo300(t *testing.T) {
+	t.Run("should map the totals to the boxes", func(t *testing.T) {
+		s, err := summary.New(newRegister(t).Rows(), summary.Quarter(2022, 1))
+		require.NoError(t, err)
+		boxes, err := s.Modelo300()
+		require.NoError(t, err)
+
+		got := make(map[string]string)
+		codes := make([]string, len(boxes))
+		for i, b := range boxes {
+			got[b.Code] = b.Amount.String()
+			codes[i] = b.Code
+		}
+		assert.Equal(t, []string{"07", "09", "22", "24", "27", "60", "122", "150", "152"}, codes)
+		assert.Equal(t, "-420.00", got["07"])
+		assert.Equal(t, "-88.20", got["09"])
+		assert.Equal(t, "900.00", got["22"])
+		assert.Equal(t, "46.80", got["24"])
+		assert.Equal(t, "-41.40", got["27"])
+		assert.Equal(t, "100.00", got["60"])
+		assert.Equal(t, "1800.00", got["122"])
+		assert.Equal(t, "-5.00", got["150"])
+	})
+
+	t.Run("should include the modifications", func(t *testing.T) {
+		base := num.MakeAmount(10000, 2)
+		amount := num.MakeAmount(2100, 2)
+		surcharge := num.MakeAmount(520, 2)
+		rows := []*register.Row{
+			{
+				Series:            "R",
+				Number:            "1",
+				Date:              cal.MakeDate(2022, 2, 1),
+				CorrectiveType:    "S",
+				Type:              "S1",
+				Rate:              "21.00",
+				Base:              num.MakeAmount(12000, 2),
+				Amount:            num.MakeAmount(2520, 2),
+				ReplacedBase:      &base,
+				ReplacedAmount:    &amount,
+				ReplacedSurcharge: &surcharge,
+			},
+		}
+		s, err := summary.New(rows, summary.Quarter(2022, 1))
+		require.NoError(t, err)
+		boxes, err := s.Modelo300()
+		require.NoError(t, err)
+
+		got := make(map[string]string)
+		for _, b := range boxes {
+			got[b.Code] = b.Amount.String()
+		}
+		assert.Equal(t, "-100.00", got["14"])
+		assert.Equal(t, "-21.00", got["15"])
+		assert.Equal(t, "-100.00", got["25"])
+		assert.Equal(t, "-5.20", got["26"])
+		assert.Equal(t, "-1.00", got["27"])
+	})
+
+	t.Run("should refuse rates without a box", func(t *testing.T) {
+		rows := []*register.Row{
+			{
+				Series: "A",
+				Number: "1",
+				Date:   cal.MakeDate(2022, 2, 1),
+				Type:   "S1",
+				Rate:   "7.50",
+				Base:   num.MakeAmount(1000, 2),
+				Amount: num.MakeAmount(75, 2),
+			},
+		}
+		s, err := summary.New(rows, summary.Quarter(2022, 1))
+		require.NoError(t, err)
+		_, err = s.Modelo300()
+		assert.ErrorContains(t, err, "rate 7.50: no box in Modelo 300")
+	})
+}
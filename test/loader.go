@@ -11,7 +11,9 @@ import (
 	"strings"
 
 	"github.com/invopop/gobl"
+	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/xmldsig"
 )
 
 // UpdateOut is a flag that can be set to update example files
@@ -81,6 +83,41 @@ func LoadInvoice(name string) *bill.Invoice {
 	return inv
 }
 
+// LoadSignedDocument parses a signed TicketBAI document from the
+// test/data/out folder.
+func LoadSignedDocument(name string) *convert.SignedDocument {
+	data, err := os.ReadFile(Path("test", "data", "out", name))
+	if err != nil {
+		panic(err)
+	}
+
+	sd, err := convert.ParseSignedDocument(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return sd
+}
+
+// LoadCertificate loads the test certificate used to sign the documents in
+// the test/data/out folder.
+func LoadCertificate() *xmldsig.Certificate {
+	pass, err := os.ReadFile(Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad_pin.txt"))
+	if err != nil {
+		panic(err)
+	}
+
+	cert, err := xmldsig.LoadCertificate(
+		Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad.p12"),
+		strings.TrimSpace(string(pass)),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	return cert
+}
+
 // Path joins the provided elements to the project root
 func Path(elements ...string) string {
 	np := []string{RootPath()}
@@ -6,24 +6,120 @@ import (
 	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl.ticketbai/internal/gateways"
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cal"
+	"github.com/invopop/gobl/org"
 )
 
 // TestConnection is a mock gateway connection for testing purposes
 type TestConnection struct {
 	postCalled   bool
 	cancelCalled bool
+	received     []*bill.Invoice
+	income       []*gateways.Income
+	renta        []*gateways.Renta
 }
 
-var _ gateways.Connection = (*TestConnection)(nil)
+var (
+	_ gateways.Connection         = (*TestConnection)(nil)
+	_ gateways.ReceivedConnection = (*TestConnection)(nil)
+	_ gateways.IncomeConnection   = (*TestConnection)(nil)
+	_ gateways.RentaConnection    = (*TestConnection)(nil)
+)
 
 // Post mocks the Post method of the Connection interface
-func (tc *TestConnection) Post(_ context.Context, _ *bill.Invoice, _ *convert.TicketBAI) error {
+func (tc *TestConnection) Post(_ context.Context, _ *bill.Invoice, _ *convert.TicketBAI) (*gateways.Receipt, error) {
+	tc.postCalled = true
+	return new(gateways.Receipt), nil
+}
+
+// PostWithRenta mocks the PostWithRenta method of the RentaConnection interface
+func (tc *TestConnection) PostWithRenta(_ context.Context, _ *bill.Invoice, _ *convert.TicketBAI, renta []*gateways.Renta) (*gateways.Receipt, error) {
 	tc.postCalled = true
-	return nil
+	tc.renta = renta
+	return new(gateways.Receipt), nil
+}
+
+// PostSignedWithRenta mocks the PostSignedWithRenta method of the RentaConnection interface
+func (tc *TestConnection) PostSignedWithRenta(_ context.Context, _ *bill.Invoice, _ *convert.SignedDocument, renta []*gateways.Renta) (*gateways.Receipt, error) {
+	tc.postCalled = true
+	tc.renta = renta
+	return new(gateways.Receipt), nil
 }
 
 // Cancel mocks the Cancel method of the Connection interface
-func (tc *TestConnection) Cancel(_ context.Context, _ *bill.Invoice, _ *convert.AnulaTicketBAI) error {
+func (tc *TestConnection) Cancel(_ context.Context, _ *bill.Invoice, _ *convert.AnulaTicketBAI) (*gateways.Receipt, error) {
+	tc.cancelCalled = true
+	return new(gateways.Receipt), nil
+}
+
+// PostSigned mocks the PostSigned method of the Connection interface
+func (tc *TestConnection) PostSigned(_ context.Context, _ *bill.Invoice, _ *convert.SignedDocument) (*gateways.Receipt, error) {
+	tc.postCalled = true
+	return new(gateways.Receipt), nil
+}
+
+// CancelSigned mocks the CancelSigned method of the Connection interface
+func (tc *TestConnection) CancelSigned(_ context.Context, _ *bill.Invoice, _ *convert.SignedCancelDocument) (*gateways.Receipt, error) {
+	tc.cancelCalled = true
+	return new(gateways.Receipt), nil
+}
+
+// PostReceived mocks the PostReceived method of the ReceivedConnection interface
+func (tc *TestConnection) PostReceived(_ context.Context, inv *bill.Invoice, _ cal.Date) (*gateways.Receipt, error) {
+	tc.received = append(tc.received, inv)
+	return new(gateways.Receipt), nil
+}
+
+// CancelReceived mocks the CancelReceived method of the ReceivedConnection interface
+func (tc *TestConnection) CancelReceived(_ context.Context, _ *bill.Invoice) (*gateways.Receipt, error) {
+	tc.cancelCalled = true
+	return new(gateways.Receipt), nil
+}
+
+// FetchReceived mocks the FetchReceived method of the ReceivedConnection interface
+func (tc *TestConnection) FetchReceived(_ context.Context, _ *org.Party, _ string, _ int) ([]*gateways.ReceivedInvoice, error) {
+	out := make([]*gateways.ReceivedInvoice, len(tc.received))
+	for i, inv := range tc.received {
+		out[i] = &gateways.ReceivedInvoice{
+			Series: inv.Series.String(),
+			Code:   inv.Code.String(),
+		}
+	}
+	return out, nil
+}
+
+// PostIncome mocks the PostIncome method of the IncomeConnection interface
+func (tc *TestConnection) PostIncome(_ context.Context, _ *org.Party, inc *gateways.Income) (*gateways.Receipt, error) {
+	tc.income = append(tc.income, inc)
+	return new(gateways.Receipt), nil
+}
+
+// CancelIncome mocks the CancelIncome method of the IncomeConnection interface,
+// removing the income posted before with the same date and reference.
+func (tc *TestConnection) CancelIncome(_ context.Context, _ *org.Party, inc *gateways.Income) (*gateways.Receipt, error) {
+	for i, posted := range tc.income {
+		if posted.Date == inc.Date && posted.Reference == inc.Reference {
+			tc.income = append(tc.income[:i:i], tc.income[i+1:]...)
+			return new(gateways.Receipt), nil
+		}
+	}
+	return nil, gateways.ErrNotFound
+}
+
+// FetchIncome mocks the FetchIncome method of the IncomeConnection interface
+func (tc *TestConnection) FetchIncome(_ context.Context, _ *org.Party, _ string, _ int) ([]*gateways.Income, error) {
+	return tc.income, nil
+}
+
+// PostIncomeInvoice mocks the PostIncomeInvoice method of the IncomeConnection interface
+func (tc *TestConnection) PostIncomeInvoice(_ context.Context, _ *bill.Invoice, _ *convert.TicketBAI, renta []*gateways.Renta) (*gateways.Receipt, error) {
+	tc.postCalled = true
+	tc.renta = renta
+	return new(gateways.Receipt), nil
+}
+
+// CancelIncomeInvoice mocks the CancelIncomeInvoice method of the IncomeConnection interface
+func (tc *TestConnection) CancelIncomeInvoice(_ context.Context, _ *bill.Invoice) (*gateways.Receipt, error) {
 	tc.cancelCalled = true
-	return nil
+	return new(gateways.Receipt), nil
 }
@@ -4,12 +4,19 @@ package ticketbai
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/invopop/gobl"
 	"github.com/invopop/gobl.ticketbai/convert"
 	"github.com/invopop/gobl.ticketbai/internal/gateways"
+	"github.com/invopop/gobl/addons/es/tbai"
 	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/head"
 	"github.com/invopop/gobl/l10n"
 	"github.com/invopop/xmldsig"
 	"github.com/nbio/xml"
@@ -22,15 +29,26 @@ const (
 	ZoneVI l10n.Code = convert.ZoneVI // Araba
 )
 
+// StampCancel is the envelope stamp provider added once a cancellation has been
+// accepted by the TicketBAI gateway. The value contains the TicketBAI code of
+// the registration that was cancelled, if available.
+const StampCancel cbc.Key = "tbai-cancel"
+
 // Client provides the main interface to the TicketBAI package.
 type Client struct {
 	software   *Software
 	zone       l10n.Code
 	cert       *xmldsig.Certificate
+	signer     *convert.Signer
+	trust      trustOptions
 	env        gateways.Environment
 	issuerRole convert.IssuerRole
 	curTime    time.Time
 	gw         gateways.Connection
+	zones      *ZoneResolver
+	device     string
+	developer  *convert.IDOtro
+	convOpts   []convert.Option
 }
 
 // Option is used to configure the client.
@@ -44,6 +62,15 @@ func WithCertificate(cert *xmldsig.Certificate) Option {
 	}
 }
 
+// WithSigner defines the signer to use when producing the TicketBAI documents
+// and authenticating with the gateway, instead of a certificate with the
+// private key held in memory. Takes precedence over WithCertificate.
+func WithSigner(signer *convert.Signer) Option {
+	return func(c *Client) {
+		c.signer = signer
+	}
+}
+
 // WithCurrentTime defines the current time to use when generating the TicketBAI
 // document. Useful for testing.
 func WithCurrentTime(curTime time.Time) Option {
@@ -60,6 +87,69 @@ func WithConnection(conn gateways.Connection) Option {
 	}
 }
 
+// WithZoneResolver defines the resolver used to determine the zone of invoices
+// that do not include the es-tbai-region extension.
+func WithZoneResolver(zr *ZoneResolver) Option {
+	return func(c *Client) {
+		c.zones = zr
+	}
+}
+
+// WithDeviceSerial defines the serial number of the device (till, terminal,
+// etc.) issuing the documents, to be included in every fingerprint. Max 30
+// characters.
+func WithDeviceSerial(serial string) Option {
+	return func(c *Client) {
+		c.device = serial
+	}
+}
+
+// WithForeignDeveloper identifies the software developer company with a
+// foreign identity instead of the Spanish NIF defined in the Software, for
+// developers that do not have one.
+func WithForeignDeveloper(id *convert.IDOtro) Option {
+	return func(c *Client) {
+		c.developer = id
+	}
+}
+
+// WithLineAggregation allows invoices with more detail lines than accepted
+// in Gipuzkoa and Araba to be converted by merging lines with the same item,
+// price and taxes.
+func WithLineAggregation() Option {
+	return func(c *Client) {
+		c.convOpts = append(c.convOpts, convert.WithLineAggregation())
+	}
+}
+
+// WithDescription defines the sources used to generate the description of
+// the invoices, tried in order until one provides some text. The text of the
+// invoice's general note is used when none of them do.
+func WithDescription(sources ...convert.DescriptionSource) Option {
+	return func(c *Client) {
+		c.convOpts = append(c.convOpts, convert.WithDescription(sources...))
+	}
+}
+
+// WithCoRecipients includes the people of the customer as additional
+// recipients of the invoices.
+func WithCoRecipients() Option {
+	return func(c *Client) {
+		c.convOpts = append(c.convOpts, convert.WithCoRecipients())
+	}
+}
+
+// WithRegimeKeys adds regime keys that cannot be determined from the
+// invoices to all the documents converted by the client, for example the
+// rental of business premises (11, 12 or 13) for a supplier that only
+// issues rental invoices. Keys for a single invoice can be provided to
+// Convert instead.
+func WithRegimeKeys(codes ...string) Option {
+	return func(c *Client) {
+		c.convOpts = append(c.convOpts, convert.WithRegimeKeys(codes...))
+	}
+}
+
 // WithSupplierIssuer set the issuer type to supplier. To be used when the
 // invoice's supplier, using their own certificate, is issuing the document.
 func WithSupplierIssuer() Option {
@@ -104,7 +194,8 @@ type Licenses map[gateways.Environment]map[l10n.Code]string
 
 // Software defines the details about the software that is using this library to
 // generate TicketBAI documents. These details are included in the final
-// document.
+// document, except for the CompanyName which has no place in the TicketBAI
+// format and is only kept for reference.
 type Software struct {
 	Licenses    Licenses
 	NIF         string
@@ -129,8 +220,15 @@ func New(software *Software, zone l10n.Code, opts ...Option) (*Client, error) {
 	}
 
 	if c.gw == nil {
-		var err error
-		c.gw, err = gateways.New(c.env, c.zone, c.cert)
+		tlsCert, err := c.tlsCertificate()
+		if err != nil {
+			return nil, err
+		}
+		trust, err := c.gatewayTrust()
+		if err != nil {
+			return nil, err
+		}
+		c.gw, err = gateways.New(c.env, c.zone, tlsCert, trust)
 		if err != nil {
 			return nil, err
 		}
@@ -139,25 +237,208 @@ func New(software *Software, zone l10n.Code, opts ...Option) (*Client, error) {
 	return c, nil
 }
 
+// Receipt contains the details provided by the TicketBAI gateway once a
+// document has been accepted.
+type Receipt = gateways.Receipt
+
+// Renta contains the income details of an activity of a natural person
+// under Modelo 140 in Bizkaia that cannot be determined from the invoice.
+type Renta = gateways.Renta
+
+// PostOption is used to configure the posting of a single document.
+type PostOption func(*postOptions)
+
+type postOptions struct {
+	renta []*Renta
+}
+
+// WithRenta provides the income details reported along with the invoice of a
+// natural person under Modelo 140 in Bizkaia that cannot be determined from
+// the invoice, such as the territory where the activity is registered, the
+// property of rental income, or the income for IRPF purposes. Details apply
+// to the activity they define, or to all the activities of the invoice if
+// none.
+func WithRenta(renta ...*Renta) PostOption {
+	return func(o *postOptions) {
+		o.renta = append(o.renta, renta...)
+	}
+}
+
+func newPostOptions(opts []PostOption) *postOptions {
+	o := new(postOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
 // Post will send the document to the TicketBAI gateway.
-func (c *Client) Post(ctx context.Context, env *gobl.Envelope, d *convert.TicketBAI) error {
+func (c *Client) Post(ctx context.Context, env *gobl.Envelope, d *convert.TicketBAI, opts ...PostOption) (*Receipt, error) {
+	inv, err := c.postInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	var r *Receipt
+	if o := newPostOptions(opts); len(o.renta) > 0 {
+		gw, err := c.rentaConnection()
+		if err != nil {
+			return nil, err
+		}
+		r, err = gw.PostWithRenta(ctx, inv, d, o.renta)
+	} else {
+		r, err = c.gw.Post(ctx, inv, d)
+	}
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// PostSigned will send the exact XML of the signed document to the TicketBAI
+// gateway, for example when posting again a document persisted before an
+// outage. The document must refer to the invoice in the envelope.
+func (c *Client) PostSigned(ctx context.Context, env *gobl.Envelope, d *convert.SignedDocument, opts ...PostOption) (*Receipt, error) {
+	inv, err := c.postInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.Sujetos == nil || d.Sujetos.Emisor == nil || d.Factura == nil || d.Factura.CabeceraFactura == nil {
+		return nil, ErrValidation.withMessage("signed document has no invoice")
+	}
+	h := d.Factura.CabeceraFactura
+	if err := matchInvoice(inv, d.Sujetos.Emisor.NIF, h.SerieFactura, h.NumFactura, h.FechaExpedicionFactura); err != nil {
+		return nil, err
+	}
+	var r *Receipt
+	if o := newPostOptions(opts); len(o.renta) > 0 {
+		gw, err := c.rentaConnection()
+		if err != nil {
+			return nil, err
+		}
+		r, err = gw.PostSignedWithRenta(ctx, inv, d, o.renta)
+	} else {
+		r, err = c.gw.PostSigned(ctx, inv, d)
+	}
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	return r, nil
+}
+
+// rentaConnection provides the client's gateway connection if it supports
+// the income details of Modelo 140.
+func (c *Client) rentaConnection() (gateways.RentaConnection, error) {
+	gw, ok := c.gw.(gateways.RentaConnection)
+	if !ok || c.zone != ZoneBI {
+		return nil, ErrValidation.withMessage("income details are only supported in Bizkaia")
+	}
+	return gw, nil
+}
+
+// Cancel will send the cancel document in the TicketBAI gateway. Errors
+// will be classified as ErrNotFound, ErrAlreadyCancelled, ErrValidation or
+// ErrConnection according to the gateway's response.
+func (c *Client) Cancel(ctx context.Context, env *gobl.Envelope, d *convert.AnulaTicketBAI) (*Receipt, error) {
+	inv, err := c.postInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.gw.Cancel(ctx, inv, d)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	addCancelStamp(env, d)
+	return r, nil
+}
+
+// CancelSigned will send the exact XML of the signed cancel document to the
+// TicketBAI gateway. The document must refer to the invoice in the envelope.
+// Errors are classified as in Cancel.
+func (c *Client) CancelSigned(ctx context.Context, env *gobl.Envelope, d *convert.SignedCancelDocument) (*Receipt, error) {
+	inv, err := c.postInvoice(env)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.IDFactura == nil || d.IDFactura.Emisor == nil || d.IDFactura.CabeceraFactura == nil {
+		return nil, ErrValidation.withMessage("signed document has no invoice")
+	}
+	h := d.IDFactura.CabeceraFactura
+	if err := matchInvoice(inv, d.IDFactura.Emisor.NIF, h.SerieFactura, h.NumFactura, h.FechaExpedicionFactura); err != nil {
+		return nil, err
+	}
+	r, err := c.gw.CancelSigned(ctx, inv, d)
+	if err != nil {
+		return nil, newErrorFrom(err)
+	}
+	addCancelStamp(env, d.AnulaTicketBAI)
+	return r, nil
+}
+
+// postInvoice extracts the invoice from the envelope, ensuring it can be sent
+// with the client's gateway connection.
+func (c *Client) postInvoice(env *gobl.Envelope) (*bill.Invoice, error) {
 	inv, ok := env.Extract().(*bill.Invoice)
 	if !ok {
-		return ErrValidation.withMessage("only invoices are supported")
+		return nil, ErrValidation.withMessage("only invoices are supported")
 	}
-	if err := c.gw.Post(ctx, inv, d); err != nil {
-		return newErrorFrom(err)
+	if err := c.checkZone(inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// matchInvoice ensures a signed document refers to the invoice in the
+// envelope, comparing the supplier's NIF, series, number and issue date, so
+// that a document persisted for one invoice is never sent or stamped for
+// another.
+func matchInvoice(inv *bill.Invoice, nif, series, code, date string) error {
+	if inv.Supplier == nil || inv.Supplier.TaxID == nil || inv.Supplier.TaxID.Code.String() != nif {
+		return ErrValidation.withMessage("signed document supplier %s does not match the invoice", nif)
+	}
+	if inv.Series.String() != series || inv.Code.String() != code {
+		return ErrValidation.withMessage("signed document invoice %s%s does not match the invoice", series, code)
+	}
+	if convert.FormatDate(inv.IssueDate) != date {
+		return ErrValidation.withMessage("signed document issue date %s does not match the invoice", date)
 	}
 	return nil
 }
 
-// Cancel will send the cancel document in the TicketBAI gateway.
-func (c *Client) Cancel(ctx context.Context, env *gobl.Envelope, d *convert.AnulaTicketBAI) error {
-	inv, ok := env.Extract().(*bill.Invoice)
-	if !ok {
-		return ErrValidation.withMessage("only invoices are supported")
+func addCancelStamp(env *gobl.Envelope, d *convert.AnulaTicketBAI) {
+	env.Head.AddStamp(
+		&head.Stamp{
+			Provider: StampCancel,
+			Value:    cancelStampValue(env, d),
+		},
+	)
+}
+
+// checkZone ensures the invoice belongs to the zone the client's gateway
+// connection was prepared for, so that documents signed with one zone's policy
+// are never sent to another zone's gateway.
+func (c *Client) checkZone(inv *bill.Invoice) error {
+	return c.matchZone(c.zoneFor(inv))
+}
+
+// matchZone ensures the zone of an invoice is the client's zone, so that
+// documents are never converted or signed mixing one zone's policy with
+// another zone's licence.
+func (c *Client) matchZone(zone l10n.Code) error {
+	if zone != c.zone {
+		return ErrValidation.withMessage("invoice zone '%s' does not match client zone '%s'", zone, c.zone)
+	}
+	return nil
+}
+
+// cancelStampValue provides the TicketBAI code of the cancelled registration,
+// or the cancelled invoice number if the envelope no longer has the code.
+func cancelStampValue(env *gobl.Envelope, d *convert.AnulaTicketBAI) string {
+	for _, stamp := range env.Head.Stamps {
+		if stamp.Provider == tbai.StampCode {
+			return stamp.Value
+		}
 	}
-	return c.gw.Cancel(ctx, inv, d)
+	return d.IDFactura.CabeceraFactura.NumFactura
 }
 
 // ParseDocument will parse the XML data into a TicketBAI document.
@@ -169,6 +450,18 @@ func ParseDocument(data []byte) (*convert.TicketBAI, error) {
 	return d, nil
 }
 
+// ParseSignedDocument will parse the XML data into a TicketBAI document,
+// keeping the original data to be sent again without any changes.
+func ParseSignedDocument(data []byte) (*convert.SignedDocument, error) {
+	return convert.ParseSignedDocument(data)
+}
+
+// ParseSignedCancelDocument will parse the XML data into a Cancel TicketBAI
+// document, keeping the original data to be sent again without any changes.
+func ParseSignedCancelDocument(data []byte) (*convert.SignedCancelDocument, error) {
+	return convert.ParseSignedCancelDocument(data)
+}
+
 // ParseCancelDocument will parse the XML data into a Cancel TicketBAI document.
 func ParseCancelDocument(data []byte) (*convert.AnulaTicketBAI, error) {
 	d := new(convert.AnulaTicketBAI)
@@ -198,10 +491,39 @@ func (c *Client) Sandbox() bool {
 }
 
 func (c *Client) buildSoftware() *convert.Software {
-	return &convert.Software{
+	soft := &convert.Software{
 		License: c.software.Licenses[c.env][c.zone],
-		NIF:     c.software.NIF,
 		Name:    c.software.Name,
 		Version: c.software.Version,
 	}
+	if c.developer != nil {
+		soft.IDOtro = c.developer
+	} else {
+		soft.NIF = c.software.NIF
+	}
+	return soft
+}
+
+// tlsCertificate provides the client certificate used to authenticate with
+// the gateway, from either the signer or the signing certificate.
+func (c *Client) tlsCertificate() (tls.Certificate, error) {
+	if c.signer != nil {
+		return c.signer.TLSCertificate(), nil
+	}
+	if c.cert == nil {
+		return tls.Certificate{}, errors.New("missing certificate or signer")
+	}
+	conf, err := c.cert.TLSAuthConfig()
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("preparing TLS config: %w", err)
+	}
+	return conf.Certificates[0], nil
+}
+
+// checkDevice ensures the device serial number fits in the TicketBAI document.
+func (c *Client) checkDevice() error {
+	if utf8.RuneCountInString(c.device) > 30 {
+		return ErrValidation.withMessage("device serial number over 30 characters")
+	}
+	return nil
 }
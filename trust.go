@@ -0,0 +1,91 @@
+package ticketbai
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/invopop/gobl.ticketbai/ca"
+	"github.com/invopop/gobl.ticketbai/internal/gateways"
+	"github.com/invopop/gobl/l10n"
+)
+
+// trustOptions contains the settings used to verify the gateways' servers.
+type trustOptions struct {
+	roots *x509.CertPool
+	extra []*x509.Certificate
+	files []string
+	pins  map[l10n.Code][]*x509.Certificate
+}
+
+// WithRootCAs adds certificates to the root CAs trusted to verify the
+// gateways' servers, for example when the agencies move to new roots before
+// they are embedded in the ca package.
+func WithRootCAs(certs ...*x509.Certificate) Option {
+	return func(c *Client) {
+		c.trust.extra = append(c.trust.extra, certs...)
+	}
+}
+
+// WithRootCAPool replaces the root CAs embedded in the ca package with the
+// ones in the pool, for example to connect to local stand-in servers. Any
+// certificates added with WithRootCAs or WithRootCAFiles are also trusted.
+func WithRootCAPool(pool *x509.CertPool) Option {
+	return func(c *Client) {
+		c.trust.roots = pool
+	}
+}
+
+// WithRootCAFiles adds the certificates in the PEM files at the given paths
+// to the root CAs trusted to verify the gateways' servers. Files are loaded
+// when the client is created.
+func WithRootCAFiles(paths ...string) Option {
+	return func(c *Client) {
+		c.trust.files = append(c.trust.files, paths...)
+	}
+}
+
+// WithPinnedCertificates defines the certificates expected from the gateway
+// of the zone, on top of the verification against the root CAs. Connections
+// are refused unless the server's chain contains one of their public keys.
+// Pins for other zones are ignored, so the same options may be shared by the
+// clients of every zone.
+func WithPinnedCertificates(zone l10n.Code, certs ...*x509.Certificate) Option {
+	return func(c *Client) {
+		if c.trust.pins == nil {
+			c.trust.pins = make(map[l10n.Code][]*x509.Certificate)
+		}
+		c.trust.pins[zone] = append(c.trust.pins[zone], certs...)
+	}
+}
+
+// gatewayTrust prepares the settings used by the gateway connection to verify
+// the servers of the client's zone.
+func (c *Client) gatewayTrust() (*gateways.Trust, error) {
+	pool := c.trust.roots
+	if pool == nil {
+		var err error
+		pool, err = ca.Pool()
+		if err != nil {
+			return nil, fmt.Errorf("preparing cert pool: %w", err)
+		}
+	} else if len(c.trust.extra) > 0 || len(c.trust.files) > 0 {
+		pool = pool.Clone() // avoid modifying the pool provided
+	}
+	for _, cert := range c.trust.extra {
+		pool.AddCert(cert)
+	}
+	for _, path := range c.trust.files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading root CAs: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("loading root CAs: no certificates found in %s", path)
+		}
+	}
+	return &gateways.Trust{
+		Roots: pool,
+		Pins:  c.trust.pins[c.zone],
+	}, nil
+}
@@ -0,0 +1,54 @@
+package ticketbai_test
+
+import (
+	"os"
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/xmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustOptions(t *testing.T) {
+	pass, err := os.ReadFile(
+		test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad_pin.txt"),
+	)
+	require.NoError(t, err)
+	cert, err := xmldsig.LoadCertificate(
+		test.Path("test", "certs", "EntitateOrdezkaria_RepresentanteDeEntidad.p12"),
+		string(pass),
+	)
+	require.NoError(t, err)
+	soft := &ticketbai.Software{NIF: "12345678A"}
+
+	t.Run("should load root CAs from disk", func(t *testing.T) {
+		_, err := ticketbai.New(soft, ticketbai.ZoneBI,
+			ticketbai.WithCertificate(cert),
+			ticketbai.WithRootCAFiles(test.Path("ca", "RAIZ2007_cert_sha256.pem")),
+		)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should refuse missing files", func(t *testing.T) {
+		_, err := ticketbai.New(soft, ticketbai.ZoneBI,
+			ticketbai.WithCertificate(cert),
+			ticketbai.WithRootCAFiles(test.Path("ca", "missing.pem")),
+		)
+		assert.ErrorContains(t, err, "loading root CAs")
+	})
+
+	t.Run("should refuse files without certificates", func(t *testing.T) {
+		_, err := ticketbai.New(soft, ticketbai.ZoneBI,
+			ticketbai.WithCertificate(cert),
+			ticketbai.WithRootCAFiles(test.Path("ca", "ca.go")),
+		)
+		assert.ErrorContains(t, err, "no certificates found")
+	})
+
+	t.Run("should require a certificate or signer", func(t *testing.T) {
+		_, err := ticketbai.New(soft, ticketbai.ZoneBI)
+		assert.ErrorContains(t, err, "missing certificate or signer")
+	})
+}
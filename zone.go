@@ -0,0 +1,109 @@
+package ticketbai
+
+import (
+	"strings"
+
+	"github.com/invopop/gobl"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/l10n"
+)
+
+// ZoneSource describes how the zone of an invoice was determined.
+type ZoneSource string
+
+// Sources used to determine an invoice's zone.
+const (
+	ZoneSourceNone      ZoneSource = ""          // zone could not be determined
+	ZoneSourceExtension ZoneSource = "extension" // from the es-tbai-region extension
+	ZoneSourceNIF       ZoneSource = "nif"       // from the per-NIF configuration
+	ZoneSourceAddress   ZoneSource = "address"   // from the supplier's postal code
+)
+
+// postCodeZones maps the province prefix of Spanish postal codes to the
+// corresponding TicketBAI zone.
+var postCodeZones = map[string]l10n.Code{
+	"01": ZoneVI, // Araba
+	"20": ZoneSS, // Gipuzkoa
+	"48": ZoneBI, // Bizkaia
+}
+
+// ZoneResolver is used to determine the zone of invoices that may be missing
+// the es-tbai-region extension. The extension always takes priority, followed
+// by the per-NIF configuration and, if enabled, the supplier's address.
+type ZoneResolver struct {
+	nifs    map[string]l10n.Code
+	address bool
+}
+
+// ZoneResolverOption is used to configure the zone resolver.
+type ZoneResolverOption func(*ZoneResolver)
+
+// WithNIFZone assigns the zone to use for invoices issued by the supplier with
+// the given NIF.
+func WithNIFZone(nif string, zone l10n.Code) ZoneResolverOption {
+	return func(zr *ZoneResolver) {
+		zr.nifs[normalizeNIF(nif)] = zone
+	}
+}
+
+// WithAddressZone enables determining the zone from the postal code of the
+// supplier's first address.
+func WithAddressZone() ZoneResolverOption {
+	return func(zr *ZoneResolver) {
+		zr.address = true
+	}
+}
+
+// NewZoneResolver creates a new zone resolver with the provided options.
+func NewZoneResolver(opts ...ZoneResolverOption) *ZoneResolver {
+	zr := &ZoneResolver{
+		nifs: make(map[string]l10n.Code),
+	}
+	for _, opt := range opts {
+		opt(zr)
+	}
+	return zr
+}
+
+// Resolve determines the zone of the invoice, along with the source that was
+// used to determine it. An empty zone is returned if not possible.
+func (zr *ZoneResolver) Resolve(inv *bill.Invoice) (l10n.Code, ZoneSource) {
+	if zone := zoneFor(inv); zone != "" {
+		return zone, ZoneSourceExtension
+	}
+	if zr == nil || inv == nil || inv.Supplier == nil {
+		return "", ZoneSourceNone
+	}
+	if inv.Supplier.TaxID != nil {
+		if zone, ok := zr.nifs[normalizeNIF(inv.Supplier.TaxID.Code.String())]; ok {
+			return zone, ZoneSourceNIF
+		}
+	}
+	if zr.address && len(inv.Supplier.Addresses) > 0 {
+		addr := inv.Supplier.Addresses[0]
+		if addr != nil && (addr.Country == "" || addr.Country == l10n.ES.ISO()) {
+			code := strings.TrimSpace(addr.Code.String())
+			if len(code) == 5 {
+				if zone, ok := postCodeZones[code[:2]]; ok {
+					return zone, ZoneSourceAddress
+				}
+			}
+		}
+	}
+	return "", ZoneSourceNone
+}
+
+// ResolveEnvelope determines the zone of the invoice contained in the
+// envelope.
+func (zr *ZoneResolver) ResolveEnvelope(env *gobl.Envelope) (l10n.Code, ZoneSource) {
+	inv, ok := env.Extract().(*bill.Invoice)
+	if !ok {
+		return "", ZoneSourceNone
+	}
+	return zr.Resolve(inv)
+}
+
+func normalizeNIF(nif string) string {
+	nif = strings.ToUpper(strings.TrimSpace(nif))
+	return strings.TrimPrefix(nif, "ES")
+}
@@ -0,0 +1,93 @@
+package ticketbai_test
+
+import (
+	"testing"
+
+	ticketbai "github.com/invopop/gobl.ticketbai"
+	"github.com/invopop/gobl.ticketbai/test"
+	"github.com/invopop/gobl/addons/es/tbai"
+	"github.com/invopop/gobl/bill"
+	"github.com/invopop/gobl/cbc"
+	"github.com/invopop/gobl/org"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneResolver(t *testing.T) {
+	withoutRegion := func(t *testing.T) *bill.Invoice {
+		t.Helper()
+		inv := test.LoadInvoice("sample-invoice.json")
+		require.NotNil(t, inv.Tax)
+		delete(inv.Tax.Ext, tbai.ExtKeyRegion)
+		return inv
+	}
+
+	t.Run("should prefer the extension", func(t *testing.T) {
+		inv := test.LoadInvoice("invoice-ss.json")
+		zr := ticketbai.NewZoneResolver(ticketbai.WithNIFZone(inv.Supplier.TaxID.Code.String(), ticketbai.ZoneVI))
+
+		zone, src := zr.Resolve(inv)
+		assert.Equal(t, ticketbai.ZoneSS, zone)
+		assert.Equal(t, ticketbai.ZoneSourceExtension, src)
+	})
+
+	t.Run("should not infer without options", func(t *testing.T) {
+		inv := withoutRegion(t)
+
+		zone, src := ticketbai.NewZoneResolver().Resolve(inv)
+		assert.Empty(t, zone)
+		assert.Equal(t, ticketbai.ZoneSourceNone, src)
+	})
+
+	t.Run("should use the NIF configuration", func(t *testing.T) {
+		inv := withoutRegion(t)
+		zr := ticketbai.NewZoneResolver(
+			ticketbai.WithNIFZone("es"+inv.Supplier.TaxID.Code.String(), ticketbai.ZoneVI),
+			ticketbai.WithAddressZone(),
+		)
+
+		zone, src := zr.Resolve(inv)
+		assert.Equal(t, ticketbai.ZoneVI, zone)
+		assert.Equal(t, ticketbai.ZoneSourceNIF, src)
+	})
+
+	t.Run("should use the supplier postal code", func(t *testing.T) {
+		zr := ticketbai.NewZoneResolver(ticketbai.WithAddressZone())
+		for code, want := range map[string]string{
+			"01001": "VI",
+			"20018": "SS",
+			"48001": "BI",
+			"28001": "",
+		} {
+			inv := withoutRegion(t)
+			inv.Supplier.Addresses = []*org.Address{{Code: cbc.Code(code), Country: "ES"}}
+
+			zone, _ := zr.Resolve(inv)
+			assert.Equal(t, want, zone.String(), code)
+		}
+	})
+
+	t.Run("should allow the client to convert without the extension", func(t *testing.T) {
+		env := test.LoadEnvelope("invoice-ss.json")
+		inv := env.Extract().(*bill.Invoice)
+		delete(inv.Tax.Ext, tbai.ExtKeyRegion)
+		inv.Supplier.Addresses = []*org.Address{{Code: "20018", Country: "ES"}}
+
+		tc := loadTBAIClient(t, ticketbai.ZoneSS)
+		_, err := tc.Convert(env)
+		assert.ErrorContains(t, err, "invalid zone")
+
+		tc = loadTBAIClient(t, ticketbai.ZoneSS,
+			ticketbai.WithZoneResolver(ticketbai.NewZoneResolver(ticketbai.WithAddressZone())),
+		)
+		zone, src := tc.ResolveZone(env)
+		assert.Equal(t, ticketbai.ZoneSS, zone)
+		assert.Equal(t, ticketbai.ZoneSourceAddress, src)
+
+		td, err := tc.Convert(env)
+		require.NoError(t, err)
+		require.NoError(t, tc.Fingerprint(td, nil))
+		require.NoError(t, tc.Sign(td, env))
+		assert.Contains(t, td.QRCodes(ticketbai.ZoneSS).QRCode, "gipuzkoa")
+	})
+}